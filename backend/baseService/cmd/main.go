@@ -2,6 +2,8 @@ package main
 
 import (
 	"context"
+	"reflect"
+
 	"github.com/cloudzenith/DouTok/backend/baseService/internal/conf"
 	"github.com/cloudzenith/DouTok/backend/baseService/internal/infrastructure/dal/query"
 	"github.com/cloudzenith/DouTok/backend/baseService/internal/infrastructure/utils"
@@ -16,8 +18,14 @@ import (
 
 func main() {
 	c := &conf.Config{}
+	shardingNumbers := conf.NewShardingNumbers(conf.Data{})
 	launcher.New(
 		launcher.WithConfigValue(c),
+		launcher.WithConfigReloadHandler(func(old, new *conf.Config) {
+			if !reflect.DeepEqual(old.Data.DbShardingConfig, new.Data.DbShardingConfig) {
+				server.ReloadFileTableSharding(shardingNumbers, new.Data)
+			}
+		}),
 		launcher.WithConfigOptions(
 			config.WithSource(file.NewSource("configs/")),
 		),
@@ -32,9 +40,10 @@ func main() {
 
 			utils.InitDefaultSnowflakeNode(cfg.Snowflake.Node)
 			log.Errorf("config: %+v", cfg)
+			shardingNumbers.Store(cfg.Data)
 			return server.NewGRPCServer(
 				cfg,
-				server.WithFileTableShardingConfig(cfg.Data),
+				server.WithFileTableShardingConfig(shardingNumbers),
 				server.WithDBShardingTablesConfig(cfg.Data.DbShardingTables),
 			)
 		}),
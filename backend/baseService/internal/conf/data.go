@@ -1,6 +1,9 @@
 package conf
 
-import "fmt"
+import (
+	"fmt"
+	"sync/atomic"
+)
 
 type Data struct {
 	Database struct {
@@ -53,3 +56,23 @@ func (config Data) GetShardingNumber(fileName, domainName, bizName string) int64
 
 	return 1
 }
+
+// ShardingNumbers serves the sharding numbers of the last stored Data, so they can be changed by a config reload.
+// Changing a sharding number remaps the existing rows unless the tables are migrated.
+type ShardingNumbers struct {
+	data atomic.Pointer[Data]
+}
+
+func NewShardingNumbers(data Data) *ShardingNumbers {
+	n := &ShardingNumbers{}
+	n.Store(data)
+	return n
+}
+
+func (n *ShardingNumbers) Store(data Data) {
+	n.data.Store(&data)
+}
+
+func (n *ShardingNumbers) GetShardingNumber(fileName, domainName, bizName string) int64 {
+	return n.data.Load().GetShardingNumber(fileName, domainName, bizName)
+}
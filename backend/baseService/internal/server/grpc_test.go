@@ -0,0 +1,54 @@
+package server_test
+
+import (
+	"context"
+	"testing"
+
+	"github.com/cloudzenith/DouTok/backend/baseService/api"
+	"github.com/cloudzenith/DouTok/backend/baseService/internal/conf"
+	"github.com/cloudzenith/DouTok/backend/baseService/internal/infrastructure/dal/models"
+	"github.com/cloudzenith/DouTok/backend/baseService/internal/infrastructure/dal/query"
+	"github.com/cloudzenith/DouTok/backend/baseService/internal/infrastructure/utils"
+	"github.com/cloudzenith/DouTok/backend/baseService/internal/server"
+	"github.com/cloudzenith/DouTok/backend/gopkgs/components/mysqlx"
+	"github.com/cloudzenith/DouTok/backend/gopkgs/launcher/testkit"
+	"github.com/go-kratos/kratos/v2/transport/grpc"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func newTestKit(t *testing.T) *testkit.Kit {
+	k := testkit.New(t,
+		testkit.WithConfigFile("../../configs/config.yaml"),
+		testkit.WithConfigValue(&conf.Config{}),
+		testkit.WithGrpcServer(func(configValue interface{}) *grpc.Server {
+			cfg := configValue.(*conf.Config)
+			utils.InitDefaultSnowflakeNode(cfg.Snowflake.Node)
+			query.SetDefault(mysqlx.GetDBClient(context.Background()))
+			// the file tables are created with the mysql only CREATE TABLE ... LIKE, the flows here don't need them
+			return server.NewGRPCServer(cfg, server.WithFileTableShardingConfig(conf.NewShardingNumbers(cfg.Data)))
+		}),
+	)
+	require.NoError(t, k.DB().AutoMigrate(&models.Account{}))
+
+	return k
+}
+
+func TestGRPCServer_RegisterAndLogin(t *testing.T) {
+	k := newTestKit(t)
+	ctx := context.Background()
+	accounts := api.NewAccountServiceClient(k.GrpcConn())
+
+	registered, err := accounts.Register(ctx, &api.RegisterRequest{Mobile: "13800000000", Password: "doutok123"})
+	require.NoError(t, err)
+	assert.NotZero(t, registered.AccountId)
+
+	checked, err := accounts.CheckAccount(ctx, &api.CheckAccountRequest{Mobile: "13800000000", Password: "doutok123"})
+	require.NoError(t, err)
+	assert.Equal(t, registered.AccountId, checked.AccountId)
+
+	checked, err = accounts.CheckAccount(ctx, &api.CheckAccountRequest{Mobile: "13800000000", Password: "doutok321"})
+	require.NoError(t, err)
+	assert.NotEqual(t, int32(0), checked.Meta.BizCode)
+	assert.Zero(t, checked.AccountId)
+}
@@ -2,9 +2,11 @@ package server
 
 import (
 	"context"
+	"github.com/cloudzenith/DouTok/backend/baseService/internal/conf"
 	"github.com/cloudzenith/DouTok/backend/baseService/internal/server/warmup"
 	"github.com/cloudzenith/DouTok/backend/gopkgs/components/miniox"
 	"github.com/cloudzenith/DouTok/backend/gopkgs/components/mysqlx"
+	"github.com/go-kratos/kratos/v2/log"
 )
 
 func warmUp(params *Params) {
@@ -12,3 +14,17 @@ func warmUp(params *Params) {
 	warmup.CheckAndCreateMinioBucket(miniox.GetClient(context.Background()), params.dbShardingTablesConfig)
 	warmup.InitMinioPublicDirectoryV2()
 }
+
+// ReloadFileTableSharding creates the file tables of the sharding numbers of data and then switches numbers to them,
+// numbers are kept when a table can't be created
+func ReloadFileTableSharding(numbers *conf.ShardingNumbers, data conf.Data) {
+	defer func() {
+		if r := recover(); r != nil {
+			log.Errorf("rejected file table sharding change: %v", r)
+		}
+	}()
+
+	warmup.CheckAndCreateFileRepoTables(mysqlx.GetDBClient(context.Background()), data, data.DbShardingTables)
+	numbers.Store(data)
+	log.Infof("reloaded file table sharding numbers")
+}
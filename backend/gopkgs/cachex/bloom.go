@@ -0,0 +1,383 @@
+package cachex
+
+import (
+	"context"
+	"fmt"
+	"hash/fnv"
+	"math"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/cloudzenith/DouTok/backend/gopkgs/gofer"
+	"github.com/go-kratos/kratos/v2/log"
+	"github.com/redis/go-redis/v9"
+	"github.com/samber/lo"
+	"gorm.io/gorm"
+)
+
+// ErrNotFoundBloomFilter is returned for keys the bloom filter of a cache knows not to exist,
+// it wraps ErrNotFoundBarrier so both are handled the same way
+var ErrNotFoundBloomFilter = fmt.Errorf("%w: rejected by bloom filter", ErrNotFoundBarrier)
+
+// BloomSource feeds all existing keys to add, it is used to build a bloom filter in bulk
+type BloomSource func(ctx context.Context, add func(keys ...string) error) error
+
+// BloomFilter guards a cache against keys which do not exist. MayContain never reports false for an added key,
+// keys created after the filter was built should be added with Add. A filter which was never built lets all
+// keys through, Rebuild should be called once at startup.
+type BloomFilter interface {
+	Add(ctx context.Context, keys ...string) error
+	MayContain(ctx context.Context, keys ...string) ([]bool, error)
+	Rebuild(ctx context.Context, source BloomSource) error
+}
+
+// rejectedByBloom reports the keys which the bloom filter of the cache knows not to exist,
+// nothing is rejected when the cache has no filter or the filter fails
+func (c *Cache[T]) rejectedByBloom(ctx context.Context, keys ...string) []bool {
+	rejected := make([]bool, len(keys))
+	if c.bloom == nil {
+		return rejected
+	}
+
+	contained, err := c.bloom.MayContain(ctx, keys...)
+	if err != nil {
+		log.Context(ctx).Warnf("bloom filter check failed: %v", err)
+		return rejected
+	}
+
+	for i := range keys {
+		rejected[i] = !contained[i]
+	}
+
+	c.metrics.hit(tierBloom, lo.Count(rejected, true))
+	return rejected
+}
+
+// bloomParams returns the number of bits and hash functions for n keys with false positive rate p
+func bloomParams(n uint64, p float64) (m uint64, k int) {
+	m = uint64(math.Ceil(-float64(n) * math.Log(p) / (math.Ln2 * math.Ln2)))
+	k = int(math.Max(1, math.Round(float64(m)/float64(n)*math.Ln2)))
+	return m, k
+}
+
+// bloomPositions returns the k bit positions of key using double hashing
+func bloomPositions(key string, m uint64, k int) []uint64 {
+	h1 := fnv.New64a()
+	_, _ = h1.Write([]byte(key))
+	h2 := fnv.New64()
+	_, _ = h2.Write([]byte(key))
+
+	a, b := h1.Sum64(), h2.Sum64()|1
+	positions := make([]uint64, k)
+	for i := range positions {
+		positions[i] = (a + uint64(i)*b) % m
+	}
+
+	return positions
+}
+
+// bloomAddScript sets the bits of the live filter, and of the filter being rebuilt if any so the rebuild keeps them.
+// A filter which does not exist is not created, it would reject all the keys which were not added.
+var bloomAddScript = redis.NewScript(`
+local live = redis.call('EXISTS', KEYS[1]) == 1
+local building = redis.call('EXISTS', KEYS[2]) == 1
+for _, position in ipairs(ARGV) do
+	if live then
+		redis.call('SETBIT', KEYS[1], position, 1)
+	end
+	if building then
+		redis.call('SETBIT', KEYS[2], position, 1)
+	end
+end
+return 0
+`)
+
+// RedisBloomFilter keeps its bits in a redis string shared by all processes, it lets all keys through while
+// the string does not exist, such as before the first build or after it expired
+type RedisBloomFilter struct {
+	client *redis.Client
+	key    string
+	m      uint64
+	k      int
+}
+
+// NewRedisBloomFilter creates a bloom filter sized for expected keys with a false positive rate of fpRate
+func NewRedisBloomFilter(client *redis.Client, key string, expected uint64, fpRate float64) *RedisBloomFilter {
+	m, k := bloomParams(expected, fpRate)
+	return &RedisBloomFilter{
+		client: client,
+		key:    key,
+		m:      m,
+		k:      k,
+	}
+}
+
+// buildingKey is where Rebuild builds the new filter, the hash tag keeps it in the slot of the live one
+func (f *RedisBloomFilter) buildingKey() string {
+	return "{" + f.key + "}:building"
+}
+
+func (f *RedisBloomFilter) add(ctx context.Context, key string, keys ...string) error {
+	pipe := f.client.Pipeline()
+	for _, k := range keys {
+		for _, position := range bloomPositions(k, f.m, f.k) {
+			pipe.SetBit(ctx, key, int64(position), 1)
+		}
+	}
+
+	_, err := pipe.Exec(ctx)
+	return err
+}
+
+func (f *RedisBloomFilter) Add(ctx context.Context, keys ...string) error {
+	if len(keys) == 0 {
+		return nil
+	}
+
+	positions := make([]interface{}, 0, len(keys)*f.k)
+	for _, key := range keys {
+		for _, position := range bloomPositions(key, f.m, f.k) {
+			positions = append(positions, position)
+		}
+	}
+
+	return bloomAddScript.Run(ctx, f.client, []string{f.key, f.buildingKey()}, positions...).Err()
+}
+
+func (f *RedisBloomFilter) MayContain(ctx context.Context, keys ...string) ([]bool, error) {
+	pipe := f.client.Pipeline()
+	exists := pipe.Exists(ctx, f.key)
+	cmds := make([][]*redis.IntCmd, len(keys))
+	for i, key := range keys {
+		for _, position := range bloomPositions(key, f.m, f.k) {
+			cmds[i] = append(cmds[i], pipe.GetBit(ctx, f.key, int64(position)))
+		}
+	}
+
+	if _, err := pipe.Exec(ctx); err != nil {
+		return nil, err
+	}
+
+	result := make([]bool, len(keys))
+	for i := range keys {
+		result[i] = true
+		if exists.Val() == 0 {
+			continue
+		}
+
+		for _, cmd := range cmds[i] {
+			if cmd.Val() == 0 {
+				result[i] = false
+				break
+			}
+		}
+	}
+
+	return result, nil
+}
+
+// Rebuild builds the filter from source aside and swaps it in, so readers never see a partial filter.
+// Add also sets the bits of the filter being built, so keys added while rebuilding are kept and the keys
+// which are gone from source are purged.
+func (f *RedisBloomFilter) Rebuild(ctx context.Context, source BloomSource) error {
+	buildingKey := f.buildingKey()
+	if err := f.client.Del(ctx, buildingKey).Err(); err != nil {
+		return err
+	}
+
+	// make sure the building key exists even if source is empty
+	if err := f.client.SetBit(ctx, buildingKey, int64(f.m-1), 0).Err(); err != nil {
+		return err
+	}
+
+	err := source(ctx, func(keys ...string) error {
+		return f.add(ctx, buildingKey, keys...)
+	})
+	if err != nil {
+		// Add stops writing to the building key once it is gone
+		_ = f.client.Del(ctx, buildingKey).Err()
+		return err
+	}
+
+	return f.client.Rename(ctx, buildingKey, f.key).Err()
+}
+
+// LocalBloomFilter keeps its bits in memory, it should be rebuilt periodically to learn the keys
+// added by other processes. It lets all keys through until it is built for the first time.
+type LocalBloomFilter struct {
+	bits  atomic.Pointer[[]uint64]
+	built atomic.Bool
+	m     uint64
+	k     int
+
+	// mu is held for reading by Add and for writing by the swap of Rebuild, pending collects the keys
+	// added while a rebuild runs, it is nil otherwise
+	mu        sync.RWMutex
+	pendingMu sync.Mutex
+	pending   []string
+	rebuildMu sync.Mutex
+
+	stopOnce sync.Once
+	stop     chan struct{}
+}
+
+func NewLocalBloomFilter(expected uint64, fpRate float64) *LocalBloomFilter {
+	m, k := bloomParams(expected, fpRate)
+	f := &LocalBloomFilter{
+		m:    m,
+		k:    k,
+		stop: make(chan struct{}),
+	}
+
+	bits := make([]uint64, (m+63)/64)
+	f.bits.Store(&bits)
+	return f
+}
+
+func (f *LocalBloomFilter) add(bits []uint64, keys ...string) {
+	for _, key := range keys {
+		for _, position := range bloomPositions(key, f.m, f.k) {
+			atomicOr(&bits[position/64], 1<<(position%64))
+		}
+	}
+}
+
+func atomicOr(addr *uint64, mask uint64) {
+	for {
+		old := atomic.LoadUint64(addr)
+		if old&mask != 0 || atomic.CompareAndSwapUint64(addr, old, old|mask) {
+			return
+		}
+	}
+}
+
+func (f *LocalBloomFilter) Add(ctx context.Context, keys ...string) error {
+	f.mu.RLock()
+	defer f.mu.RUnlock()
+
+	f.add(*f.bits.Load(), keys...)
+
+	f.pendingMu.Lock()
+	if f.pending != nil {
+		f.pending = append(f.pending, keys...)
+	}
+	f.pendingMu.Unlock()
+
+	return nil
+}
+
+func (f *LocalBloomFilter) MayContain(ctx context.Context, keys ...string) ([]bool, error) {
+	bits := *f.bits.Load()
+	result := make([]bool, len(keys))
+	for i, key := range keys {
+		result[i] = true
+		if !f.built.Load() {
+			continue
+		}
+
+		for _, position := range bloomPositions(key, f.m, f.k) {
+			if atomic.LoadUint64(&bits[position/64])&(1<<(position%64)) == 0 {
+				result[i] = false
+				break
+			}
+		}
+	}
+
+	return result, nil
+}
+
+// Rebuild builds the filter from source aside and swaps it in. The keys added while rebuilding are added
+// to the new bits before the swap so they are kept, the keys which are gone from source are purged.
+func (f *LocalBloomFilter) Rebuild(ctx context.Context, source BloomSource) error {
+	f.rebuildMu.Lock()
+	defer f.rebuildMu.Unlock()
+
+	f.pendingMu.Lock()
+	f.pending = []string{}
+	f.pendingMu.Unlock()
+
+	bits := make([]uint64, (f.m+63)/64)
+	err := source(ctx, func(keys ...string) error {
+		f.add(bits, keys...)
+		return nil
+	})
+
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	f.pendingMu.Lock()
+	pending := f.pending
+	f.pending = nil
+	f.pendingMu.Unlock()
+
+	if err != nil {
+		return err
+	}
+
+	f.add(bits, pending...)
+	f.bits.Store(&bits)
+	f.built.Store(true)
+	return nil
+}
+
+// StartRebuild builds the filter from source right away and then rebuilds it every interval in background
+// until Stop is called
+func (f *LocalBloomFilter) StartRebuild(interval time.Duration, source BloomSource) {
+	gofer.Go(func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+
+		for {
+			if err := f.Rebuild(context.Background(), source); err != nil {
+				log.Errorf("failed to rebuild bloom filter: %v", err)
+			}
+
+			select {
+			case <-f.stop:
+				return
+			case <-ticker.C:
+			}
+		}
+	})
+}
+
+func (f *LocalBloomFilter) Stop() {
+	f.stopOnce.Do(func() {
+		close(f.stop)
+	})
+}
+
+// TableBloomSource reads the ids of a table in batches and turns them into cache keys with keyPattern,
+// such as "video:%d", it is used to seed a bloom filter at startup
+func TableBloomSource(db *gorm.DB, table, idColumn, keyPattern string, batchSize int) BloomSource {
+	return func(ctx context.Context, add func(keys ...string) error) error {
+		var lastId int64
+		for {
+			var ids []int64
+			err := db.WithContext(ctx).Table(table).
+				Where(idColumn+" > ?", lastId).
+				Order(idColumn).
+				Limit(batchSize).
+				Pluck(idColumn, &ids).Error
+			if err != nil {
+				return err
+			}
+
+			if len(ids) == 0 {
+				return nil
+			}
+
+			keys := make([]string, len(ids))
+			for i, id := range ids {
+				keys[i] = fmt.Sprintf(keyPattern, id)
+			}
+
+			if err := add(keys...); err != nil {
+				return err
+			}
+
+			lastId = ids[len(ids)-1]
+		}
+	}
+}
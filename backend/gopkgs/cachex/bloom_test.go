@@ -0,0 +1,212 @@
+package cachex
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/glebarez/sqlite"
+	"github.com/samber/lo"
+	"github.com/stretchr/testify/assert"
+	"gorm.io/gorm"
+)
+
+func sliceSource(keys ...string) BloomSource {
+	return func(ctx context.Context, add func(keys ...string) error) error {
+		return add(keys...)
+	}
+}
+
+func testBloomFilter(t *testing.T, filter BloomFilter) {
+	ctx := context.Background()
+
+	// a filter which was never built lets all keys through
+	contained, err := filter.MayContain(ctx, "video:1", "video:3")
+	assert.Nil(t, err)
+	assert.Equal(t, []bool{true, true}, contained)
+
+	assert.Nil(t, filter.Rebuild(ctx, sliceSource("video:1")))
+	assert.Nil(t, filter.Add(ctx, "video:2"))
+	contained, err = filter.MayContain(ctx, "video:1", "video:2", "video:3")
+	assert.Nil(t, err)
+	assert.Equal(t, []bool{true, true, false}, contained)
+
+	// the keys which are gone from the source are purged
+	assert.Nil(t, filter.Rebuild(ctx, sliceSource("video:3")))
+	contained, err = filter.MayContain(ctx, "video:1", "video:3", "video:4")
+	assert.Nil(t, err)
+	assert.Equal(t, []bool{false, true, false}, contained)
+}
+
+// testBloomFilterAddDuringRebuild adds keys while the new filter is built, none of them may be lost by the swap.
+// The source yields the keys added before the rebuild started, as a table would.
+func testBloomFilterAddDuringRebuild(t *testing.T, filter BloomFilter) {
+	ctx := context.Background()
+	var added []string
+	source := func(ctx context.Context, add func(keys ...string) error) error {
+		if len(added) > 0 {
+			if err := add(added...); err != nil {
+				return err
+			}
+		}
+
+		for i := 0; i < 100; i++ {
+			if err := add(fmt.Sprintf("user:%d", i)); err != nil {
+				return err
+			}
+
+			key := fmt.Sprintf("video:%d", len(added))
+			if err := filter.Add(ctx, key); err != nil {
+				return err
+			}
+			added = append(added, key)
+		}
+		return nil
+	}
+
+	for i := 0; i < 3; i++ {
+		assert.Nil(t, filter.Rebuild(ctx, source))
+	}
+
+	contained, err := filter.MayContain(ctx, added...)
+	assert.Nil(t, err)
+	assert.Equal(t, len(added), lo.Count(contained, true))
+}
+
+func TestRedisBloomFilter(t *testing.T) {
+	_, client := newTestClient(t)
+	testBloomFilter(t, NewRedisBloomFilter(client, "bloom:video", 1000, 0.01))
+	testBloomFilterAddDuringRebuild(t, NewRedisBloomFilter(client, "bloom:user", 10000, 0.01))
+}
+
+func TestLocalBloomFilter(t *testing.T) {
+	filter := NewLocalBloomFilter(1000, 0.01)
+	defer filter.Stop()
+	testBloomFilter(t, filter)
+}
+
+func TestLocalBloomFilter_AddDuringRebuild(t *testing.T) {
+	filter := NewLocalBloomFilter(100000, 0.01)
+	defer filter.Stop()
+	testBloomFilterAddDuringRebuild(t, filter)
+}
+
+func TestLocalBloomFilter_StartRebuild(t *testing.T) {
+	filter := NewLocalBloomFilter(1000, 0.01)
+	defer filter.Stop()
+
+	// the first build does not wait for the interval
+	filter.StartRebuild(time.Hour, sliceSource("video:1"))
+	assert.Eventually(t, func() bool {
+		contained, _ := filter.MayContain(context.Background(), "video:2")
+		return !contained[0]
+	}, time.Second, 10*time.Millisecond)
+
+	contained, err := filter.MayContain(context.Background(), "video:1")
+	assert.Nil(t, err)
+	assert.True(t, contained[0])
+}
+
+func TestRedisBloomFilter_Expired(t *testing.T) {
+	s, client := newTestClient(t)
+	filter := NewRedisBloomFilter(client, "bloom:video", 1000, 0.01)
+	assert.Nil(t, filter.Rebuild(context.Background(), sliceSource("video:1")))
+
+	// adding to an expired filter does not bring back a filter rejecting the other keys
+	s.Del("bloom:video")
+	assert.Nil(t, filter.Add(context.Background(), "video:2"))
+	contained, err := filter.MayContain(context.Background(), "video:1", "video:3")
+	assert.Nil(t, err)
+	assert.Equal(t, []bool{true, true}, contained)
+}
+
+func TestLocalBloomFilter_FalsePositiveRate(t *testing.T) {
+	filter := NewLocalBloomFilter(10000, 0.01)
+	defer filter.Stop()
+
+	ctx := context.Background()
+	assert.Nil(t, filter.Rebuild(ctx, sliceSource()))
+	for i := 0; i < 10000; i++ {
+		_ = filter.Add(ctx, fmt.Sprintf("video:%d", i))
+	}
+
+	var falsePositives int
+	for i := 10000; i < 20000; i++ {
+		contained, _ := filter.MayContain(ctx, fmt.Sprintf("video:%d", i))
+		if contained[0] {
+			falsePositives++
+		}
+	}
+
+	assert.Less(t, falsePositives, 300)
+}
+
+func TestCache_FetchBloomFilter(t *testing.T) {
+	s, client := newTestClient(t)
+	filter := NewRedisBloomFilter(client, "bloom:video", 1000, 0.01)
+	assert.Nil(t, filter.Rebuild(context.Background(), sliceSource("video:1")))
+
+	cache := NewCache[*testValue](
+		WithRedisClient[*testValue](client),
+		WithUseBarrier[*testValue](true),
+		WithBloomFilter[*testValue](filter),
+	)
+
+	var calls int
+	_, err := cache.Fetch(context.Background(), "video:2", loader("b", &calls))
+	assert.True(t, errors.Is(err, ErrNotFoundBloomFilter))
+	assert.True(t, errors.Is(err, ErrNotFoundBarrier))
+	assert.Equal(t, 0, calls)
+	assert.False(t, s.Exists("video:2"))
+
+	value, err := cache.Fetch(context.Background(), "video:1", loader("a", &calls))
+	assert.Nil(t, err)
+	assert.Equal(t, "a", value.Name)
+	assert.Equal(t, 1, calls)
+}
+
+func TestCache_MFetchBloomFilter(t *testing.T) {
+	_, client := newTestClient(t)
+	filter := NewLocalBloomFilter(1000, 0.01)
+	defer filter.Stop()
+	assert.Nil(t, filter.Rebuild(context.Background(), sliceSource("video:1")))
+
+	cache := NewCache[*testValue](
+		WithRedisClient[*testValue](client),
+		WithBloomFilter[*testValue](filter),
+	)
+
+	var loaded []string
+	values, err := cache.MFetch(context.Background(), []string{"video:1", "video:2"},
+		func(ctx context.Context, missing []string) (map[string]*testValue, error) {
+			loaded = append(loaded, missing...)
+			return map[string]*testValue{"video:1": {Name: "a"}}, nil
+		})
+	assert.Nil(t, err)
+	assert.Equal(t, []string{"video:1"}, loaded)
+	assert.Len(t, values, 1)
+	assert.Equal(t, "a", values["video:1"].Name)
+}
+
+func TestTableBloomSource(t *testing.T) {
+	db, err := gorm.Open(sqlite.Open(":memory:"), &gorm.Config{})
+	assert.Nil(t, err)
+
+	type Video struct {
+		Id int64
+	}
+	assert.Nil(t, db.AutoMigrate(&Video{}))
+	for i := int64(1); i <= 5; i++ {
+		assert.Nil(t, db.Create(&Video{Id: i}).Error)
+	}
+
+	var keys []string
+	err = TableBloomSource(db, "videos", "id", "video:%d", 2)(context.Background(), func(batch ...string) error {
+		keys = append(keys, batch...)
+		return nil
+	})
+	assert.Nil(t, err)
+	assert.Equal(t, []string{"video:1", "video:2", "video:3", "video:4", "video:5"}, keys)
+}
@@ -2,9 +2,9 @@ package cachex
 
 import (
 	"context"
-	"encoding/json"
 	"errors"
 	"github.com/jellydator/ttlcache/v3"
+	"sync"
 	"time"
 
 	"github.com/cloudzenith/DouTok/backend/gopkgs/gofer"
@@ -23,17 +23,31 @@ var (
 )
 
 type Cache[T any] struct {
-	useBarrier  bool
-	useLocal    bool
-	useFallback bool
-	localTTL    *time.Duration
-	redisTTL    *time.Duration
-	client      *redis.Client
-	localCache  *ttlcache.Cache[string, []byte]
+	useBarrier        bool
+	useLocal          bool
+	useFallback       bool
+	localTTL          *time.Duration
+	redisTTL          *time.Duration
+	client            *redis.Client
+	localCache        *ttlcache.Cache[string, []byte]
+	invalidateChannel string
+	pubsub            *redis.PubSub
+	flight            *batchFlight
+	softTTL           *time.Duration
+	ttlJitter         time.Duration
+	refreshing        sync.Map
+	codec             Codec[T]
+	metrics           *cacheMetrics
+	bloom             BloomFilter
+	hotKeys           *HotKeyDetector
+	hotCache          *ttlcache.Cache[string, []byte]
+	promoteTTL        time.Duration
 }
 
 func NewCache[T any](options ...CacheOption[T]) *Cache[T] {
-	cache := &Cache[T]{}
+	cache := &Cache[T]{
+		flight: newBatchFlight(),
+	}
 
 	for _, option := range options {
 		option(cache)
@@ -51,82 +65,189 @@ func NewCache[T any](options ...CacheOption[T]) *Cache[T] {
 		cache.redisTTL = &DefaultTTL
 	}
 
+	if cache.codec == nil {
+		cache.codec = JSONCodec[T]{}
+	}
+
+	if cache.softTTL != nil && *cache.softTTL >= *cache.redisTTL {
+		panic("soft ttl of cache should be less than redis ttl")
+	}
+
 	if cache.useLocal {
 		cache.localCache = ttlcache.New[string, []byte]()
 	}
 
+	if cache.hotKeys != nil {
+		cache.hotCache = newHotCache()
+		if cache.promoteTTL <= 0 {
+			cache.promoteTTL = defaultPromoteTTL
+		}
+	}
+
+	if (cache.useLocal || cache.hotCache != nil) && cache.invalidateChannel != "" {
+		cache.subscribeInvalidation()
+	}
+
 	return cache
 }
 
-func (c *Cache[T]) fetchSet(ctx context.Context, key string, fetch func(ctx context.Context) (T, error)) (t T, err error) {
+// subscribeInvalidation listens on the invalidate channel and drops the local copy of every key
+// published by any process, so replicas do not serve stale local data until the local TTL expires.
+func (c *Cache[T]) subscribeInvalidation() {
+	ctx := context.Background()
+	c.pubsub = c.client.Subscribe(ctx, c.invalidateChannel)
+
+	// wait for the subscription to be confirmed, otherwise invalidations published right after
+	// NewCache returns could be missed
+	if _, err := c.pubsub.Receive(ctx); err != nil {
+		log.Context(ctx).Warnf("subscribe invalidate channel %s failed: %v", c.invalidateChannel, err)
+	}
+
+	ch := c.pubsub.Channel()
+	gofer.Go(func() {
+		for msg := range ch {
+			c.dropLocal(msg.Payload)
+		}
+	})
+}
+
+func (c *Cache[T]) callFetch(ctx context.Context, fetch func(ctx context.Context) (T, error)) (T, error) {
+	start := time.Now()
 	value, err := fetch(ctx)
+	c.metrics.observe(tierLoader, start)
+	c.metrics.loaderCall(err)
+	return value, err
+}
+
+func (c *Cache[T]) fetchSet(
+	ctx context.Context,
+	key string,
+	fetch func(ctx context.Context) (T, error),
+	opts *fetchOptions[T],
+) ([]byte, error) {
+	value, err := c.callFetch(ctx, fetch)
 	if err != nil {
 		if c.useBarrier {
-			_ = c.client.Set(ctx, key, NotFoundBarrier, *c.redisTTL).Err()
-			return t, ErrNotFoundBarrier
+			_ = c.client.Set(ctx, key, NotFoundBarrier, c.nextRedisTTL()).Err()
+			return nil, ErrNotFoundBarrier
 		}
-		return t, err
+		return nil, err
 	}
 
-	val, err := json.Marshal(value)
+	return c.set(ctx, key, value, opts)
+}
+
+func (c *Cache[T]) set(ctx context.Context, key string, value T, opts *fetchOptions[T]) ([]byte, error) {
+	val, err := c.codec.Marshal(value)
 	if err != nil {
-		return t, err
+		return nil, err
 	}
 
-	ttl := c.redisTTL
-	err = c.client.Set(ctx, key, val, *ttl).Err()
-	if err != nil {
+	pipe := c.client.Pipeline()
+	pipe.Set(ctx, key, val, c.nextRedisTTL())
+	c.addTags(ctx, pipe, key, opts.tags(key, value))
+	if _, err := pipe.Exec(ctx); err != nil {
 		log.Context(ctx).Warnf("fetchSet write redis failed: %v", err)
 	}
 
-	if c.useLocal {
-		if *c.localTTL > 0 {
-			ttl = c.localTTL
-		}
+	c.setLocal(key, val)
+	return val, nil
+}
+
+func (c *Cache[T]) setLocal(key string, val []byte) {
+	if !c.useLocal {
+		return
+	}
 
-		c.localCache.Set(key, val, *ttl)
+	ttl := c.redisTTL
+	if *c.localTTL > 0 {
+		ttl = c.localTTL
 	}
 
-	return value, nil
+	c.localCache.Set(key, val, *ttl)
 }
 
-func (c *Cache[T]) getCache(ctx context.Context, key string) ([]byte, error) {
+// dropLocal removes the copies of key held in process
+func (c *Cache[T]) dropLocal(key string) {
 	if c.useLocal {
+		c.localCache.Delete(key)
+	}
+
+	if c.hotCache != nil {
+		c.hotCache.Delete(key)
+	}
+}
+
+// getCache returns the cached value of key, stale reports whether the value is past its soft TTL
+func (c *Cache[T]) getCache(ctx context.Context, key string) (val []byte, stale bool, err error) {
+	if c.useLocal {
+		start := time.Now()
 		item := c.localCache.Get(key)
+		c.metrics.observe(tierLocal, start)
 		if item != nil {
-			return item.Value(), nil
+			c.metrics.hit(tierLocal, 1)
+			return item.Value(), false, nil
 		}
 	}
 
-	result, err := c.client.Get(ctx, key).Result()
+	start := time.Now()
+	var result string
+	if c.softTTL == nil {
+		result, err = c.client.Get(ctx, key).Result()
+	} else {
+		result, stale, err = c.getWithStale(ctx, key)
+	}
+	c.metrics.observe(tierRedis, start)
 	if err != nil {
-		return nil, err
+		if errors.Is(err, redis.Nil) {
+			c.metrics.miss(1)
+		}
+		return nil, false, err
 	}
 
 	if result == NotFoundBarrier {
-		return nil, ErrNotFoundBarrier
+		c.metrics.hit(tierBarrier, 1)
+		return nil, false, ErrNotFoundBarrier
 	}
 
-	return []byte(result), nil
+	c.metrics.hit(tierRedis, 1)
+	c.setLocal(key, []byte(result))
+	return []byte(result), stale, nil
 }
 
 func (c *Cache[T]) Fetch(
 	ctx context.Context,
 	key string,
 	fetch func(ctx context.Context) (T, error),
+	options ...FetchOption[T],
 ) (t T, err error) {
+	if c.rejectedByBloom(ctx, key)[0] {
+		return t, ErrNotFoundBloomFilter
+	}
+
+	hot := len(c.recordHot(key)) > 0
+	if val, ok := c.getHot(key); ok {
+		err = c.codec.Unmarshal(val, &t)
+		return t, err
+	}
+
+	opts := newFetchOptions(options...)
 	value, err, _ := gofer.SingleFlightDo(key, func() (any, error) {
 		if c.useFallback {
-			val, err := c.fetchSet(ctx, key, fetch)
+			val, err := c.fetchSet(ctx, key, fetch, opts)
 			if err == nil {
 				return val, nil
 			}
 
-			return c.getCache(ctx, key)
+			val, _, err = c.getCache(ctx, key)
+			return val, err
 		}
 
-		val, err := c.getCache(ctx, key)
+		val, stale, err := c.getCache(ctx, key)
 		if err == nil {
+			if stale {
+				c.refresh(ctx, key, fetch, opts)
+			}
 			return val, nil
 		}
 
@@ -134,7 +255,7 @@ func (c *Cache[T]) Fetch(
 			return nil, err
 		}
 
-		return c.fetchSet(ctx, key, fetch)
+		return c.fetchSet(ctx, key, fetch, opts)
 	})
 
 	gofer.SingleFlightForget(key)
@@ -142,10 +263,56 @@ func (c *Cache[T]) Fetch(
 		return t, err
 	}
 
-	err = json.Unmarshal(value.([]byte), t)
+	if hot {
+		c.promote(ctx, key, value.([]byte))
+	}
+
+	err = c.codec.Unmarshal(value.([]byte), &t)
 	if err != nil {
 		return t, err
 	}
 
 	return t, nil
 }
+
+// Delete removes keys from redis and drops their local copies in every process
+func (c *Cache[T]) Delete(ctx context.Context, keys ...string) error {
+	if len(keys) == 0 {
+		return nil
+	}
+
+	if err := c.client.Del(ctx, keys...).Err(); err != nil {
+		return err
+	}
+
+	return c.Invalidate(ctx, keys...)
+}
+
+// Invalidate drops the local copies of keys in this process and, when an invalidate channel is set,
+// broadcasts them so that other processes drop theirs too. Values in redis are left untouched.
+func (c *Cache[T]) Invalidate(ctx context.Context, keys ...string) error {
+	for _, key := range keys {
+		c.dropLocal(key)
+	}
+
+	if c.invalidateChannel == "" || len(keys) == 0 {
+		return nil
+	}
+
+	pipe := c.client.Pipeline()
+	for _, key := range keys {
+		pipe.Publish(ctx, c.invalidateChannel, key)
+	}
+
+	_, err := pipe.Exec(ctx)
+	return err
+}
+
+// Close stops listening on the invalidate channel
+func (c *Cache[T]) Close() error {
+	if c.pubsub == nil {
+		return nil
+	}
+
+	return c.pubsub.Close()
+}
@@ -0,0 +1,354 @@
+package cachex
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/go-kratos/kratos/v2/log"
+	"github.com/samber/lo"
+)
+
+type flightCall struct {
+	done chan struct{}
+	val  []byte
+	err  error
+}
+
+// batchFlight is a per key singleflight for batch loads, a caller owns the keys nobody else is loading
+// and waits for the others
+type batchFlight struct {
+	mu    sync.Mutex
+	calls map[string]*flightCall
+}
+
+func newBatchFlight() *batchFlight {
+	return &batchFlight{
+		calls: make(map[string]*flightCall),
+	}
+}
+
+func (f *batchFlight) claim(keys []string) (owned, waiting map[string]*flightCall) {
+	owned = make(map[string]*flightCall)
+	waiting = make(map[string]*flightCall)
+
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	for _, key := range keys {
+		if call, ok := f.calls[key]; ok {
+			waiting[key] = call
+			continue
+		}
+
+		call := &flightCall{done: make(chan struct{})}
+		f.calls[key] = call
+		owned[key] = call
+	}
+
+	return owned, waiting
+}
+
+func (f *batchFlight) finish(key string, call *flightCall) {
+	f.mu.Lock()
+	delete(f.calls, key)
+	f.mu.Unlock()
+
+	close(call.done)
+}
+
+// MFetch is the batch version of Fetch. It looks keys up in the local cache, then in redis with a single MGET,
+// and calls batchLoader once for all keys still missing. Keys absent from the map returned by batchLoader
+// are treated as not found, they are omitted from the result and guarded by a barrier when barrier is used.
+func (c *Cache[T]) MFetch(
+	ctx context.Context,
+	keys []string,
+	batchLoader func(ctx context.Context, missing []string) (map[string]T, error),
+	options ...FetchOption[T],
+) (map[string]T, error) {
+	keys = lo.Uniq(keys)
+	rejected := c.rejectedByBloom(ctx, keys...)
+	keys = lo.Filter(keys, func(_ string, i int) bool {
+		return !rejected[i]
+	})
+	if len(keys) == 0 {
+		return map[string]T{}, nil
+	}
+
+	hot := c.recordHot(keys...)
+	promoted := make(map[string][]byte)
+	keys = lo.Filter(keys, func(key string, _ int) bool {
+		val, ok := c.getHot(key)
+		if ok {
+			promoted[key] = val
+		}
+		return !ok
+	})
+
+	opts := newFetchOptions(options...)
+
+	var (
+		raw = map[string][]byte{}
+		err error
+	)
+	if len(keys) > 0 && c.useFallback {
+		raw, err = c.mFetchWithFallback(ctx, keys, batchLoader, opts)
+	} else if len(keys) > 0 {
+		raw, err = c.mFetch(ctx, keys, batchLoader, opts)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	for _, key := range hot {
+		if val, ok := raw[key]; ok {
+			c.promote(ctx, key, val)
+		}
+	}
+
+	for key, val := range promoted {
+		raw[key] = val
+	}
+
+	result := make(map[string]T, len(raw))
+	for key, val := range raw {
+		var t T
+		if err := c.codec.Unmarshal(val, &t); err != nil {
+			return nil, err
+		}
+
+		result[key] = t
+	}
+
+	return result, nil
+}
+
+func (c *Cache[T]) mFetch(
+	ctx context.Context,
+	keys []string,
+	batchLoader func(ctx context.Context, missing []string) (map[string]T, error),
+	opts *fetchOptions[T],
+) (map[string][]byte, error) {
+	raw := make(map[string][]byte, len(keys))
+
+	missing, stale, err := c.mGetCache(ctx, keys, raw)
+	if err != nil {
+		log.Context(ctx).Warnf("mFetch read redis failed: %v", err)
+	}
+
+	if len(stale) > 0 {
+		c.mRefresh(ctx, stale, batchLoader, opts)
+	}
+
+	if len(missing) == 0 {
+		return raw, nil
+	}
+
+	loaded, err := c.mLoad(ctx, missing, batchLoader, opts)
+	if err != nil {
+		return nil, err
+	}
+
+	for key, val := range loaded {
+		raw[key] = val
+	}
+
+	return raw, nil
+}
+
+func (c *Cache[T]) mFetchWithFallback(
+	ctx context.Context,
+	keys []string,
+	batchLoader func(ctx context.Context, missing []string) (map[string]T, error),
+	opts *fetchOptions[T],
+) (map[string][]byte, error) {
+	loaded, err := c.mLoad(ctx, keys, batchLoader, opts)
+	if err == nil {
+		return loaded, nil
+	}
+
+	raw := make(map[string][]byte, len(keys))
+	if _, _, cacheErr := c.mGetCache(ctx, keys, raw); cacheErr != nil {
+		return nil, err
+	}
+
+	return raw, nil
+}
+
+// mGetCache fills raw with the cached values of keys and returns the keys neither cached nor guarded by a barrier,
+// and the keys whose values are past their soft TTL
+func (c *Cache[T]) mGetCache(ctx context.Context, keys []string, raw map[string][]byte) (missing, stale []string, err error) {
+	remote := keys
+	if c.useLocal {
+		start := time.Now()
+		remote = make([]string, 0, len(keys))
+		for _, key := range keys {
+			if item := c.localCache.Get(key); item != nil {
+				raw[key] = item.Value()
+				continue
+			}
+
+			remote = append(remote, key)
+		}
+		c.metrics.observe(tierLocal, start)
+		c.metrics.hit(tierLocal, len(keys)-len(remote))
+	}
+
+	if len(remote) == 0 {
+		return nil, nil, nil
+	}
+
+	start := time.Now()
+	var values []any
+	var remaining []time.Duration
+	if c.softTTL == nil {
+		values, err = c.client.MGet(ctx, remote...).Result()
+	} else {
+		values, remaining, err = c.mGetWithStale(ctx, remote)
+	}
+	c.metrics.observe(tierRedis, start)
+	if err != nil {
+		return remote, nil, err
+	}
+
+	var barriers int
+	defer func() {
+		c.metrics.miss(len(missing))
+		c.metrics.hit(tierBarrier, barriers)
+		c.metrics.hit(tierRedis, len(remote)-len(missing)-barriers)
+	}()
+
+	for i, value := range values {
+		result, ok := value.(string)
+		if !ok {
+			missing = append(missing, remote[i])
+			continue
+		}
+
+		if result == NotFoundBarrier {
+			barriers++
+			continue
+		}
+
+		if remaining != nil && c.isStale(remaining[i]) {
+			stale = append(stale, remote[i])
+		}
+
+		c.setLocal(remote[i], []byte(result))
+		raw[remote[i]] = []byte(result)
+	}
+
+	return missing, stale, nil
+}
+
+// mLoad loads keys through batchLoader, keys which are being loaded by another caller are waited for instead
+func (c *Cache[T]) mLoad(
+	ctx context.Context,
+	keys []string,
+	batchLoader func(ctx context.Context, missing []string) (map[string]T, error),
+	opts *fetchOptions[T],
+) (map[string][]byte, error) {
+	owned, waiting := c.flight.claim(keys)
+
+	raw := make(map[string][]byte, len(keys))
+	if len(owned) > 0 {
+		loaded, err := c.mFetchSet(ctx, lo.Keys(owned), batchLoader, owned, opts)
+		if err != nil {
+			return nil, err
+		}
+
+		for key, val := range loaded {
+			raw[key] = val
+		}
+	}
+
+	for key, call := range waiting {
+		select {
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		case <-call.done:
+		}
+
+		if call.err != nil {
+			return nil, call.err
+		}
+
+		if call.val != nil {
+			raw[key] = call.val
+		}
+	}
+
+	return raw, nil
+}
+
+func (c *Cache[T]) mFetchSet(
+	ctx context.Context,
+	keys []string,
+	batchLoader func(ctx context.Context, missing []string) (map[string]T, error),
+	calls map[string]*flightCall,
+	opts *fetchOptions[T],
+) (raw map[string][]byte, err error) {
+	defer func() {
+		for key, call := range calls {
+			call.val = raw[key]
+			call.err = err
+			c.flight.finish(key, call)
+		}
+	}()
+
+	values, err := c.callBatchLoader(ctx, keys, batchLoader)
+	if err != nil {
+		return nil, err
+	}
+
+	return c.mSet(ctx, keys, values, opts)
+}
+
+func (c *Cache[T]) callBatchLoader(
+	ctx context.Context,
+	keys []string,
+	batchLoader func(ctx context.Context, missing []string) (map[string]T, error),
+) (map[string]T, error) {
+	start := time.Now()
+	values, err := batchLoader(ctx, keys)
+	c.metrics.observe(tierLoader, start)
+	c.metrics.loaderCall(err)
+	return values, err
+}
+
+// mSet writes values back to redis with a pipeline, keys absent from values are guarded by a barrier
+// when barrier is used
+func (c *Cache[T]) mSet(
+	ctx context.Context,
+	keys []string,
+	values map[string]T,
+	opts *fetchOptions[T],
+) (map[string][]byte, error) {
+	raw := make(map[string][]byte, len(values))
+	pipe := c.client.Pipeline()
+	for _, key := range keys {
+		value, ok := values[key]
+		if !ok {
+			if c.useBarrier {
+				pipe.Set(ctx, key, NotFoundBarrier, c.nextRedisTTL())
+			}
+			continue
+		}
+
+		val, err := c.codec.Marshal(value)
+		if err != nil {
+			return nil, err
+		}
+
+		pipe.Set(ctx, key, val, c.nextRedisTTL())
+		c.addTags(ctx, pipe, key, opts.tags(key, value))
+		c.setLocal(key, val)
+		raw[key] = val
+	}
+
+	if _, err := pipe.Exec(ctx); err != nil {
+		log.Context(ctx).Warnf("mSet write redis failed: %v", err)
+	}
+
+	return raw, nil
+}
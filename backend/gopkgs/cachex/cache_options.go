@@ -42,3 +42,98 @@ func WithRedisClient[T any](client *redis.Client) CacheOption[T] {
 		cache.client = client
 	}
 }
+
+// WithInvalidateChannel sets the redis pub/sub channel used to broadcast local cache invalidations.
+// All caches holding the same keys across processes should use the same channel.
+func WithInvalidateChannel[T any](channel string) CacheOption[T] {
+	return func(cache *Cache[T]) {
+		cache.invalidateChannel = channel
+	}
+}
+
+// WithSoftTTL enables stale-while-revalidate, a value older than softTTL but still in redis is returned
+// right away and refreshed in background. softTTL should be less than the redis TTL.
+func WithSoftTTL[T any](softTTL *time.Duration) CacheOption[T] {
+	return func(cache *Cache[T]) {
+		cache.softTTL = softTTL
+	}
+}
+
+// WithTTLJitter adds a random duration in [0, jitter) to the redis TTL of every write
+func WithTTLJitter[T any](jitter time.Duration) CacheOption[T] {
+	return func(cache *Cache[T]) {
+		cache.ttlJitter = jitter
+	}
+}
+
+// WithCodec sets the codec used to serialize values, JSONCodec is used by default
+func WithCodec[T any](codec Codec[T]) CacheOption[T] {
+	return func(cache *Cache[T]) {
+		cache.codec = codec
+	}
+}
+
+// WithMetrics enables prometheus metrics of the cache, name is used as the cache label and should be unique
+func WithMetrics[T any](name string) CacheOption[T] {
+	return func(cache *Cache[T]) {
+		cache.metrics = newCacheMetrics(name)
+	}
+}
+
+// WithBloomFilter guards the cache with filter, keys the filter rejects are reported as not found
+// without touching redis or the loader. Errors of the filter are ignored.
+func WithBloomFilter[T any](filter BloomFilter) CacheOption[T] {
+	return func(cache *Cache[T]) {
+		cache.bloom = filter
+	}
+}
+
+// WithHotKeyDetector promotes the keys detector reports as hot into an in-process cache for promoteTTL,
+// even when the local cache is not used. A promoteTTL which is not positive falls back to 10 seconds,
+// promoted keys always expire.
+func WithHotKeyDetector[T any](detector *HotKeyDetector, promoteTTL time.Duration) CacheOption[T] {
+	return func(cache *Cache[T]) {
+		cache.hotKeys = detector
+		cache.promoteTTL = promoteTTL
+	}
+}
+
+type fetchOptions[T any] struct {
+	taggers []func(key string, value T) []string
+}
+
+func newFetchOptions[T any](options ...FetchOption[T]) *fetchOptions[T] {
+	opts := &fetchOptions[T]{}
+	for _, option := range options {
+		option(opts)
+	}
+
+	return opts
+}
+
+func (o *fetchOptions[T]) tags(key string, value T) []string {
+	var tags []string
+	for _, tagger := range o.taggers {
+		tags = append(tags, tagger(key, value)...)
+	}
+
+	return tags
+}
+
+type FetchOption[T any] func(opts *fetchOptions[T])
+
+// WithTags attaches tags to the values written by a fetch, see Cache.InvalidateTag
+func WithTags[T any](tags ...string) FetchOption[T] {
+	return func(opts *fetchOptions[T]) {
+		opts.taggers = append(opts.taggers, func(string, T) []string {
+			return tags
+		})
+	}
+}
+
+// WithTagger attaches the tags computed from every loaded value, such as the author of a video
+func WithTagger[T any](tagger func(key string, value T) []string) FetchOption[T] {
+	return func(opts *fetchOptions[T]) {
+		opts.taggers = append(opts.taggers, tagger)
+	}
+}
@@ -0,0 +1,120 @@
+package cachex
+
+import (
+	"context"
+	"math/rand"
+	"time"
+
+	"github.com/cloudzenith/DouTok/backend/gopkgs/gofer"
+	"github.com/go-kratos/kratos/v2/log"
+	"github.com/redis/go-redis/v9"
+	"github.com/samber/lo"
+)
+
+// nextRedisTTL returns the redis TTL with a random jitter added, so that keys written together
+// do not expire together
+func (c *Cache[T]) nextRedisTTL() time.Duration {
+	if c.ttlJitter <= 0 {
+		return *c.redisTTL
+	}
+
+	return *c.redisTTL + time.Duration(rand.Int63n(int64(c.ttlJitter)))
+}
+
+// isStale reports whether a value with remaining redis TTL is past its soft TTL.
+// The age of a value is measured against the redis TTL, so the jitter added on write delays the soft TTL too.
+func (c *Cache[T]) isStale(remaining time.Duration) bool {
+	if c.softTTL == nil || remaining < 0 {
+		return false
+	}
+
+	return remaining <= *c.redisTTL-*c.softTTL
+}
+
+func (c *Cache[T]) getWithStale(ctx context.Context, key string) (string, bool, error) {
+	pipe := c.client.Pipeline()
+	get := pipe.Get(ctx, key)
+	ttl := pipe.PTTL(ctx, key)
+	if _, err := pipe.Exec(ctx); err != nil {
+		return "", false, err
+	}
+
+	return get.Val(), c.isStale(ttl.Val()), nil
+}
+
+func (c *Cache[T]) mGetWithStale(ctx context.Context, keys []string) ([]any, []time.Duration, error) {
+	pipe := c.client.Pipeline()
+	get := pipe.MGet(ctx, keys...)
+	ttls := lo.Map(keys, func(key string, _ int) *redis.DurationCmd {
+		return pipe.PTTL(ctx, key)
+	})
+	if _, err := pipe.Exec(ctx); err != nil {
+		return nil, nil, err
+	}
+
+	return get.Val(), lo.Map(ttls, func(ttl *redis.DurationCmd, _ int) time.Duration {
+		return ttl.Val()
+	}), nil
+}
+
+// claimRefresh returns the keys not being refreshed yet and marks them as being refreshed
+func (c *Cache[T]) claimRefresh(keys ...string) []string {
+	return lo.Filter(keys, func(key string, _ int) bool {
+		_, loaded := c.refreshing.LoadOrStore(key, struct{}{})
+		return !loaded
+	})
+}
+
+// refresh reloads a stale key in background, a failed refresh keeps the stale value until its hard TTL
+func (c *Cache[T]) refresh(
+	ctx context.Context,
+	key string,
+	fetch func(ctx context.Context) (T, error),
+	opts *fetchOptions[T],
+) {
+	if len(c.claimRefresh(key)) == 0 {
+		return
+	}
+
+	ctx = context.WithoutCancel(ctx)
+	gofer.Go(func() {
+		defer c.refreshing.Delete(key)
+
+		value, err := c.callFetch(ctx, fetch)
+		if err != nil {
+			log.Context(ctx).Warnf("refresh cache %s failed: %v", key, err)
+			return
+		}
+
+		_, _ = c.set(ctx, key, value, opts)
+	})
+}
+
+func (c *Cache[T]) mRefresh(
+	ctx context.Context,
+	keys []string,
+	batchLoader func(ctx context.Context, missing []string) (map[string]T, error),
+	opts *fetchOptions[T],
+) {
+	keys = c.claimRefresh(keys...)
+	if len(keys) == 0 {
+		return
+	}
+
+	ctx = context.WithoutCancel(ctx)
+	gofer.Go(func() {
+		defer func() {
+			for _, key := range keys {
+				c.refreshing.Delete(key)
+			}
+		}()
+
+		values, err := c.callBatchLoader(ctx, keys, batchLoader)
+		if err != nil {
+			log.Context(ctx).Warnf("refresh cache %v failed: %v", keys, err)
+			return
+		}
+
+		_, _ = c.mSet(ctx, keys, values, opts)
+	})
+}
@@ -0,0 +1,345 @@
+package cachex
+
+import (
+	"context"
+	"errors"
+	"os"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/cloudzenith/DouTok/backend/gopkgs/gofer"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/redis/go-redis/v9"
+	"github.com/stretchr/testify/assert"
+)
+
+type testValue struct {
+	Name string `json:"name"`
+}
+
+func TestMain(m *testing.M) {
+	gofer.InitSingleFlighter()
+	os.Exit(m.Run())
+}
+
+func newTestClient(t *testing.T) (*miniredis.Miniredis, *redis.Client) {
+	s := miniredis.RunT(t)
+	client := redis.NewClient(&redis.Options{Addr: s.Addr()})
+	t.Cleanup(func() {
+		_ = client.Close()
+	})
+
+	return s, client
+}
+
+func loader(name string, calls *int) func(ctx context.Context) (*testValue, error) {
+	return func(ctx context.Context) (*testValue, error) {
+		*calls++
+		return &testValue{Name: name}, nil
+	}
+}
+
+func TestCache_Fetch(t *testing.T) {
+	_, client := newTestClient(t)
+	cache := NewCache[*testValue](WithRedisClient[*testValue](client))
+
+	var calls int
+	value, err := cache.Fetch(context.Background(), "k", loader("a", &calls))
+	assert.Nil(t, err)
+	assert.Equal(t, "a", value.Name)
+
+	value, err = cache.Fetch(context.Background(), "k", loader("b", &calls))
+	assert.Nil(t, err)
+	assert.Equal(t, "a", value.Name)
+	assert.Equal(t, 1, calls)
+}
+
+func TestCache_FetchBarrier(t *testing.T) {
+	s, client := newTestClient(t)
+	cache := NewCache[*testValue](WithRedisClient[*testValue](client), WithUseBarrier[*testValue](true))
+
+	_, err := cache.Fetch(context.Background(), "k", func(ctx context.Context) (*testValue, error) {
+		return nil, errors.New("not found")
+	})
+	assert.ErrorIs(t, err, ErrNotFoundBarrier)
+
+	value, _ := s.Get("k")
+	assert.Equal(t, NotFoundBarrier, value)
+}
+
+func TestCache_Delete(t *testing.T) {
+	s, client := newTestClient(t)
+	cache := NewCache[*testValue](WithRedisClient[*testValue](client), WithUseLocal[*testValue](true))
+
+	var calls int
+	_, err := cache.Fetch(context.Background(), "k", loader("a", &calls))
+	assert.Nil(t, err)
+
+	assert.Nil(t, cache.Delete(context.Background(), "k"))
+	assert.False(t, s.Exists("k"))
+
+	value, err := cache.Fetch(context.Background(), "k", loader("b", &calls))
+	assert.Nil(t, err)
+	assert.Equal(t, "b", value.Name)
+	assert.Equal(t, 2, calls)
+}
+
+func TestCache_InvalidateAcrossInstances(t *testing.T) {
+	_, client := newTestClient(t)
+	localTTL := time.Minute
+
+	newReplica := func() *Cache[*testValue] {
+		c := NewCache[*testValue](
+			WithRedisClient[*testValue](client),
+			WithUseLocal[*testValue](true),
+			WithLocalTTL[*testValue](&localTTL),
+			WithInvalidateChannel[*testValue]("cachex:test:invalidate"),
+		)
+		t.Cleanup(func() {
+			_ = c.Close()
+		})
+		return c
+	}
+
+	a, b := newReplica(), newReplica()
+
+	var calls int
+	_, err := a.Fetch(context.Background(), "k", loader("a", &calls))
+	assert.Nil(t, err)
+	_, err = b.Fetch(context.Background(), "k", loader("a", &calls))
+	assert.Nil(t, err)
+	assert.NotNil(t, b.localCache.Get("k"))
+
+	assert.Nil(t, a.Delete(context.Background(), "k"))
+	assert.Eventually(t, func() bool {
+		return b.localCache.Get("k") == nil
+	}, time.Second, 10*time.Millisecond)
+
+	value, err := b.Fetch(context.Background(), "k", loader("b", &calls))
+	assert.Nil(t, err)
+	assert.Equal(t, "b", value.Name)
+}
+
+func TestCache_MFetch(t *testing.T) {
+	s, client := newTestClient(t)
+	cache := NewCache[*testValue](
+		WithRedisClient[*testValue](client),
+		WithUseLocal[*testValue](true),
+		WithUseBarrier[*testValue](true),
+	)
+
+	_ = s.Set("cached", `{"name":"cached"}`)
+	_ = s.Set("barrier", NotFoundBarrier)
+
+	var calls int
+	var requested []string
+	batchLoader := func(ctx context.Context, missing []string) (map[string]*testValue, error) {
+		calls++
+		requested = missing
+		return map[string]*testValue{"loaded": {Name: "loaded"}}, nil
+	}
+
+	values, err := cache.MFetch(context.Background(), []string{"cached", "barrier", "loaded", "absent", "loaded"}, batchLoader)
+	assert.Nil(t, err)
+	assert.Equal(t, 1, calls)
+	assert.ElementsMatch(t, []string{"loaded", "absent"}, requested)
+	assert.Len(t, values, 2)
+	assert.Equal(t, "cached", values["cached"].Name)
+	assert.Equal(t, "loaded", values["loaded"].Name)
+
+	value, _ := s.Get("absent")
+	assert.Equal(t, NotFoundBarrier, value)
+	assert.True(t, s.Exists("loaded"))
+
+	values, err = cache.MFetch(context.Background(), []string{"cached", "loaded", "absent"}, batchLoader)
+	assert.Nil(t, err)
+	assert.Equal(t, 1, calls)
+	assert.Len(t, values, 2)
+}
+
+func TestCache_MFetchSharesInflightKeys(t *testing.T) {
+	_, client := newTestClient(t)
+	cache := NewCache[*testValue](WithRedisClient[*testValue](client))
+
+	var calls atomic.Int32
+	started, release := make(chan struct{}), make(chan struct{})
+	batchLoader := func(ctx context.Context, missing []string) (map[string]*testValue, error) {
+		calls.Add(1)
+		close(started)
+		<-release
+		return map[string]*testValue{"k": {Name: "shared"}}, nil
+	}
+
+	var wg sync.WaitGroup
+	results := make([]map[string]*testValue, 2)
+	for i := range results {
+		if i > 0 {
+			<-started
+		}
+
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			results[i], _ = cache.MFetch(context.Background(), []string{"k"}, batchLoader)
+		}(i)
+	}
+
+	close(release)
+	wg.Wait()
+
+	assert.Equal(t, int32(1), calls.Load())
+	for _, values := range results {
+		assert.Equal(t, "shared", values["k"].Name)
+	}
+}
+
+func TestCache_MFetchFallback(t *testing.T) {
+	s, client := newTestClient(t)
+	cache := NewCache[*testValue](WithRedisClient[*testValue](client), WithUseFallback[*testValue](true))
+	_ = s.Set("k", `{"name":"cached"}`)
+
+	values, err := cache.MFetch(context.Background(), []string{"k"}, func(ctx context.Context, missing []string) (map[string]*testValue, error) {
+		return nil, errors.New("source down")
+	})
+	assert.Nil(t, err)
+	assert.Equal(t, "cached", values["k"].Name)
+}
+
+func TestCache_FetchStaleWhileRevalidate(t *testing.T) {
+	s, client := newTestClient(t)
+	redisTTL, softTTL := 10*time.Second, 2*time.Second
+	cache := NewCache[*testValue](
+		WithRedisClient[*testValue](client),
+		WithRedisTTL[*testValue](&redisTTL),
+		WithSoftTTL[*testValue](&softTTL),
+	)
+
+	var calls atomic.Int32
+	fetch := func(ctx context.Context) (*testValue, error) {
+		if calls.Add(1) == 1 {
+			return &testValue{Name: "a"}, nil
+		}
+		return &testValue{Name: "b"}, nil
+	}
+
+	value, err := cache.Fetch(context.Background(), "k", fetch)
+	assert.Nil(t, err)
+	assert.Equal(t, "a", value.Name)
+
+	s.FastForward(3 * time.Second)
+	value, err = cache.Fetch(context.Background(), "k", fetch)
+	assert.Nil(t, err)
+	assert.Equal(t, "a", value.Name)
+
+	assert.Eventually(t, func() bool {
+		value, _ := s.Get("k")
+		return value == `{"name":"b"}`
+	}, time.Second, 10*time.Millisecond)
+	assert.Equal(t, redisTTL, s.TTL("k"))
+}
+
+func TestCache_MFetchStaleWhileRevalidate(t *testing.T) {
+	s, client := newTestClient(t)
+	redisTTL, softTTL := 10*time.Second, 2*time.Second
+	cache := NewCache[*testValue](
+		WithRedisClient[*testValue](client),
+		WithRedisTTL[*testValue](&redisTTL),
+		WithSoftTTL[*testValue](&softTTL),
+	)
+
+	_ = s.Set("fresh", `{"name":"fresh"}`)
+	s.SetTTL("fresh", redisTTL)
+	_ = s.Set("stale", `{"name":"stale"}`)
+	s.SetTTL("stale", 5*time.Second)
+
+	refreshed := make(chan []string, 1)
+	values, err := cache.MFetch(context.Background(), []string{"fresh", "stale"}, func(ctx context.Context, missing []string) (map[string]*testValue, error) {
+		refreshed <- missing
+		return map[string]*testValue{"stale": {Name: "refreshed"}}, nil
+	})
+	assert.Nil(t, err)
+	assert.Equal(t, "stale", values["stale"].Name)
+	assert.Equal(t, []string{"stale"}, <-refreshed)
+
+	assert.Eventually(t, func() bool {
+		value, _ := s.Get("stale")
+		return value == `{"name":"refreshed"}`
+	}, time.Second, 10*time.Millisecond)
+}
+
+func TestCache_TTLJitter(t *testing.T) {
+	s, client := newTestClient(t)
+	redisTTL := 10 * time.Second
+	cache := NewCache[*testValue](
+		WithRedisClient[*testValue](client),
+		WithRedisTTL[*testValue](&redisTTL),
+		WithTTLJitter[*testValue](time.Second),
+	)
+
+	var calls int
+	_, err := cache.Fetch(context.Background(), "k", loader("a", &calls))
+	assert.Nil(t, err)
+
+	ttl := s.TTL("k")
+	assert.GreaterOrEqual(t, ttl, redisTTL)
+	assert.Less(t, ttl, redisTTL+time.Second)
+}
+
+func TestCache_Metrics(t *testing.T) {
+	s, client := newTestClient(t)
+	cache := NewCache[*testValue](
+		WithRedisClient[*testValue](client),
+		WithUseLocal[*testValue](true),
+		WithUseBarrier[*testValue](true),
+		WithMetrics[*testValue]("metrics_test"),
+	)
+	_ = s.Set("barrier", NotFoundBarrier)
+
+	var calls int
+	for i := 0; i < 2; i++ {
+		_, _ = cache.Fetch(context.Background(), "k", loader("a", &calls))
+	}
+	_, _ = cache.Fetch(context.Background(), "barrier", loader("a", &calls))
+	_, _ = cache.Fetch(context.Background(), "error", func(ctx context.Context) (*testValue, error) {
+		return nil, errors.New("not found")
+	})
+
+	assert.Equal(t, float64(1), testutil.ToFloat64(hitsCounter.WithLabelValues("metrics_test", tierLocal)))
+	assert.Equal(t, float64(1), testutil.ToFloat64(hitsCounter.WithLabelValues("metrics_test", tierBarrier)))
+	assert.Equal(t, float64(2), testutil.ToFloat64(missesCounter.WithLabelValues("metrics_test")))
+	assert.Equal(t, float64(2), testutil.ToFloat64(loaderCallsCounter.WithLabelValues("metrics_test")))
+	assert.Equal(t, float64(1), testutil.ToFloat64(loaderErrorsCounter.WithLabelValues("metrics_test")))
+}
+
+func TestCache_InvalidateTag(t *testing.T) {
+	s, client := newTestClient(t)
+	cache := NewCache[*testValue](WithRedisClient[*testValue](client), WithUseLocal[*testValue](true))
+
+	var calls int
+	_, err := cache.Fetch(context.Background(), "video:1", loader("a", &calls), WithTags[*testValue]("user:1"))
+	assert.Nil(t, err)
+	_, err = cache.MFetch(context.Background(), []string{"video:2", "video:3"}, func(ctx context.Context, missing []string) (map[string]*testValue, error) {
+		return map[string]*testValue{"video:2": {Name: "1"}, "video:3": {Name: "2"}}, nil
+	}, WithTagger(func(key string, value *testValue) []string {
+		return []string{"user:" + value.Name}
+	}))
+	assert.Nil(t, err)
+
+	members, _ := s.Members(tagKey("user:1"))
+	assert.ElementsMatch(t, []string{"video:1", "video:2"}, members)
+
+	assert.Nil(t, cache.InvalidateTag(context.Background(), "user:1"))
+	assert.False(t, s.Exists("video:1"))
+	assert.False(t, s.Exists("video:2"))
+	assert.True(t, s.Exists("video:3"))
+	assert.False(t, s.Exists(tagKey("user:1")))
+	assert.Nil(t, cache.localCache.Get("video:1"))
+	assert.NotNil(t, cache.localCache.Get("video:3"))
+
+	_, err = cache.Fetch(context.Background(), "video:1", loader("b", &calls))
+	assert.Nil(t, err)
+	assert.Equal(t, 2, calls)
+}
@@ -0,0 +1,55 @@
+package cachex
+
+import (
+	"encoding/json"
+
+	"github.com/vmihailenco/msgpack/v5"
+	"google.golang.org/protobuf/proto"
+)
+
+// Codec is used to serialize the values of a cache before writing them to redis and the local cache
+type Codec[T any] interface {
+	Marshal(value T) ([]byte, error)
+	Unmarshal(data []byte, value *T) error
+}
+
+// JSONCodec serializes values with encoding/json, it is the default codec of a cache
+type JSONCodec[T any] struct{}
+
+func (JSONCodec[T]) Marshal(value T) ([]byte, error) {
+	return json.Marshal(value)
+}
+
+func (JSONCodec[T]) Unmarshal(data []byte, value *T) error {
+	return json.Unmarshal(data, value)
+}
+
+// ProtoCodec serializes protobuf messages with the binary wire format
+type ProtoCodec[T proto.Message] struct{}
+
+func (ProtoCodec[T]) Marshal(value T) ([]byte, error) {
+	return proto.Marshal(value)
+}
+
+func (ProtoCodec[T]) Unmarshal(data []byte, value *T) error {
+	// generated messages support ProtoReflect on a nil pointer, so a zero T is enough to create a new message
+	var zero T
+	msg := zero.ProtoReflect().New().Interface()
+	if err := proto.Unmarshal(data, msg); err != nil {
+		return err
+	}
+
+	*value = msg.(T)
+	return nil
+}
+
+// MsgpackCodec serializes values with msgpack, which is more compact than json and keeps int64 precision
+type MsgpackCodec[T any] struct{}
+
+func (MsgpackCodec[T]) Marshal(value T) ([]byte, error) {
+	return msgpack.Marshal(value)
+}
+
+func (MsgpackCodec[T]) Unmarshal(data []byte, value *T) error {
+	return msgpack.Unmarshal(data, value)
+}
@@ -0,0 +1,103 @@
+package cachex
+
+import (
+	"context"
+	"math"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/types/known/typepb"
+)
+
+func newBenchMessage() *typepb.Type {
+	msg := &typepb.Type{
+		Name:    "doutok.video",
+		Oneofs:  []string{"cover", "play"},
+		Syntax:  typepb.Syntax_SYNTAX_PROTO3,
+		Edition: "2023",
+	}
+
+	for i := 0; i < 16; i++ {
+		msg.Fields = append(msg.Fields, &typepb.Field{
+			Kind:     typepb.Field_TYPE_INT64,
+			Number:   int32(i + 1),
+			Name:     "field",
+			JsonName: "field",
+		})
+	}
+
+	return msg
+}
+
+func TestCodecs(t *testing.T) {
+	msg := newBenchMessage()
+
+	protoCodec := ProtoCodec[*typepb.Type]{}
+	data, err := protoCodec.Marshal(msg)
+	assert.Nil(t, err)
+
+	var decoded *typepb.Type
+	assert.Nil(t, protoCodec.Unmarshal(data, &decoded))
+	assert.True(t, proto.Equal(msg, decoded))
+
+	value := map[string]int64{"id": math.MaxInt64}
+	msgpackCodec := MsgpackCodec[map[string]int64]{}
+	data, err = msgpackCodec.Marshal(value)
+	assert.Nil(t, err)
+
+	var decodedValue map[string]int64
+	assert.Nil(t, msgpackCodec.Unmarshal(data, &decodedValue))
+	assert.Equal(t, value, decodedValue)
+}
+
+func TestCache_FetchWithCodec(t *testing.T) {
+	_, client := newTestClient(t)
+	cache := NewCache[*typepb.Type](
+		WithRedisClient[*typepb.Type](client),
+		WithCodec[*typepb.Type](ProtoCodec[*typepb.Type]{}),
+	)
+
+	msg := newBenchMessage()
+	for i := 0; i < 2; i++ {
+		value, err := cache.Fetch(context.Background(), "k", func(ctx context.Context) (*typepb.Type, error) {
+			return msg, nil
+		})
+		assert.Nil(t, err)
+		assert.True(t, proto.Equal(msg, value))
+	}
+}
+
+func benchmarkCodec(b *testing.B, codec Codec[*typepb.Type]) {
+	msg := newBenchMessage()
+	data, err := codec.Marshal(msg)
+	if err != nil {
+		b.Fatal(err)
+	}
+
+	b.ReportAllocs()
+	b.ResetTimer()
+
+	for i := 0; i < b.N; i++ {
+		data, _ := codec.Marshal(msg)
+
+		var decoded *typepb.Type
+		if err := codec.Unmarshal(data, &decoded); err != nil {
+			b.Fatal(err)
+		}
+	}
+
+	b.ReportMetric(float64(len(data)), "bytes/value")
+}
+
+func BenchmarkJSONCodec(b *testing.B) {
+	benchmarkCodec(b, JSONCodec[*typepb.Type]{})
+}
+
+func BenchmarkProtoCodec(b *testing.B) {
+	benchmarkCodec(b, ProtoCodec[*typepb.Type]{})
+}
+
+func BenchmarkMsgpackCodec(b *testing.B) {
+	benchmarkCodec(b, MsgpackCodec[*typepb.Type]{})
+}
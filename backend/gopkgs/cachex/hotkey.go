@@ -0,0 +1,214 @@
+package cachex
+
+import (
+	"context"
+	"math/rand"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/go-kratos/kratos/v2/log"
+	"github.com/jellydator/ttlcache/v3"
+)
+
+const (
+	defaultHotKeySlots      = 10
+	defaultHotKeyMaxTracked = 10000
+	defaultHotKeyWindow     = 10 * time.Second
+	defaultPromoteTTL       = 10 * time.Second
+)
+
+// HotKey is a key and its estimated number of accesses in the window of a HotKeyDetector
+type HotKey struct {
+	Key   string
+	Count int
+}
+
+// HotKeyDetector counts key accesses in a sliding window split into slots, a key is hot when its estimated
+// accesses in the window reach the threshold
+type HotKeyDetector struct {
+	mu           sync.Mutex
+	threshold    int
+	sampleRate   float64
+	maxTracked   int
+	slotDuration time.Duration
+	slots        []map[string]int
+	current      int
+	slotStart    time.Time
+	now          func() time.Time
+}
+
+type HotKeyOption func(d *HotKeyDetector)
+
+// WithSampleRate records only a fraction of accesses, counts are scaled back when compared to the threshold
+func WithSampleRate(rate float64) HotKeyOption {
+	return func(d *HotKeyDetector) {
+		d.sampleRate = rate
+	}
+}
+
+// WithMaxTrackedKeys bounds the number of distinct keys counted in a slot, accesses to other keys are ignored
+// until the slot rotates
+func WithMaxTrackedKeys(n int) HotKeyOption {
+	return func(d *HotKeyDetector) {
+		d.maxTracked = n
+	}
+}
+
+// WithWindowSlots sets the number of slots the window is split into, more slots slide the window more smoothly.
+// n should be positive, the default number of slots is kept otherwise.
+func WithWindowSlots(n int) HotKeyOption {
+	return func(d *HotKeyDetector) {
+		if n <= 0 {
+			return
+		}
+
+		d.slots = make([]map[string]int, n)
+	}
+}
+
+// NewHotKeyDetector creates a detector reporting keys accessed at least threshold times within window,
+// a window shorter than a nanosecond per slot falls back to the default window
+func NewHotKeyDetector(threshold int, window time.Duration, options ...HotKeyOption) *HotKeyDetector {
+	d := &HotKeyDetector{
+		threshold:  threshold,
+		sampleRate: 1,
+		maxTracked: defaultHotKeyMaxTracked,
+		slots:      make([]map[string]int, defaultHotKeySlots),
+		now:        time.Now,
+	}
+
+	for _, option := range options {
+		option(d)
+	}
+
+	for i := range d.slots {
+		d.slots[i] = make(map[string]int)
+	}
+
+	d.slotDuration = window / time.Duration(len(d.slots))
+	if d.slotDuration <= 0 {
+		d.slotDuration = defaultHotKeyWindow / time.Duration(len(d.slots))
+	}
+	d.slotStart = d.now()
+	return d
+}
+
+// Record counts an access to key and reports whether key is hot
+func (d *HotKeyDetector) Record(key string) bool {
+	if d.sampleRate < 1 && rand.Float64() >= d.sampleRate {
+		return false
+	}
+
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	d.rotate()
+	slot := d.slots[d.current]
+	if _, ok := slot[key]; !ok && len(slot) >= d.maxTracked {
+		return false
+	}
+
+	slot[key]++
+	return d.count(key) >= d.threshold
+}
+
+// TopK returns the k most accessed keys in the window
+func (d *HotKeyDetector) TopK(k int) []HotKey {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	d.rotate()
+	counts := make(map[string]int)
+	for _, slot := range d.slots {
+		for key, n := range slot {
+			counts[key] += n
+		}
+	}
+
+	keys := make([]HotKey, 0, len(counts))
+	for key, n := range counts {
+		keys = append(keys, HotKey{Key: key, Count: int(float64(n) / d.sampleRate)})
+	}
+
+	sort.Slice(keys, func(i, j int) bool {
+		return keys[i].Count > keys[j].Count
+	})
+
+	if len(keys) > k {
+		keys = keys[:k]
+	}
+
+	return keys
+}
+
+// count returns the estimated accesses of key in the window, the caller must hold mu
+func (d *HotKeyDetector) count(key string) int {
+	var n int
+	for _, slot := range d.slots {
+		n += slot[key]
+	}
+
+	return int(float64(n) / d.sampleRate)
+}
+
+// rotate drops the slots which slid out of the window, the caller must hold mu
+func (d *HotKeyDetector) rotate() {
+	elapsed := int(d.now().Sub(d.slotStart) / d.slotDuration)
+	if elapsed <= 0 {
+		return
+	}
+
+	for i := 0; i < elapsed && i < len(d.slots); i++ {
+		d.current = (d.current + 1) % len(d.slots)
+		d.slots[d.current] = make(map[string]int)
+	}
+
+	d.slotStart = d.slotStart.Add(time.Duration(elapsed) * d.slotDuration)
+}
+
+// getHot returns the value of key promoted to the hot cache
+func (c *Cache[T]) getHot(key string) ([]byte, bool) {
+	if c.hotCache == nil {
+		return nil, false
+	}
+
+	item := c.hotCache.Get(key)
+	if item == nil {
+		return nil, false
+	}
+
+	c.metrics.hit(tierHot, 1)
+	return item.Value(), true
+}
+
+// recordHot counts an access to every key and returns the hot ones
+func (c *Cache[T]) recordHot(keys ...string) []string {
+	if c.hotKeys == nil {
+		return nil
+	}
+
+	var hot []string
+	for _, key := range keys {
+		if c.hotKeys.Record(key) {
+			hot = append(hot, key)
+		}
+	}
+
+	return hot
+}
+
+// promote keeps the value of a hot key in process for the promote TTL, whether the local cache is used or not
+func (c *Cache[T]) promote(ctx context.Context, key string, val []byte) {
+	if c.hotCache.Has(key) {
+		return
+	}
+
+	c.hotCache.Set(key, val, c.promoteTTL)
+	c.metrics.promotion()
+	log.Context(ctx).Infof("cachex: promote hot key %s to local cache for %s", key, c.promoteTTL)
+}
+
+func newHotCache() *ttlcache.Cache[string, []byte] {
+	return ttlcache.New[string, []byte](ttlcache.WithDisableTouchOnHit[string, []byte]())
+}
@@ -0,0 +1,131 @@
+package cachex
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestHotKeyDetector_SlidingWindow(t *testing.T) {
+	now := time.Now()
+	d := NewHotKeyDetector(3, time.Second)
+	d.now = func() time.Time {
+		return now
+	}
+	d.slotStart = now
+
+	assert.False(t, d.Record("k"))
+	assert.False(t, d.Record("k"))
+
+	now = now.Add(500 * time.Millisecond)
+	assert.True(t, d.Record("k"))
+
+	// the first two accesses slid out of the window
+	now = now.Add(600 * time.Millisecond)
+	assert.False(t, d.Record("k"))
+
+	now = now.Add(2 * time.Second)
+	assert.Empty(t, d.TopK(10))
+}
+
+func TestHotKeyDetector_TopK(t *testing.T) {
+	d := NewHotKeyDetector(100, time.Second)
+	for i := 0; i < 3; i++ {
+		d.Record("a")
+	}
+	d.Record("b")
+	for i := 0; i < 2; i++ {
+		d.Record("c")
+	}
+
+	assert.Equal(t, []HotKey{{Key: "a", Count: 3}, {Key: "c", Count: 2}}, d.TopK(2))
+}
+
+func TestHotKeyDetector_MaxTrackedKeys(t *testing.T) {
+	d := NewHotKeyDetector(1, time.Second, WithMaxTrackedKeys(1))
+	assert.True(t, d.Record("a"))
+	assert.False(t, d.Record("b"))
+}
+
+func TestHotKeyDetector_InvalidOptions(t *testing.T) {
+	for _, n := range []int{0, -1} {
+		d := NewHotKeyDetector(1, time.Second, WithWindowSlots(n))
+		assert.Len(t, d.slots, defaultHotKeySlots)
+		assert.True(t, d.Record("k"))
+	}
+
+	d := NewHotKeyDetector(1, 0)
+	assert.Equal(t, defaultHotKeyWindow/defaultHotKeySlots, d.slotDuration)
+	assert.True(t, d.Record("k"))
+
+	_, client := newTestClient(t)
+	cache := NewCache[*testValue](
+		WithRedisClient[*testValue](client),
+		WithHotKeyDetector[*testValue](NewHotKeyDetector(1, time.Minute), 0),
+	)
+	assert.Equal(t, defaultPromoteTTL, cache.promoteTTL)
+}
+
+func TestCache_HotKeyPromotion(t *testing.T) {
+	s, client := newTestClient(t)
+	cache := NewCache[*testValue](
+		WithRedisClient[*testValue](client),
+		WithHotKeyDetector[*testValue](NewHotKeyDetector(3, time.Minute), time.Minute),
+		WithMetrics[*testValue]("hot_key_test"),
+	)
+
+	var calls int
+	for i := 0; i < 3; i++ {
+		_, err := cache.Fetch(context.Background(), "k", loader("a", &calls))
+		assert.Nil(t, err)
+	}
+	assert.Equal(t, float64(1), testutil.ToFloat64(promotionsCounter.WithLabelValues("hot_key_test")))
+
+	// promoted keys are served in process without touching redis or the loader
+	s.FlushAll()
+	value, err := cache.Fetch(context.Background(), "k", loader("b", &calls))
+	assert.Nil(t, err)
+	assert.Equal(t, "a", value.Name)
+	assert.Equal(t, 1, calls)
+
+	values, err := cache.MFetch(context.Background(), []string{"k"}, nil)
+	assert.Nil(t, err)
+	assert.Equal(t, "a", values["k"].Name)
+	assert.Equal(t, float64(2), testutil.ToFloat64(hitsCounter.WithLabelValues("hot_key_test", tierHot)))
+
+	assert.Nil(t, cache.Invalidate(context.Background(), "k"))
+	value, err = cache.Fetch(context.Background(), "k", loader("b", &calls))
+	assert.Nil(t, err)
+	assert.Equal(t, "b", value.Name)
+}
+
+func TestCache_MFetchHotKeyPromotion(t *testing.T) {
+	s, client := newTestClient(t)
+	cache := NewCache[*testValue](
+		WithRedisClient[*testValue](client),
+		WithHotKeyDetector[*testValue](NewHotKeyDetector(2, time.Minute), time.Minute),
+	)
+
+	batchLoader := func(ctx context.Context, missing []string) (map[string]*testValue, error) {
+		values := make(map[string]*testValue)
+		for _, key := range missing {
+			values[key] = &testValue{Name: key}
+		}
+		return values, nil
+	}
+
+	_, _ = cache.MFetch(context.Background(), []string{"a", "b"}, batchLoader)
+	_, _ = cache.MFetch(context.Background(), []string{"a"}, batchLoader)
+
+	s.FlushAll()
+	values, err := cache.MFetch(context.Background(), []string{"a", "b"},
+		func(ctx context.Context, missing []string) (map[string]*testValue, error) {
+			assert.Equal(t, []string{"b"}, missing)
+			return batchLoader(ctx, missing)
+		})
+	assert.Nil(t, err)
+	assert.Len(t, values, 2)
+}
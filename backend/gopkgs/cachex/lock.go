@@ -4,29 +4,210 @@ import (
 	"context"
 	"fmt"
 	"github.com/bsm/redislock"
+	"github.com/cloudzenith/DouTok/backend/gopkgs/gofer"
+	"github.com/go-kratos/kratos/v2/log"
 	"github.com/redis/go-redis/v9"
+	"sync"
 	"time"
 )
 
+const (
+	fencingKeySuffix   = ":fencing"
+	defaultMinBackoff  = 16 * time.Millisecond
+	defaultMaxBackoff  = 512 * time.Millisecond
+	renewIntervalRatio = 3
+	minRenewInterval   = time.Millisecond
+)
+
+var (
+	ErrLockNotObtained = redislock.ErrNotObtained
+	ErrLockNotHeld     = redislock.ErrLockNotHeld
+)
+
+// fencingScript issues the next fencing token only if the lock is still held by the caller,
+// so tokens increase in the order the lock was held
+var fencingScript = redis.NewScript(`
+if redis.call('GET', KEYS[1]) == ARGV[1] then
+	return redis.call('INCR', KEYS[2])
+end
+return -1
+`)
+
 type LockHandle struct {
 	locker      *redislock.Client
 	client      *redis.Client
 	lockPattern string
 	newLockTTL  time.Duration
 	renewTTL    time.Duration
+	minBackoff  time.Duration
+	maxBackoff  time.Duration
+	maxRetries  int
+}
+
+type LockOption func(*LockHandle)
+
+// WithBackoff sets the exponential backoff between two attempts of TryLockFor and of Lock with retries
+func WithBackoff(min, max time.Duration) LockOption {
+	return func(l *LockHandle) {
+		l.minBackoff = min
+		l.maxBackoff = max
+	}
 }
 
-func New(client *redis.Client, lockPattern string, newLockTTL, renewTTL time.Duration) *LockHandle {
-	return &LockHandle{
+// WithMaxRetries makes Lock retry at most n times with backoff, by default Lock makes a single attempt
+func WithMaxRetries(n int) LockOption {
+	return func(l *LockHandle) {
+		l.maxRetries = n
+	}
+}
+
+// New creates a LockHandle, locks obtained expire after newLockTTL unless they are renewed.
+// When renewTTL is positive, a watchdog refreshes every held lock to renewTTL until it is released.
+func New(client *redis.Client, lockPattern string, newLockTTL, renewTTL time.Duration, options ...LockOption) *LockHandle {
+	l := &LockHandle{
 		locker:      redislock.New(client),
 		client:      client,
 		lockPattern: lockPattern,
 		newLockTTL:  newLockTTL,
 		renewTTL:    renewTTL,
+		minBackoff:  defaultMinBackoff,
+		maxBackoff:  defaultMaxBackoff,
+	}
+
+	for _, option := range options {
+		option(l)
 	}
+
+	return l
+}
+
+// Lock is a held distributed lock
+type Lock struct {
+	*redislock.Lock
+	fencingToken int64
+	stopOnce     sync.Once
+	stop         chan struct{}
+	lost         chan struct{}
+	watchdogDone chan struct{}
+}
+
+// FencingToken returns a number which is greater than the tokens of all previous holders of the lock,
+// storage writes should be rejected when they carry a token lower than the last one seen
+func (l *Lock) FencingToken() int64 {
+	return l.fencingToken
+}
+
+// Lost is closed when the watchdog fails to renew the lock
+func (l *Lock) Lost() <-chan struct{} {
+	return l.lost
 }
 
-func (l *LockHandle) Lock(ctx context.Context, keywords ...any) (*redislock.Lock, error) {
+// Release stops renewing the lock and releases it
+func (l *Lock) Release(ctx context.Context) error {
+	l.stopWatchdog()
+	return l.Lock.Release(ctx)
+}
+
+func (l *Lock) stopWatchdog() {
+	l.stopOnce.Do(func() {
+		close(l.stop)
+	})
+	<-l.watchdogDone
+}
+
+// Lock obtains the lock, it returns ErrLockNotObtained when the lock is held unless WithMaxRetries is set
+func (l *LockHandle) Lock(ctx context.Context, keywords ...any) (*Lock, error) {
+	if l.maxRetries <= 0 {
+		return l.obtain(ctx, redislock.NoRetry(), keywords...)
+	}
+
+	return l.obtain(ctx, redislock.LimitRetry(l.backoff(), l.maxRetries), keywords...)
+}
+
+// TryLock makes a single attempt to obtain the lock, it returns ErrLockNotObtained when the lock is held
+func (l *LockHandle) TryLock(ctx context.Context, keywords ...any) (*Lock, error) {
+	return l.obtain(ctx, redislock.NoRetry(), keywords...)
+}
+
+// TryLockFor retries to obtain the lock with backoff for at most wait
+func (l *LockHandle) TryLockFor(ctx context.Context, wait time.Duration, keywords ...any) (*Lock, error) {
+	ctx, cancel := context.WithTimeout(ctx, wait)
+	defer cancel()
+
+	lock, err := l.obtain(ctx, l.backoff(), keywords...)
+	if err != nil && ctx.Err() != nil {
+		return nil, ErrLockNotObtained
+	}
+
+	return lock, err
+}
+
+func (l *LockHandle) backoff() redislock.RetryStrategy {
+	return redislock.ExponentialBackoff(l.minBackoff, l.maxBackoff)
+}
+
+func (l *LockHandle) obtain(ctx context.Context, strategy redislock.RetryStrategy, keywords ...any) (*Lock, error) {
 	lockKey := fmt.Sprintf(l.lockPattern, keywords...)
-	return l.locker.Obtain(ctx, lockKey, l.newLockTTL, nil)
+	obtained, err := l.locker.Obtain(ctx, lockKey, l.newLockTTL, &redislock.Options{
+		RetryStrategy: strategy,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	token, err := fencingScript.Run(ctx, l.client, []string{lockKey, lockKey + fencingKeySuffix}, obtained.Token()).Int64()
+	if err != nil {
+		_ = obtained.Release(context.WithoutCancel(ctx))
+		return nil, err
+	}
+
+	if token < 0 {
+		return nil, ErrLockNotObtained
+	}
+
+	lock := &Lock{
+		Lock:         obtained,
+		fencingToken: token,
+		stop:         make(chan struct{}),
+		lost:         make(chan struct{}),
+		watchdogDone: make(chan struct{}),
+	}
+
+	l.watch(lock)
+	return lock, nil
+}
+
+// watch refreshes lock every third of the shorter of newLockTTL and renewTTL until it is released
+// or the refresh fails, so the first refresh happens before the lock obtained with newLockTTL expires.
+// The interval is at least minRenewInterval, tiny TTLs would make it zero otherwise.
+func (l *LockHandle) watch(lock *Lock) {
+	if l.renewTTL <= 0 {
+		close(lock.watchdogDone)
+		return
+	}
+
+	interval := max(min(l.newLockTTL, l.renewTTL)/renewIntervalRatio, minRenewInterval)
+	gofer.Go(func() {
+		defer close(lock.watchdogDone)
+
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-lock.stop:
+				return
+			case <-ticker.C:
+			}
+
+			ctx, cancel := context.WithTimeout(context.Background(), interval)
+			err := lock.Refresh(ctx, l.renewTTL, nil)
+			cancel()
+			if err != nil {
+				log.Errorf("failed to renew lock %s: %v", lock.Key(), err)
+				close(lock.lost)
+				return
+			}
+		}
+	})
 }
@@ -0,0 +1,114 @@
+package cachex
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestLockHandle_TryLock(t *testing.T) {
+	_, client := newTestClient(t)
+	handle := New(client, "lock:%d", time.Second, 0)
+
+	lock, err := handle.TryLock(context.Background(), 1)
+	assert.Nil(t, err)
+
+	_, err = handle.TryLock(context.Background(), 1)
+	assert.ErrorIs(t, err, ErrLockNotObtained)
+
+	_, err = handle.TryLockFor(context.Background(), 50*time.Millisecond, 1)
+	assert.ErrorIs(t, err, ErrLockNotObtained)
+
+	assert.Nil(t, lock.Release(context.Background()))
+	lock, err = handle.TryLock(context.Background(), 1)
+	assert.Nil(t, err)
+	assert.Nil(t, lock.Release(context.Background()))
+}
+
+func TestLockHandle_Lock(t *testing.T) {
+	_, client := newTestClient(t)
+	handle := New(client, "lock:%d", time.Second, 0)
+
+	lock, err := handle.Lock(context.Background(), 1)
+	assert.Nil(t, err)
+
+	start := time.Now()
+	_, err = handle.Lock(context.Background(), 1)
+	assert.ErrorIs(t, err, ErrLockNotObtained)
+	assert.Less(t, time.Since(start), 100*time.Millisecond)
+
+	retrying := New(client, "lock:%d", time.Second, 0, WithBackoff(time.Millisecond, time.Millisecond), WithMaxRetries(3))
+	_, err = retrying.Lock(context.Background(), 1)
+	assert.ErrorIs(t, err, ErrLockNotObtained)
+
+	assert.Nil(t, lock.Release(context.Background()))
+}
+
+func TestLockHandle_TryLockForWaitsForRelease(t *testing.T) {
+	_, client := newTestClient(t)
+	handle := New(client, "lock:%d", time.Second, 0, WithBackoff(time.Millisecond, 10*time.Millisecond))
+
+	first, err := handle.Lock(context.Background(), 1)
+	assert.Nil(t, err)
+
+	time.AfterFunc(50*time.Millisecond, func() {
+		_ = first.Release(context.Background())
+	})
+
+	second, err := handle.TryLockFor(context.Background(), time.Second, 1)
+	assert.Nil(t, err)
+	assert.Greater(t, second.FencingToken(), first.FencingToken())
+	assert.Nil(t, second.Release(context.Background()))
+}
+
+func TestLockHandle_Watchdog(t *testing.T) {
+	s, client := newTestClient(t)
+	renewTTL := 150 * time.Millisecond
+	handle := New(client, "lock:%d", time.Second, renewTTL)
+
+	lock, err := handle.Lock(context.Background(), 1)
+	assert.Nil(t, err)
+
+	assert.Eventually(t, func() bool {
+		return s.TTL("lock:1") == renewTTL
+	}, time.Second, 10*time.Millisecond)
+
+	s.Del("lock:1")
+	select {
+	case <-lock.Lost():
+	case <-time.After(time.Second):
+		t.Fatal("lost lock was not detected")
+	}
+
+	assert.ErrorIs(t, lock.Release(context.Background()), ErrLockNotHeld)
+}
+
+func TestLockHandle_WatchdogRenewsBeforeNewLockTTL(t *testing.T) {
+	s, client := newTestClient(t)
+	handle := New(client, "lock:%d", 60*time.Millisecond, time.Second)
+
+	lock, err := handle.Lock(context.Background(), 1)
+	assert.Nil(t, err)
+
+	assert.Eventually(t, func() bool {
+		return s.TTL("lock:1") == time.Second
+	}, 50*time.Millisecond, 5*time.Millisecond)
+	assert.Nil(t, lock.Release(context.Background()))
+}
+
+func TestLockHandle_WatchdogTinyTTL(t *testing.T) {
+	_, client := newTestClient(t)
+	handle := New(client, "lock:%d", time.Second, 2*time.Nanosecond)
+
+	lock, err := handle.Lock(context.Background(), 1)
+	assert.Nil(t, err)
+
+	// a third of 2ns is a zero interval, the watchdog still refreshes and detects the lost lock
+	select {
+	case <-lock.Lost():
+	case <-time.After(time.Second):
+		t.Fatal("watchdog did not refresh the lock")
+	}
+}
@@ -0,0 +1,117 @@
+package cachex
+
+import (
+	"sync"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+const (
+	tierLocal   = "local"
+	tierRedis   = "redis"
+	tierBarrier = "barrier"
+	tierLoader  = "loader"
+	tierBloom   = "bloom"
+	tierHot     = "hot"
+)
+
+var (
+	registerMetricsOnce sync.Once
+
+	hitsCounter = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: "cachex",
+		Name:      "hits_total",
+		Help:      "Number of keys served from a cache tier, barrier and bloom hits are keys known to be not found.",
+	}, []string{"cache", "tier"})
+
+	missesCounter = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: "cachex",
+		Name:      "misses_total",
+		Help:      "Number of keys found in neither the local cache nor redis.",
+	}, []string{"cache"})
+
+	loaderCallsCounter = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: "cachex",
+		Name:      "loader_calls_total",
+		Help:      "Number of calls to the fetch or batch loader functions.",
+	}, []string{"cache"})
+
+	loaderErrorsCounter = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: "cachex",
+		Name:      "loader_errors_total",
+		Help:      "Number of failed calls to the fetch or batch loader functions.",
+	}, []string{"cache"})
+
+	promotionsCounter = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: "cachex",
+		Name:      "hot_key_promotions_total",
+		Help:      "Number of hot keys promoted into the in-process cache.",
+	}, []string{"cache"})
+
+	durationHistogram = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: "cachex",
+		Name:      "duration_seconds",
+		Help:      "Latency of reading a cache tier or calling a loader.",
+		Buckets:   []float64{.0001, .0005, .001, .005, .01, .025, .05, .1, .25, .5, 1},
+	}, []string{"cache", "tier"})
+)
+
+func registerMetrics() {
+	registerMetricsOnce.Do(func() {
+		prometheus.MustRegister(hitsCounter, missesCounter, loaderCallsCounter, loaderErrorsCounter, promotionsCounter, durationHistogram)
+	})
+}
+
+// cacheMetrics records the metrics of a named cache, a nil *cacheMetrics records nothing
+type cacheMetrics struct {
+	name string
+}
+
+func newCacheMetrics(name string) *cacheMetrics {
+	registerMetrics()
+	return &cacheMetrics{name: name}
+}
+
+func (m *cacheMetrics) hit(tier string, n int) {
+	if m == nil || n == 0 {
+		return
+	}
+
+	hitsCounter.WithLabelValues(m.name, tier).Add(float64(n))
+}
+
+func (m *cacheMetrics) miss(n int) {
+	if m == nil || n == 0 {
+		return
+	}
+
+	missesCounter.WithLabelValues(m.name).Add(float64(n))
+}
+
+func (m *cacheMetrics) loaderCall(err error) {
+	if m == nil {
+		return
+	}
+
+	loaderCallsCounter.WithLabelValues(m.name).Inc()
+	if err != nil {
+		loaderErrorsCounter.WithLabelValues(m.name).Inc()
+	}
+}
+
+func (m *cacheMetrics) promotion() {
+	if m == nil {
+		return
+	}
+
+	promotionsCounter.WithLabelValues(m.name).Inc()
+}
+
+func (m *cacheMetrics) observe(tier string, start time.Time) {
+	if m == nil {
+		return
+	}
+
+	durationHistogram.WithLabelValues(m.name, tier).Observe(time.Since(start).Seconds())
+}
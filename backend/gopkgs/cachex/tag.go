@@ -0,0 +1,50 @@
+package cachex
+
+import (
+	"context"
+
+	"github.com/redis/go-redis/v9"
+)
+
+const TagKeyPrefix = "cachex:tag:"
+
+func tagKey(tag string) string {
+	return TagKeyPrefix + tag
+}
+
+// addTags records key in the redis set of every tag, a tag set lives as long as its newest key
+func (c *Cache[T]) addTags(ctx context.Context, pipe redis.Pipeliner, key string, tags []string) {
+	for _, tag := range tags {
+		pipe.SAdd(ctx, tagKey(tag), key)
+		pipe.Expire(ctx, tagKey(tag), *c.redisTTL+c.ttlJitter)
+	}
+}
+
+// InvalidateTag removes every key tagged with tag from redis and drops their local copies.
+// Tags are shared by all caches on the same redis, caches with different value types but the same tags
+// should use the same invalidate channel so that their local copies are dropped too.
+// Every key is deleted on its own, so the keys of a tag may live in different redis cluster slots,
+// and only the keys read are removed from the tag, the keys tagged meanwhile are kept for the next invalidation.
+func (c *Cache[T]) InvalidateTag(ctx context.Context, tag string) error {
+	keys, err := c.client.SMembers(ctx, tagKey(tag)).Result()
+	if err != nil {
+		return err
+	}
+
+	if len(keys) == 0 {
+		return nil
+	}
+
+	members := make([]interface{}, 0, len(keys))
+	pipe := c.client.Pipeline()
+	for _, key := range keys {
+		pipe.Del(ctx, key)
+		members = append(members, key)
+	}
+	pipe.SRem(ctx, tagKey(tag), members...)
+	if _, err := pipe.Exec(ctx); err != nil {
+		return err
+	}
+
+	return c.Invalidate(ctx, keys...)
+}
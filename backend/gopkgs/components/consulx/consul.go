@@ -87,7 +87,7 @@ func IsHealth() (err error) {
 			return false
 		}
 
-		log.Infof("consul health check success, client key: %s", key)
+		log.Debugf("consul health check success, client key: %s", key)
 		return true
 	})
 
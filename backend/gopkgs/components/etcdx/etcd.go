@@ -78,7 +78,22 @@ func IsHealth() (err error) {
 			return false
 		}
 
-		log.Infof("etcd health check success, client key: %s", key)
+		log.Debugf("etcd health check success, client key: %s", key)
+		return true
+	})
+
+	return err
+}
+
+// Close closes all clients
+func Close() (err error) {
+	globalClientMap.Range(func(key, value any) bool {
+		if e := value.(*clientv3.Client).Close(); e != nil {
+			log.Errorf("failed to close etcd client %s: %v", key, e)
+			err = e
+		}
+
+		globalClientMap.Delete(key)
 		return true
 	})
 
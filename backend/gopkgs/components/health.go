@@ -0,0 +1,28 @@
+package components
+
+import (
+	"context"
+	"net"
+	"strings"
+	"time"
+)
+
+// HealthCheckTimeout bounds a single health check of a component
+const HealthCheckTimeout = 3 * time.Second
+
+// DialAddresses checks that every address of a list separated by ";" or "," accepts tcp connections,
+// it is used to check services without a ping api such as rocketmq name servers
+func DialAddresses(ctx context.Context, addresses string) error {
+	var dialer net.Dialer
+	for _, addr := range strings.FieldsFunc(addresses, func(r rune) bool {
+		return r == ';' || r == ','
+	}) {
+		conn, err := dialer.DialContext(ctx, "tcp", strings.TrimSpace(addr))
+		if err != nil {
+			return err
+		}
+		_ = conn.Close()
+	}
+
+	return nil
+}
@@ -7,6 +7,8 @@ type Config struct {
 	AccessKey   string `yaml:"access_key" json:"access_key"`
 	SecretKey   string `yaml:"secret_key" json:"secret_key"`
 	Secure      bool   `yaml:"secure" json:"secure"`
+	// HealthCheckBucket is stat by the health check when set, otherwise the health check lists the buckets
+	HealthCheckBucket string `yaml:"health_check_bucket" json:"health_check_bucket"`
 }
 
 func (c *Config) SetDefault() {
@@ -77,15 +77,37 @@ func GetClient(ctx context.Context, keys ...string) *minio.Core {
 func IsHealth() (err error) {
 	globalClientMap.Range(func(key, value interface{}) bool {
 		client := value.(*minio.Core)
-		_, err = client.ListBuckets(context.Background())
+		ctx, cancel := context.WithTimeout(context.Background(), components.HealthCheckTimeout)
+		defer cancel()
+
+		err = ping(ctx, client, globalConfigMap[key.(string)])
 		if err != nil {
 			log.Errorf("minio health check failed, client key: %s", key)
 			return false
 		}
 
-		log.Infof("minio health check success, client key: %s", key)
+		log.Debugf("minio health check success, client key: %s", key)
 		return true
 	})
 
 	return err
 }
+
+// ping stats the health check bucket when it is configured, otherwise it lists the buckets
+func ping(ctx context.Context, client *minio.Core, c *Config) error {
+	if c == nil || c.HealthCheckBucket == "" {
+		_, err := client.ListBuckets(ctx)
+		return err
+	}
+
+	exists, err := client.BucketExists(ctx, c.HealthCheckBucket)
+	if err != nil {
+		return err
+	}
+
+	if !exists {
+		return fmt.Errorf("bucket %s not found", c.HealthCheckBucket)
+	}
+
+	return nil
+}
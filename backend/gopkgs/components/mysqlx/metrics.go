@@ -0,0 +1,64 @@
+package mysqlx
+
+import (
+	"github.com/prometheus/client_golang/prometheus"
+	"gorm.io/gorm"
+)
+
+var (
+	maxOpenDesc = prometheus.NewDesc(
+		"mysql_pool_max_open_connections", "Maximum number of open connections of a client.", []string{"client"}, nil,
+	)
+	openDesc = prometheus.NewDesc(
+		"mysql_pool_open_connections", "Number of open connections of a client.", []string{"client"}, nil,
+	)
+	inUseDesc = prometheus.NewDesc(
+		"mysql_pool_in_use_connections", "Number of connections in use of a client.", []string{"client"}, nil,
+	)
+	idleDesc = prometheus.NewDesc(
+		"mysql_pool_idle_connections", "Number of idle connections of a client.", []string{"client"}, nil,
+	)
+	waitCountDesc = prometheus.NewDesc(
+		"mysql_pool_wait_count_total", "Number of connections waited for.", []string{"client"}, nil,
+	)
+	waitDurationDesc = prometheus.NewDesc(
+		"mysql_pool_wait_duration_seconds_total", "Time blocked waiting for a new connection.", []string{"client"}, nil,
+	)
+)
+
+// Collector exports the connection pool stats of every client
+func Collector() prometheus.Collector {
+	return poolCollector{}
+}
+
+type poolCollector struct{}
+
+func (poolCollector) Describe(ch chan<- *prometheus.Desc) {
+	ch <- maxOpenDesc
+	ch <- openDesc
+	ch <- inUseDesc
+	ch <- idleDesc
+	ch <- waitCountDesc
+	ch <- waitDurationDesc
+}
+
+func (poolCollector) Collect(ch chan<- prometheus.Metric) {
+	globalClientMap.Range(func(key, value any) bool {
+		db, err := value.(*gorm.DB).DB()
+		if err != nil {
+			return true
+		}
+
+		client := key.(string)
+		stats := db.Stats()
+		ch <- prometheus.MustNewConstMetric(maxOpenDesc, prometheus.GaugeValue, float64(stats.MaxOpenConnections), client)
+		ch <- prometheus.MustNewConstMetric(openDesc, prometheus.GaugeValue, float64(stats.OpenConnections), client)
+		ch <- prometheus.MustNewConstMetric(inUseDesc, prometheus.GaugeValue, float64(stats.InUse), client)
+		ch <- prometheus.MustNewConstMetric(idleDesc, prometheus.GaugeValue, float64(stats.Idle), client)
+		ch <- prometheus.MustNewConstMetric(waitCountDesc, prometheus.CounterValue, float64(stats.WaitCount), client)
+		ch <- prometheus.MustNewConstMetric(
+			waitDurationDesc, prometheus.CounterValue, stats.WaitDuration.Seconds(), client,
+		)
+		return true
+	})
+}
@@ -15,15 +15,29 @@ import (
 
 var (
 	globalClientMap = sync.Map{}
+	// globalConfigMap is replaced instead of modified, so the maps returned by GetConfig are never written
 	globalConfigMap = make(components.ConfigMap[*Config])
+	configMu        sync.RWMutex
+	dialectors      = sync.Map{}
 )
 
+// GetConfig returns the configs of the clients, it should not be modified
 func GetConfig() components.ConfigMap[*Config] {
+	configMu.RLock()
+	defer configMu.RUnlock()
+
 	return globalConfigMap
 }
 
-func Init(cm components.ConfigMap[*Config]) (func() error, error) {
+func setConfig(cm components.ConfigMap[*Config]) {
+	configMu.Lock()
+	defer configMu.Unlock()
+
 	globalConfigMap = cm
+}
+
+func Init(cm components.ConfigMap[*Config]) (func() error, error) {
+	setConfig(cm)
 
 	for k, v := range cm {
 		db, err := Connect(v)
@@ -38,39 +52,106 @@ func Init(cm components.ConfigMap[*Config]) (func() error, error) {
 	return IsHealth, nil
 }
 
+// RegisterDialector makes Connect open the clients of dialect with open instead of the mysql driver,
+// such as sqlite clients in tests
+func RegisterDialector(dialect string, open func(c *Config) gorm.Dialector) {
+	dialectors.Store(dialect, open)
+}
+
 func Connect(c *Config) (*gorm.DB, error) {
 	c.SetDefault()
+	if open, ok := dialectors.Load(c.Dialect); ok {
+		db, err := gorm.Open(open.(func(c *Config) gorm.Dialector)(c), gormConfig())
+		if err != nil {
+			return nil, err
+		}
+
+		originDB, err := db.DB()
+		if err != nil {
+			return nil, err
+		}
+
+		setPool(originDB, c)
+		return db, nil
+	}
+
 	originDB, err := sql.Open("mysql", c.ToDSN())
 	if err != nil {
 		return nil, err
 	}
 
-	originDB.SetMaxIdleConns(c.MaxIdle)
-	originDB.SetMaxOpenConns(c.MaxOpen)
+	setPool(originDB, c)
+
+	var connPoll gorm.ConnPool = originDB
+
+	dialector := mysql.New(mysql.Config{
+		Conn: connPoll,
+	})
+
+	return gorm.Open(dialector, gormConfig())
+}
+
+func gormConfig() *gorm.Config {
+	return &gorm.Config{
+		NamingStrategy: schema.NamingStrategy{
+			SingularTable: true,
+		},
+	}
+}
+
+// setPool applies the pool settings of c, they are safe to change at runtime
+func setPool(db *sql.DB, c *Config) {
+	db.SetMaxIdleConns(c.MaxIdle)
+	db.SetMaxOpenConns(c.MaxOpen)
 
 	if c.ConnMaxLifeTime > 0 {
-		originDB.SetConnMaxLifetime(time.Duration(c.ConnMaxLifeTime) * time.Second)
+		db.SetConnMaxLifetime(time.Duration(c.ConnMaxLifeTime) * time.Second)
 	} else {
-		originDB.SetConnMaxLifetime(0)
+		db.SetConnMaxLifetime(0)
 	}
 
 	if c.ConnMaxIdleTime > 0 {
-		originDB.SetConnMaxIdleTime(time.Duration(c.ConnMaxIdleTime) * time.Second)
+		db.SetConnMaxIdleTime(time.Duration(c.ConnMaxIdleTime) * time.Second)
 	} else {
-		originDB.SetConnMaxIdleTime(0)
+		db.SetConnMaxIdleTime(0)
+	}
+}
+
+// Reload applies the pool settings of cm to the connected clients. Changes of anything else, such as the
+// address or the credentials, need a restart, so cm is rejected as a whole when it contains any of them.
+func Reload(cm components.ConfigMap[*Config]) error {
+	current := GetConfig()
+	for k, v := range cm {
+		v.SetDefault()
+		old, ok := current[k]
+		if !ok {
+			return fmt.Errorf("mysql client %s is new and needs a restart", k)
+		}
+
+		if v.Dialect != old.Dialect || v.ToDSN() != old.ToDSN() {
+			return fmt.Errorf("connection settings of mysql client %s changed and need a restart", k)
+		}
 	}
 
-	var connPoll gorm.ConnPool = originDB
+	next := make(components.ConfigMap[*Config], len(current))
+	for k, v := range current {
+		next[k] = v
+	}
 
-	dialector := mysql.New(mysql.Config{
-		Conn: connPoll,
-	})
+	for k, v := range cm {
+		db, err := GetDBClient(context.Background(), k).DB()
+		if err != nil {
+			setConfig(next)
+			return err
+		}
 
-	return gorm.Open(dialector, &gorm.Config{
-		NamingStrategy: schema.NamingStrategy{
-			SingularTable: true,
-		},
-	})
+		setPool(db, v)
+		next[k] = v
+		log.Infof("reloaded pool settings of mysql client %s", k)
+	}
+
+	setConfig(next)
+	return nil
 }
 
 func getKey(keys ...string) string {
@@ -101,12 +182,36 @@ func IsHealth() (err error) {
 			return false
 		}
 
-		err = db.Ping()
+		ctx, cancel := context.WithTimeout(context.Background(), components.HealthCheckTimeout)
+		defer cancel()
+
+		err = db.PingContext(ctx)
 		if err != nil {
+			log.Errorf("mysql health check failed, client key: %s", key)
 			return false
 		}
 
-		log.Infof("mysql health check success, client key: %s", key)
+		log.Debugf("mysql health check success, client key: %s", key)
+		return true
+	})
+
+	return err
+}
+
+// Close closes the connection pools of all clients
+func Close() (err error) {
+	globalClientMap.Range(func(key, value any) bool {
+		db, e := value.(*gorm.DB).DB()
+		if e == nil {
+			e = db.Close()
+		}
+
+		if e != nil {
+			log.Errorf("failed to close mysql client %s: %v", key, e)
+			err = e
+		}
+
+		globalClientMap.Delete(key)
 		return true
 	})
 
@@ -0,0 +1,62 @@
+package redisx
+
+import (
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/redis/go-redis/v9"
+)
+
+var (
+	hitsDesc = prometheus.NewDesc(
+		"redis_pool_hits_total", "Number of times a free connection was found in the pool.", []string{"client"}, nil,
+	)
+	missesDesc = prometheus.NewDesc(
+		"redis_pool_misses_total", "Number of times a free connection was not found in the pool.", []string{"client"}, nil,
+	)
+	timeoutsDesc = prometheus.NewDesc(
+		"redis_pool_timeouts_total", "Number of times a wait for a connection timed out.", []string{"client"}, nil,
+	)
+	totalConnsDesc = prometheus.NewDesc(
+		"redis_pool_connections", "Number of connections in the pool.", []string{"client"}, nil,
+	)
+	idleConnsDesc = prometheus.NewDesc(
+		"redis_pool_idle_connections", "Number of idle connections in the pool.", []string{"client"}, nil,
+	)
+	staleConnsDesc = prometheus.NewDesc(
+		"redis_pool_stale_connections_total", "Number of stale connections removed from the pool.", []string{"client"}, nil,
+	)
+)
+
+// Collector exports the connection pool stats of every client, a client is labelled as config key.db name
+func Collector() prometheus.Collector {
+	return poolCollector{}
+}
+
+type poolCollector struct{}
+
+func (poolCollector) Describe(ch chan<- *prometheus.Desc) {
+	ch <- hitsDesc
+	ch <- missesDesc
+	ch <- timeoutsDesc
+	ch <- totalConnsDesc
+	ch <- idleConnsDesc
+	ch <- staleConnsDesc
+}
+
+func (poolCollector) Collect(ch chan<- prometheus.Metric) {
+	globalClientMap.Range(func(key, value any) bool {
+		client, ok1 := key.(string)
+		pool, ok2 := value.(interface{ PoolStats() *redis.PoolStats })
+		if !ok1 || !ok2 {
+			return true
+		}
+
+		stats := pool.PoolStats()
+		ch <- prometheus.MustNewConstMetric(hitsDesc, prometheus.CounterValue, float64(stats.Hits), client)
+		ch <- prometheus.MustNewConstMetric(missesDesc, prometheus.CounterValue, float64(stats.Misses), client)
+		ch <- prometheus.MustNewConstMetric(timeoutsDesc, prometheus.CounterValue, float64(stats.Timeouts), client)
+		ch <- prometheus.MustNewConstMetric(totalConnsDesc, prometheus.GaugeValue, float64(stats.TotalConns), client)
+		ch <- prometheus.MustNewConstMetric(idleConnsDesc, prometheus.GaugeValue, float64(stats.IdleConns), client)
+		ch <- prometheus.MustNewConstMetric(staleConnsDesc, prometheus.CounterValue, float64(stats.StaleConns), client)
+		return true
+	})
+}
@@ -80,13 +80,31 @@ func GetClient(ctx context.Context, keys ...string) *redis.Client {
 func IsHealth() (err error) {
 	globalClientMap.Range(func(key, value any) bool {
 		client := value.(*redis.Client)
-		err = client.Ping(context.Background()).Err()
+		ctx, cancel := context.WithTimeout(context.Background(), components.HealthCheckTimeout)
+		defer cancel()
+
+		err = client.Ping(ctx).Err()
 		if err != nil {
 			log.Errorf("redis health check failed, client key: %s", key)
 			return false
 		}
 
-		log.Infof("redis health check success, client key: %s", key)
+		log.Debugf("redis health check success, client key: %s", key)
+		return true
+	})
+
+	return err
+}
+
+// Close closes all clients
+func Close() (err error) {
+	globalClientMap.Range(func(key, value any) bool {
+		if e := value.(*redis.Client).Close(); e != nil {
+			log.Errorf("failed to close redis client %s: %v", key, e)
+			err = e
+		}
+
+		globalClientMap.Delete(key)
 		return true
 	})
 
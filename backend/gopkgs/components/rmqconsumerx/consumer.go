@@ -6,11 +6,14 @@ import (
 	"github.com/apache/rocketmq-client-go/v2"
 	"github.com/apache/rocketmq-client-go/v2/consumer"
 	"github.com/cloudzenith/DouTok/backend/gopkgs/components"
+	"github.com/go-kratos/kratos/v2/log"
 	"sync"
 )
 
 var (
 	globalClientMap = sync.Map{}
+	globalConfigMap = sync.Map{}
+	consumerFactory func(c *Config) (rocketmq.PushConsumer, error)
 )
 
 func Init(cm components.ConfigMap[*Config]) (func() error, error) {
@@ -21,11 +24,25 @@ func Init(cm components.ConfigMap[*Config]) (func() error, error) {
 	return IsHealth, nil
 }
 
-func Connect(configKey string, c *Config) {
-	p, err := rocketmq.NewPushConsumer(
+// SetConsumerFactory replaces how consumers are created, such as with an in-memory bus in tests,
+// the health check does not dial the name servers then
+func SetConsumerFactory(factory func(c *Config) (rocketmq.PushConsumer, error)) {
+	consumerFactory = factory
+}
+
+func newClient(c *Config) (rocketmq.PushConsumer, error) {
+	if consumerFactory != nil {
+		return consumerFactory(c)
+	}
+
+	return rocketmq.NewPushConsumer(
 		consumer.WithNameServer([]string{c.NameServer}),
 		consumer.WithGroupName(c.ConsumerGroup),
 	)
+}
+
+func Connect(configKey string, c *Config) {
+	p, err := newClient(c)
 	if err != nil {
 		panic(err)
 	}
@@ -35,6 +52,7 @@ func Connect(configKey string, c *Config) {
 	}
 
 	globalClientMap.Store(configKey, p)
+	globalConfigMap.Store(configKey, c)
 }
 
 func GetClient(ctx context.Context, keys ...string) rocketmq.PushConsumer {
@@ -54,6 +72,51 @@ func GetConsumer[T any](ctx context.Context, topic string, keys ...string) *Cons
 	return newConsumer[T](GetClient(ctx, keys...), topic)
 }
 
+// IsHealth checks that the name servers of every consumer are reachable
 func IsHealth() (err error) {
+	if consumerFactory != nil {
+		return nil
+	}
+
+	globalConfigMap.Range(func(key, value any) bool {
+		ctx, cancel := context.WithTimeout(context.Background(), components.HealthCheckTimeout)
+		defer cancel()
+
+		err = components.DialAddresses(ctx, value.(*Config).NameServer)
+		if err != nil {
+			log.Errorf("rocket mq consumer health check failed, client key: %s", key)
+			return false
+		}
+
+		return true
+	})
+
+	return err
+}
+
+// Suspend stops all consumers from pulling new messages, the messages being consumed are finished
+func Suspend() error {
+	globalClientMap.Range(func(key, value any) bool {
+		value.(rocketmq.PushConsumer).Suspend()
+		log.Infof("suspended rocket mq consumer %s", key)
+		return true
+	})
+
+	return nil
+}
+
+// Close shuts all consumers down
+func Close() (err error) {
+	globalClientMap.Range(func(key, value any) bool {
+		if e := value.(rocketmq.PushConsumer).Shutdown(); e != nil {
+			log.Errorf("failed to shutdown rocket mq consumer %s: %v", key, e)
+			err = e
+		}
+
+		globalClientMap.Delete(key)
+		globalConfigMap.Delete(key)
+		return true
+	})
+
 	return err
 }
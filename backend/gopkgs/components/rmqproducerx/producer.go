@@ -6,11 +6,14 @@ import (
 	"github.com/apache/rocketmq-client-go/v2"
 	"github.com/apache/rocketmq-client-go/v2/producer"
 	"github.com/cloudzenith/DouTok/backend/gopkgs/components"
+	"github.com/go-kratos/kratos/v2/log"
 	"sync"
 )
 
 var (
 	globalClientMap = sync.Map{}
+	globalConfigMap = sync.Map{}
+	producerFactory func(c *Config) (rocketmq.Producer, error)
 )
 
 func Init(cm components.ConfigMap[*Config]) (func() error, error) {
@@ -21,10 +24,24 @@ func Init(cm components.ConfigMap[*Config]) (func() error, error) {
 	return IsHealth, nil
 }
 
-func Connect(configKey string, c *Config) {
-	p, err := rocketmq.NewProducer(
+// SetProducerFactory replaces how producers are created, such as with an in-memory bus in tests,
+// the health check does not dial the name servers then
+func SetProducerFactory(factory func(c *Config) (rocketmq.Producer, error)) {
+	producerFactory = factory
+}
+
+func newClient(c *Config) (rocketmq.Producer, error) {
+	if producerFactory != nil {
+		return producerFactory(c)
+	}
+
+	return rocketmq.NewProducer(
 		producer.WithNameServer([]string{c.NameServer}),
 	)
+}
+
+func Connect(configKey string, c *Config) {
+	p, err := newClient(c)
 	if err != nil {
 		panic(err)
 	}
@@ -34,6 +51,7 @@ func Connect(configKey string, c *Config) {
 	}
 
 	globalClientMap.Store(configKey, p)
+	globalConfigMap.Store(configKey, c)
 }
 
 func GetClient(ctx context.Context, keys ...string) rocketmq.Producer {
@@ -53,6 +71,40 @@ func GetProducer[T any](ctx context.Context, topic string, keys ...string) *Prod
 	return newProducer[T](GetClient(ctx, keys...), topic)
 }
 
+// IsHealth checks that the name servers of every producer are reachable
 func IsHealth() (err error) {
+	if producerFactory != nil {
+		return nil
+	}
+
+	globalConfigMap.Range(func(key, value any) bool {
+		ctx, cancel := context.WithTimeout(context.Background(), components.HealthCheckTimeout)
+		defer cancel()
+
+		err = components.DialAddresses(ctx, value.(*Config).NameServer)
+		if err != nil {
+			log.Errorf("rocket mq producer health check failed, client key: %s", key)
+			return false
+		}
+
+		return true
+	})
+
+	return err
+}
+
+// Close shuts all producers down
+func Close() (err error) {
+	globalClientMap.Range(func(key, value any) bool {
+		if e := value.(rocketmq.Producer).Shutdown(); e != nil {
+			log.Errorf("failed to shutdown rocket mq producer %s: %v", key, e)
+			err = e
+		}
+
+		globalClientMap.Delete(key)
+		globalConfigMap.Delete(key)
+		return true
+	})
+
 	return err
 }
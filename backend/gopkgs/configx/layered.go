@@ -0,0 +1,238 @@
+// Package configx layers config sources, from the lowest to the highest precedence:
+//
+//	file defaults < consul kv or etcd < environment variables < command line overrides
+//
+// Documents of the lower layers are merged key by key, and the environment variables and command line overrides
+// replace single keys. The layer every key came from is recorded, so the effective config can be printed
+// with its origins.
+package configx
+
+import (
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/go-kratos/kratos/v2/config"
+	"github.com/go-kratos/kratos/v2/encoding"
+)
+
+const layeredKey = "layered"
+
+// Layer is a config source, its name is the origin of the keys it sets
+type Layer struct {
+	name   string
+	source config.Source
+	// overrides sets single keys on the config merged from the lower layers
+	overrides func(tree map[string]interface{}) ([]override, error)
+}
+
+type override struct {
+	path  []string
+	value interface{}
+}
+
+// NewLayer makes a layer of a config source whose documents are merged into the lower layers
+func NewLayer(name string, source config.Source) Layer {
+	return Layer{name: name, source: source}
+}
+
+// Source merges its layers into a single json document, it is used as the only source of the launcher config
+type Source struct {
+	layers []Layer
+
+	mu      sync.RWMutex
+	origins map[string]string
+	tree    map[string]interface{}
+}
+
+func New(layers ...Layer) *Source {
+	return &Source{layers: layers}
+}
+
+func (s *Source) Load() ([]*config.KeyValue, error) {
+	tree := make(map[string]interface{})
+	origins := make(map[string]string)
+
+	for _, layer := range s.layers {
+		if layer.source != nil {
+			kvs, err := layer.source.Load()
+			if err != nil {
+				return nil, fmt.Errorf("load config layer %s: %w", layer.name, err)
+			}
+
+			for _, kv := range kvs {
+				doc, err := decode(kv)
+				if err != nil {
+					return nil, fmt.Errorf("decode %s of config layer %s: %w", kv.Key, layer.name, err)
+				}
+				merge(tree, doc, nil, layer.name, origins)
+			}
+		}
+
+		if layer.overrides != nil {
+			overrides, err := layer.overrides(tree)
+			if err != nil {
+				return nil, fmt.Errorf("config layer %s: %w", layer.name, err)
+			}
+
+			for _, o := range overrides {
+				set(tree, o.path, o.value)
+				setOrigin(origins, o.path, o.value, layer.name)
+			}
+		}
+	}
+
+	value, err := json.Marshal(tree)
+	if err != nil {
+		return nil, err
+	}
+
+	s.mu.Lock()
+	s.tree = tree
+	s.origins = origins
+	s.mu.Unlock()
+
+	return []*config.KeyValue{{Key: layeredKey, Value: value, Format: "json"}}, nil
+}
+
+// Origins returns the layer every key of the last loaded config came from, keys are joined with dots
+func (s *Source) Origins() map[string]string {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	origins := make(map[string]string, len(s.origins))
+	for k, v := range s.origins {
+		origins[k] = v
+	}
+
+	return origins
+}
+
+// Dump prints every key of the last loaded config with its origin, one per line, secrets are redacted
+func (s *Source) Dump() string {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	keys := make([]string, 0, len(s.origins))
+	for k := range s.origins {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	var b strings.Builder
+	for _, k := range keys {
+		value := redact(k, get(s.tree, strings.Split(k, ".")))
+		fmt.Fprintf(&b, "%s = %v (%s)\n", k, value, s.origins[k])
+	}
+
+	return b.String()
+}
+
+func decode(kv *config.KeyValue) (map[string]interface{}, error) {
+	doc := make(map[string]interface{})
+	if kv.Format == "" {
+		set(doc, strings.Split(kv.Key, "."), string(kv.Value))
+		return doc, nil
+	}
+
+	codec := encoding.GetCodec(kv.Format)
+	if codec == nil {
+		return nil, fmt.Errorf("unsupported format %s", kv.Format)
+	}
+
+	if err := codec.Unmarshal(kv.Value, &doc); err != nil {
+		return nil, err
+	}
+
+	return normalize(doc).(map[string]interface{}), nil
+}
+
+// normalize converts the map[interface{}]interface{} of some yaml decoders into map[string]interface{}
+func normalize(v interface{}) interface{} {
+	switch value := v.(type) {
+	case map[string]interface{}:
+		for k, item := range value {
+			value[k] = normalize(item)
+		}
+		return value
+	case map[interface{}]interface{}:
+		m := make(map[string]interface{}, len(value))
+		for k, item := range value {
+			m[fmt.Sprint(k)] = normalize(item)
+		}
+		return m
+	case []interface{}:
+		for i, item := range value {
+			value[i] = normalize(item)
+		}
+		return value
+	default:
+		return v
+	}
+}
+
+// merge copies src into dst, maps are merged key by key and anything else is replaced
+func merge(dst, src map[string]interface{}, prefix []string, origin string, origins map[string]string) {
+	for k, v := range src {
+		path := append(append([]string{}, prefix...), k)
+		if m, ok := v.(map[string]interface{}); ok {
+			sub, ok := dst[k].(map[string]interface{})
+			if !ok {
+				sub = make(map[string]interface{})
+				dst[k] = sub
+			}
+			merge(sub, m, path, origin, origins)
+			continue
+		}
+
+		dst[k] = v
+		setOrigin(origins, path, v, origin)
+	}
+}
+
+func setOrigin(origins map[string]string, path []string, value interface{}, origin string) {
+	key := strings.Join(path, ".")
+	for k := range origins {
+		// a key replacing a map replaces the keys below it
+		if strings.HasPrefix(k, key+".") {
+			delete(origins, k)
+		}
+	}
+
+	if m, ok := value.(map[string]interface{}); ok {
+		for k, v := range m {
+			setOrigin(origins, append(append([]string{}, path...), k), v, origin)
+		}
+		return
+	}
+
+	origins[key] = origin
+}
+
+func set(tree map[string]interface{}, path []string, value interface{}) {
+	for _, k := range path[:len(path)-1] {
+		sub, ok := tree[k].(map[string]interface{})
+		if !ok {
+			sub = make(map[string]interface{})
+			tree[k] = sub
+		}
+		tree = sub
+	}
+
+	tree[path[len(path)-1]] = value
+}
+
+func get(tree map[string]interface{}, path []string) interface{} {
+	var value interface{} = tree
+	for _, k := range path {
+		m, ok := value.(map[string]interface{})
+		if !ok {
+			return nil
+		}
+		value = m[k]
+	}
+
+	return value
+}
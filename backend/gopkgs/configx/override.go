@@ -0,0 +1,153 @@
+package configx
+
+import (
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+
+	"github.com/go-kratos/kratos/v2/config/file"
+	"gopkg.in/yaml.v2"
+)
+
+const (
+	originFile  = "file"
+	originEnv   = "env"
+	originFlags = "flags"
+
+	setFlag = "--set"
+)
+
+// File loads the yaml, json or xml files of path, a file or a directory
+func File(path string) Layer {
+	return NewLayer(originFile, file.NewSource(path))
+}
+
+// Env overrides single keys with the environment variables starting with prefix, such as
+// DOUTOK_COMPONENTS_MYSQL_DEFAULT_PASSWORD for components.mysql.default.password with the prefix DOUTOK_.
+// Underscores are matched against the keys of the lower layers, so keys containing underscores such as max_idle
+// are found, a double underscore separates the keys explicitly: DOUTOK_COMPONENTS__MYSQL__REPLICA__MAX_IDLE.
+func Env(prefix string) Layer {
+	return Layer{
+		name: originEnv,
+		overrides: func(tree map[string]interface{}) ([]override, error) {
+			var overrides []override
+			for _, env := range os.Environ() {
+				name, value, ok := strings.Cut(env, "=")
+				if !ok || !strings.HasPrefix(name, prefix) || name == prefix {
+					continue
+				}
+
+				path := resolvePath(tree, strings.ToLower(strings.TrimPrefix(name, prefix)))
+				overrides = append(overrides, override{path: path, value: parseValue(tree, path, value)})
+			}
+
+			return overrides, nil
+		},
+	}
+}
+
+// Flags overrides single keys with the --set key=value arguments of args, such as
+// --set components.mysql.default.host=127.0.0.1, the other arguments are ignored
+func Flags(args []string) Layer {
+	return Layer{
+		name: originFlags,
+		overrides: func(tree map[string]interface{}) ([]override, error) {
+			var overrides []override
+			for i := 0; i < len(args); i++ {
+				var assignment string
+				switch {
+				case args[i] == setFlag && i+1 < len(args):
+					i++
+					assignment = args[i]
+				case strings.HasPrefix(args[i], setFlag+"="):
+					assignment = strings.TrimPrefix(args[i], setFlag+"=")
+				default:
+					continue
+				}
+
+				key, value, ok := strings.Cut(assignment, "=")
+				if !ok || key == "" {
+					return nil, fmt.Errorf("invalid %s %s, it should be key=value", setFlag, assignment)
+				}
+
+				path := strings.Split(key, ".")
+				overrides = append(overrides, override{path: path, value: parseValue(tree, path, value)})
+			}
+
+			return overrides, nil
+		},
+	}
+}
+
+// resolvePath splits name into keys, preferring the longest keys present in tree
+func resolvePath(tree map[string]interface{}, name string) []string {
+	if strings.Contains(name, "__") {
+		return strings.Split(name, "__")
+	}
+
+	parts := strings.Split(name, "_")
+	path := make([]string, 0, len(parts))
+	node := tree
+	for len(parts) > 0 {
+		matched := 0
+		for n := len(parts); n > 0; n-- {
+			if _, ok := node[strings.Join(parts[:n], "_")]; ok {
+				matched = n
+				break
+			}
+		}
+
+		// an unknown key takes the rest of the name
+		if matched == 0 {
+			path = append(path, strings.Join(parts, "_"))
+			break
+		}
+
+		key := strings.Join(parts[:matched], "_")
+		path = append(path, key)
+		parts = parts[matched:]
+
+		sub, ok := node[key].(map[string]interface{})
+		if !ok {
+			if len(parts) > 0 {
+				path[len(path)-1] = strings.Join(append([]string{key}, parts...), "_")
+			}
+			break
+		}
+		node = sub
+	}
+
+	return path
+}
+
+// parseValue keeps the type of the value being overridden, a new key gets the type its yaml scalar has
+func parseValue(tree map[string]interface{}, path []string, raw string) interface{} {
+	switch get(tree, path).(type) {
+	case string:
+		return raw
+	case bool:
+		if v, err := strconv.ParseBool(raw); err == nil {
+			return v
+		}
+	case int, int64, uint64, float64:
+		if v, err := strconv.ParseInt(raw, 10, 64); err == nil {
+			return v
+		}
+		if v, err := strconv.ParseFloat(raw, 64); err == nil {
+			return v
+		}
+	}
+
+	var value interface{}
+	if err := yaml.Unmarshal([]byte(raw), &value); err != nil || value == nil {
+		return raw
+	}
+
+	switch value.(type) {
+	case map[interface{}]interface{}, []interface{}:
+		return raw
+	default:
+		return value
+	}
+}
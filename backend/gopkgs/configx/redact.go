@@ -0,0 +1,55 @@
+package configx
+
+import (
+	"net/url"
+	"strings"
+)
+
+const redacted = "******"
+
+var secretKeywords = []string{"password", "secret", "token", "credential", "private_key", "access_key"}
+
+// Redact returns a copy of tree with the values of secret keys and the passwords of urls hidden
+func Redact(tree map[string]interface{}) map[string]interface{} {
+	return redactTree("", tree)
+}
+
+func redactTree(prefix string, tree map[string]interface{}) map[string]interface{} {
+	redacted := make(map[string]interface{}, len(tree))
+	for k, v := range tree {
+		key := k
+		if prefix != "" {
+			key = prefix + "." + k
+		}
+
+		if sub, ok := v.(map[string]interface{}); ok {
+			redacted[k] = redactTree(key, sub)
+			continue
+		}
+		redacted[k] = redact(key, v)
+	}
+
+	return redacted
+}
+
+// redact hides the values of secret keys and the passwords of urls
+func redact(key string, value interface{}) interface{} {
+	name := strings.ToLower(key[strings.LastIndex(key, ".")+1:])
+	for _, keyword := range secretKeywords {
+		if strings.Contains(name, keyword) {
+			return redacted
+		}
+	}
+
+	s, ok := value.(string)
+	if !ok || !strings.Contains(s, "://") {
+		return value
+	}
+
+	u, err := url.Parse(s)
+	if err != nil {
+		return value
+	}
+
+	return u.Redacted()
+}
@@ -0,0 +1,165 @@
+package configx
+
+import (
+	"context"
+	"fmt"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/go-kratos/kratos/v2/config"
+	"github.com/hashicorp/consul/api"
+	clientv3 "go.etcd.io/etcd/client/v3"
+)
+
+const (
+	originConsul = "consul"
+	originEtcd   = "etcd"
+
+	remoteTimeout = 5 * time.Second
+)
+
+// format is the extension of key, a key without extension holds yaml
+func format(key string) string {
+	if ext := strings.TrimPrefix(filepath.Ext(key), "."); ext != "" {
+		return ext
+	}
+
+	return "yaml"
+}
+
+// Consul loads the document stored under key of the consul kv store, such as doutok/baseService/config.yaml,
+// a missing key is an empty layer
+func Consul(client *api.Client, key string) Layer {
+	return NewLayer(originConsul, &consulSource{client: client, key: key})
+}
+
+type consulSource struct {
+	client *api.Client
+	key    string
+}
+
+func (s *consulSource) Load() ([]*config.KeyValue, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), remoteTimeout)
+	defer cancel()
+
+	pair, _, err := s.client.KV().Get(s.key, (&api.QueryOptions{}).WithContext(ctx))
+	if err != nil || pair == nil {
+		return nil, err
+	}
+
+	return []*config.KeyValue{{Key: s.key, Value: pair.Value, Format: format(s.key)}}, nil
+}
+
+func (s *consulSource) Watch() (config.Watcher, error) {
+	ctx, cancel := context.WithCancel(context.Background())
+	return &consulWatcher{source: s, ctx: ctx, cancel: cancel}, nil
+}
+
+// consulWatcher waits for changes of the key with blocking queries
+type consulWatcher struct {
+	source    *consulSource
+	ctx       context.Context
+	cancel    context.CancelFunc
+	lastIndex uint64
+}
+
+func (w *consulWatcher) Next() ([]*config.KeyValue, error) {
+	for {
+		options := (&api.QueryOptions{WaitIndex: w.lastIndex}).WithContext(w.ctx)
+		pair, meta, err := w.source.client.KV().Get(w.source.key, options)
+		if err != nil {
+			return nil, err
+		}
+
+		changed := w.lastIndex != 0 && meta.LastIndex != w.lastIndex
+		w.lastIndex = meta.LastIndex
+		if !changed {
+			continue
+		}
+
+		if pair == nil {
+			return nil, nil
+		}
+		return []*config.KeyValue{{Key: w.source.key, Value: pair.Value, Format: format(w.source.key)}}, nil
+	}
+}
+
+func (w *consulWatcher) Stop() error {
+	w.cancel()
+	return nil
+}
+
+// Etcd loads the document stored under key of etcd, such as /doutok/baseService/config.yaml,
+// a missing key is an empty layer
+func Etcd(client *clientv3.Client, key string) Layer {
+	return NewLayer(originEtcd, &etcdSource{client: client, key: key})
+}
+
+type etcdSource struct {
+	client *clientv3.Client
+	key    string
+}
+
+func (s *etcdSource) Load() ([]*config.KeyValue, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), remoteTimeout)
+	defer cancel()
+
+	resp, err := s.client.Get(ctx, s.key)
+	if err != nil || len(resp.Kvs) == 0 {
+		return nil, err
+	}
+
+	return []*config.KeyValue{{Key: s.key, Value: resp.Kvs[0].Value, Format: format(s.key)}}, nil
+}
+
+func (s *etcdSource) Watch() (config.Watcher, error) {
+	return newEtcdWatcher(s.client, s.key), nil
+}
+
+type etcdWatcher struct {
+	ctx     context.Context
+	cancel  context.CancelFunc
+	client  clientv3.Watcher
+	key     string
+	ch      clientv3.WatchChan
+	retried bool
+}
+
+func newEtcdWatcher(client clientv3.Watcher, key string) *etcdWatcher {
+	ctx, cancel := context.WithCancel(context.Background())
+	return &etcdWatcher{ctx: ctx, cancel: cancel, client: client, key: key, ch: client.Watch(ctx, key)}
+}
+
+// Next only signals the change, the layered source loads all layers again.
+// etcd closes the watch channel when it cancels the watch, such as on compaction, the watch is created again
+// and a change is signalled so the changes in between are loaded, the channel closing again without any
+// response in between is an error, such as for a closed client
+func (w *etcdWatcher) Next() ([]*config.KeyValue, error) {
+	select {
+	case <-w.ctx.Done():
+		return nil, w.ctx.Err()
+	case resp, ok := <-w.ch:
+		if ok {
+			w.retried = false
+			return nil, resp.Err()
+		}
+	}
+
+	if w.ctx.Err() != nil {
+		return nil, w.ctx.Err()
+	}
+
+	w.ch = w.client.Watch(w.ctx, w.key)
+	if w.retried {
+		return nil, fmt.Errorf("etcd watch of %s closed, watching again", w.key)
+	}
+
+	w.retried = true
+	return nil, nil
+}
+
+func (w *etcdWatcher) Stop() error {
+	w.cancel()
+	return nil
+}
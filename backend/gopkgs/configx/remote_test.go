@@ -0,0 +1,58 @@
+package configx
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	clientv3 "go.etcd.io/etcd/client/v3"
+)
+
+type testEtcdWatcher struct {
+	watches int
+	chs     []chan clientv3.WatchResponse
+}
+
+func (w *testEtcdWatcher) Watch(_ context.Context, _ string, _ ...clientv3.OpOption) clientv3.WatchChan {
+	w.watches++
+	ch := make(chan clientv3.WatchResponse, 1)
+	w.chs = append(w.chs, ch)
+	return ch
+}
+
+func (w *testEtcdWatcher) RequestProgress(_ context.Context) error {
+	return nil
+}
+
+func (w *testEtcdWatcher) Close() error {
+	return nil
+}
+
+func TestEtcdWatcher_ClosedChannel(t *testing.T) {
+	client := &testEtcdWatcher{}
+	w := newEtcdWatcher(client, "/doutok/baseService/config.yaml")
+	defer func() {
+		_ = w.Stop()
+	}()
+
+	close(client.chs[0])
+	_, err := w.Next()
+	assert.Nil(t, err)
+	assert.Equal(t, 2, client.watches)
+
+	client.chs[1] <- clientv3.WatchResponse{}
+	_, err = w.Next()
+	assert.Nil(t, err)
+
+	close(client.chs[1])
+	_, err = w.Next()
+	assert.Nil(t, err)
+	close(client.chs[2])
+	_, err = w.Next()
+	assert.NotNil(t, err)
+	assert.Equal(t, 4, client.watches)
+
+	assert.Nil(t, w.Stop())
+	_, err = w.Next()
+	assert.ErrorIs(t, err, context.Canceled)
+}
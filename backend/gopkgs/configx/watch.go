@@ -0,0 +1,88 @@
+package configx
+
+import (
+	"context"
+	"time"
+
+	"github.com/go-kratos/kratos/v2/config"
+	"github.com/go-kratos/kratos/v2/log"
+)
+
+const watchRetryInterval = time.Second
+
+// watcher reloads all layers when any of them changes, the environment and the command line are not watched
+type watcher struct {
+	source   *Source
+	ctx      context.Context
+	cancel   context.CancelFunc
+	changed  chan struct{}
+	watchers []config.Watcher
+}
+
+func (s *Source) Watch() (config.Watcher, error) {
+	ctx, cancel := context.WithCancel(context.Background())
+	w := &watcher{
+		source:  s,
+		ctx:     ctx,
+		cancel:  cancel,
+		changed: make(chan struct{}, 1),
+	}
+
+	for _, layer := range s.layers {
+		if layer.source == nil {
+			continue
+		}
+
+		lw, err := layer.source.Watch()
+		if err != nil {
+			_ = w.Stop()
+			return nil, err
+		}
+
+		w.watchers = append(w.watchers, lw)
+		go w.watch(layer.name, lw)
+	}
+
+	return w, nil
+}
+
+func (w *watcher) watch(name string, lw config.Watcher) {
+	for {
+		_, err := lw.Next()
+		if w.ctx.Err() != nil {
+			return
+		}
+
+		if err != nil {
+			log.Warnf("failed to watch config layer %s: %v", name, err)
+			time.Sleep(watchRetryInterval)
+			continue
+		}
+
+		select {
+		case w.changed <- struct{}{}:
+		default:
+		}
+	}
+}
+
+func (w *watcher) Next() ([]*config.KeyValue, error) {
+	select {
+	case <-w.ctx.Done():
+		return nil, w.ctx.Err()
+	case <-w.changed:
+	}
+
+	return w.source.Load()
+}
+
+func (w *watcher) Stop() error {
+	w.cancel()
+	for _, lw := range w.watchers {
+		if err := lw.Stop(); err != nil {
+			log.Warnf("failed to stop config watcher: %v", err)
+		}
+	}
+
+	return nil
+}
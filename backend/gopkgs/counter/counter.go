@@ -0,0 +1,488 @@
+package counter
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/cloudzenith/DouTok/backend/gopkgs/cachex"
+	"github.com/cloudzenith/DouTok/backend/gopkgs/gofer"
+	"github.com/go-kratos/kratos/v2/log"
+	"github.com/google/uuid"
+	"github.com/redis/go-redis/v9"
+	"github.com/samber/lo"
+	"gorm.io/gorm"
+)
+
+const (
+	defaultFlushInterval = 5 * time.Second
+	defaultBatchSize     = 200
+	defaultCacheTTL      = 10 * time.Minute
+	defaultIdColumn      = "id"
+	defaultLeaseTTL      = 10 * time.Second
+	maxLoadAttempts      = 3
+	loadRetryBackoff     = 20 * time.Millisecond
+)
+
+// ErrFlushInProgress is returned by Get when the counts could not be read while a flush was writing the table
+var ErrFlushInProgress = errors.New("counter is being flushed")
+
+// incrScript adds the delta to the pending deltas, and to the cached counts when they are cached.
+// It drops the lease of a load in progress, so that the load does not cache counts without the delta.
+var incrScript = redis.NewScript(`
+if redis.call('EXISTS', KEYS[1]) == 1 then
+	redis.call('HINCRBY', KEYS[1], ARGV[1], ARGV[3])
+end
+redis.call('DEL', KEYS[3])
+return redis.call('HINCRBY', KEYS[2], ARGV[2], ARGV[3])
+`)
+
+// cacheScript caches the loaded counts only if the lease of the load is still held
+var cacheScript = redis.NewScript(`
+if redis.call('GET', KEYS[2]) ~= ARGV[1] then
+	return 0
+end
+redis.call('DEL', KEYS[2])
+redis.call('HSET', KEYS[1], unpack(ARGV, 3))
+redis.call('EXPIRE', KEYS[1], ARGV[2])
+return 1
+`)
+
+// takeDeltasScript moves the pending deltas aside for flushing, unless deltas of a failed flush are left
+var takeDeltasScript = redis.NewScript(`
+if redis.call('EXISTS', KEYS[2]) == 0 and redis.call('EXISTS', KEYS[1]) == 1 then
+	redis.call('RENAME', KEYS[1], KEYS[2])
+end
+return redis.call('EXISTS', KEYS[2])
+`)
+
+// Counter keeps counts such as likes and comments of rows of a table. Increments are applied to redis
+// synchronously and flushed to the table in batches by a background worker, reads are served from redis.
+type Counter struct {
+	name          string
+	client        *redis.Client
+	db            *gorm.DB
+	table         string
+	idColumn      string
+	columns       map[string]string
+	flushInterval time.Duration
+	batchSize     int
+	cacheTTL      time.Duration
+	locker        *cachex.LockHandle
+
+	started  atomic.Bool
+	stopOnce sync.Once
+	stop     chan struct{}
+	stopped  chan struct{}
+}
+
+// New creates a counter, name is used as the namespace of its redis keys
+func New(name string, client *redis.Client, db *gorm.DB, options ...Option) *Counter {
+	c := &Counter{
+		name:          name,
+		client:        client,
+		db:            db,
+		table:         name,
+		idColumn:      defaultIdColumn,
+		columns:       make(map[string]string),
+		flushInterval: defaultFlushInterval,
+		batchSize:     defaultBatchSize,
+		cacheTTL:      defaultCacheTTL,
+		stop:          make(chan struct{}),
+		stopped:       make(chan struct{}),
+	}
+
+	for _, option := range options {
+		option(c)
+	}
+
+	if len(c.columns) == 0 {
+		panic("counter should have at least one field")
+	}
+
+	c.locker = cachex.New(client, c.key("lock"), c.flushInterval*2, c.flushInterval*2)
+	return c
+}
+
+func (c *Counter) key(suffix string) string {
+	return fmt.Sprintf("counter:%s:%s", c.name, suffix)
+}
+
+func (c *Counter) countsKey(id int64) string {
+	return c.key(strconv.FormatInt(id, 10))
+}
+
+func (c *Counter) leaseKey(id int64) string {
+	return c.countsKey(id) + ":lease"
+}
+
+func (c *Counter) deltaField(id int64, field string) string {
+	return fmt.Sprintf("%d:%s", id, field)
+}
+
+func parseDeltaField(deltaField string) (int64, string, error) {
+	idStr, field, ok := strings.Cut(deltaField, ":")
+	if !ok {
+		return 0, "", fmt.Errorf("invalid delta field %s", deltaField)
+	}
+
+	id, err := strconv.ParseInt(idStr, 10, 64)
+	return id, field, err
+}
+
+// Incr adds delta to the field of the row id, use a negative delta to decrease it
+func (c *Counter) Incr(ctx context.Context, id int64, field string, delta int64) error {
+	if _, ok := c.columns[field]; !ok {
+		return fmt.Errorf("unknown counter field %s", field)
+	}
+
+	return incrScript.Run(
+		ctx, c.client, []string{c.countsKey(id), c.key("delta"), c.leaseKey(id)},
+		field, c.deltaField(id, field), delta,
+	).Err()
+}
+
+// Get returns the counts of ids, counts not cached in redis are read from the table in one query
+// together with the deltas not flushed yet
+func (c *Counter) Get(ctx context.Context, ids []int64) (map[int64]map[string]int64, error) {
+	ids = lo.Uniq(ids)
+
+	pipe := c.client.Pipeline()
+	cmds := lo.Map(ids, func(id int64, _ int) *redis.MapStringStringCmd {
+		return pipe.HGetAll(ctx, c.countsKey(id))
+	})
+	if _, err := pipe.Exec(ctx); err != nil {
+		return nil, err
+	}
+
+	result := make(map[int64]map[string]int64, len(ids))
+	missing := make([]int64, 0)
+	for i, cmd := range cmds {
+		if len(cmd.Val()) == 0 {
+			missing = append(missing, ids[i])
+			continue
+		}
+
+		counts := make(map[string]int64, len(cmd.Val()))
+		for field, value := range cmd.Val() {
+			counts[field], _ = strconv.ParseInt(value, 10, 64)
+		}
+		result[ids[i]] = counts
+	}
+
+	if len(missing) == 0 {
+		return result, nil
+	}
+
+	loaded, err := c.load(ctx, missing)
+	if err != nil {
+		return nil, err
+	}
+
+	for id, counts := range loaded {
+		result[id] = counts
+	}
+
+	return result, nil
+}
+
+// load reads the counts of ids from the table, adds the pending deltas and caches them.
+// The flush epoch is odd while a flush writes the table, the read is retried when a flush
+// ran meanwhile, otherwise deltas already written to the table could be counted twice.
+func (c *Counter) load(ctx context.Context, ids []int64) (map[int64]map[string]int64, error) {
+	for attempt := 1; ; attempt++ {
+		epoch, err := c.client.Get(ctx, c.key("epoch")).Int64()
+		if err != nil && !errors.Is(err, redis.Nil) {
+			return nil, err
+		}
+
+		if epoch%2 == 0 {
+			result, lease, err := c.read(ctx, ids, epoch)
+			if err != nil && !errors.Is(err, ErrFlushInProgress) {
+				return nil, err
+			}
+
+			if err == nil {
+				c.cache(ctx, result, lease)
+				return result, nil
+			}
+		}
+
+		if attempt == maxLoadAttempts {
+			return nil, ErrFlushInProgress
+		}
+
+		select {
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		case <-time.After(time.Duration(attempt) * loadRetryBackoff):
+		}
+	}
+}
+
+// read returns the counts of ids in the table plus the pending deltas, and the lease to cache them.
+// It returns ErrFlushInProgress when the flush epoch is not epoch anymore.
+func (c *Counter) read(ctx context.Context, ids []int64, epoch int64) (map[int64]map[string]int64, string, error) {
+	fields := lo.Keys(c.columns)
+	selects := append([]string{c.idColumn}, lo.Map(fields, func(field string, _ int) string {
+		return c.columns[field]
+	})...)
+
+	rows, err := c.db.WithContext(ctx).Table(c.table).Select(selects).Where(c.idColumn+" IN ?", ids).Rows()
+	if err != nil {
+		return nil, "", err
+	}
+	defer rows.Close()
+
+	result := make(map[int64]map[string]int64, len(ids))
+	for rows.Next() {
+		var id int64
+		values := make([]int64, len(fields))
+		dest := append([]any{&id}, lo.Map(values, func(_ int64, i int) any {
+			return &values[i]
+		})...)
+		if err := rows.Scan(dest...); err != nil {
+			return nil, "", err
+		}
+
+		result[id] = lo.SliceToMap(fields, func(field string) (string, int64) {
+			return field, 0
+		})
+		for i, field := range fields {
+			result[id][field] = values[i]
+		}
+	}
+	if err := rows.Err(); err != nil {
+		return nil, "", err
+	}
+
+	lease := uuid.NewString()
+	if err := c.addPendingDeltas(ctx, result, epoch, lease); err != nil {
+		return nil, "", err
+	}
+
+	return result, lease, nil
+}
+
+// cache caches the counts of every id whose lease was not dropped by an increment since it was taken
+func (c *Counter) cache(ctx context.Context, counts map[int64]map[string]int64, lease string) {
+	pipe := c.client.Pipeline()
+	for id, values := range counts {
+		args := []any{lease, int64(c.cacheTTL.Seconds())}
+		for field, value := range values {
+			args = append(args, field, value)
+		}
+		cacheScript.Eval(ctx, pipe, []string{c.countsKey(id), c.leaseKey(id)}, args...)
+	}
+	if _, err := pipe.Exec(ctx); err != nil {
+		log.Context(ctx).Warnf("failed to cache counts of %s: %v", c.name, err)
+	}
+}
+
+// addPendingDeltas adds the pending deltas to counts and takes the leases of their ids in one transaction,
+// together with a check that the flush epoch is still epoch
+func (c *Counter) addPendingDeltas(ctx context.Context, counts map[int64]map[string]int64, epoch int64, lease string) error {
+	if len(counts) == 0 {
+		return nil
+	}
+
+	deltaFields := make([]string, 0, len(counts)*len(c.columns))
+	for id := range counts {
+		for field := range c.columns {
+			deltaFields = append(deltaFields, c.deltaField(id, field))
+		}
+	}
+
+	pipe := c.client.TxPipeline()
+	cmds := []*redis.SliceCmd{
+		pipe.HMGet(ctx, c.key("delta"), deltaFields...),
+		pipe.HMGet(ctx, c.key("flushing"), deltaFields...),
+	}
+	epochCmd := pipe.Get(ctx, c.key("epoch"))
+	for id := range counts {
+		pipe.Set(ctx, c.leaseKey(id), lease, defaultLeaseTTL)
+	}
+	if _, err := pipe.Exec(ctx); err != nil && !errors.Is(err, redis.Nil) {
+		return err
+	}
+
+	current, err := epochCmd.Int64()
+	if err != nil && !errors.Is(err, redis.Nil) {
+		return err
+	}
+
+	if current != epoch {
+		return ErrFlushInProgress
+	}
+
+	for _, cmd := range cmds {
+		for i, value := range cmd.Val() {
+			str, ok := value.(string)
+			if !ok {
+				continue
+			}
+
+			delta, _ := strconv.ParseInt(str, 10, 64)
+			id, field, _ := parseDeltaField(deltaFields[i])
+			counts[id][field] += delta
+		}
+	}
+
+	return nil
+}
+
+// Flush writes the pending deltas to the table, only one replica flushes at a time.
+// Deltas of a failed flush are kept and written by the next one.
+func (c *Counter) Flush(ctx context.Context) error {
+	lock, err := c.locker.TryLock(ctx)
+	if err != nil {
+		if err == cachex.ErrLockNotObtained {
+			return nil
+		}
+		return err
+	}
+	defer func() {
+		_ = lock.Release(context.WithoutCancel(ctx))
+	}()
+
+	// a flush which stopped while writing the table left the epoch odd, readers retry until it is even
+	epochKey := c.key("epoch")
+	epoch, err := c.client.Get(ctx, epochKey).Int64()
+	if err != nil && !errors.Is(err, redis.Nil) {
+		return err
+	}
+	if epoch%2 == 1 {
+		if err := c.client.Incr(ctx, epochKey).Err(); err != nil {
+			return err
+		}
+	}
+
+	flushingKey := c.key("flushing")
+	exists, err := takeDeltasScript.Run(ctx, c.client, []string{c.key("delta"), flushingKey}).Int()
+	if err != nil || exists == 0 {
+		return err
+	}
+
+	deltas, err := c.client.HGetAll(ctx, flushingKey).Result()
+	if err != nil {
+		return err
+	}
+
+	rows := make(map[int64]map[string]int64)
+	rowFields := make(map[int64][]string)
+	for deltaField, value := range deltas {
+		id, field, err := parseDeltaField(deltaField)
+		if err != nil {
+			return err
+		}
+
+		delta, _ := strconv.ParseInt(value, 10, 64)
+		if _, ok := rows[id]; !ok {
+			rows[id] = make(map[string]int64)
+		}
+		rows[id][field] += delta
+		rowFields[id] = append(rowFields[id], deltaField)
+	}
+
+	for _, batch := range lo.Chunk(lo.Keys(rows), c.batchSize) {
+		flushed := lo.FlatMap(batch, func(id int64, _ int) []string {
+			return rowFields[id]
+		})
+		if err := c.flushAndRemove(ctx, batch, rows, flushed); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// flushAndRemove writes a batch to the table and removes its deltas from the flushing hash,
+// the flush epoch is odd in between so that readers do not count the deltas twice
+func (c *Counter) flushAndRemove(ctx context.Context, ids []int64, rows map[int64]map[string]int64, flushed []string) error {
+	epochKey := c.key("epoch")
+	if err := c.client.Incr(ctx, epochKey).Err(); err != nil {
+		return err
+	}
+	defer func() {
+		if err := c.client.Incr(context.WithoutCancel(ctx), epochKey).Err(); err != nil {
+			log.Context(ctx).Errorf("failed to end flush epoch of %s: %v", c.name, err)
+		}
+	}()
+
+	if err := c.flushBatch(ctx, ids, rows); err != nil {
+		return err
+	}
+
+	// remove the flushed deltas at once, so that a failed batch does not flush them twice
+	return c.client.HDel(ctx, c.key("flushing"), flushed...).Err()
+}
+
+func (c *Counter) flushBatch(ctx context.Context, ids []int64, rows map[int64]map[string]int64) error {
+	return c.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		for _, id := range ids {
+			updates := make(map[string]any)
+			for field, delta := range rows[id] {
+				column, ok := c.columns[field]
+				if !ok || delta == 0 {
+					continue
+				}
+
+				updates[column] = gorm.Expr(column+" + ?", delta)
+			}
+
+			if len(updates) == 0 {
+				continue
+			}
+
+			if err := tx.Table(c.table).Where(c.idColumn+" = ?", id).UpdateColumns(updates).Error; err != nil {
+				return err
+			}
+		}
+
+		return nil
+	})
+}
+
+// Start flushes the pending deltas every flush interval in background until Stop is called
+func (c *Counter) Start() {
+	if !c.started.CompareAndSwap(false, true) {
+		return
+	}
+
+	gofer.Go(func() {
+		defer close(c.stopped)
+
+		ticker := time.NewTicker(c.flushInterval)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-c.stop:
+				return
+			case <-ticker.C:
+			}
+
+			if err := c.Flush(context.Background()); err != nil {
+				log.Errorf("failed to flush counter %s: %v", c.name, err)
+			}
+		}
+	})
+}
+
+// Stop stops the background worker started by Start and flushes the pending deltas for the last time
+func (c *Counter) Stop(ctx context.Context) error {
+	c.stopOnce.Do(func() {
+		close(c.stop)
+	})
+
+	if c.started.Load() {
+		<-c.stopped
+	}
+
+	return c.Flush(ctx)
+}
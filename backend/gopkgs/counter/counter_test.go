@@ -0,0 +1,158 @@
+package counter
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/glebarez/sqlite"
+	"github.com/redis/go-redis/v9"
+	"github.com/stretchr/testify/assert"
+	"gorm.io/gorm"
+)
+
+type video struct {
+	Id           int64
+	LikeCount    int64
+	CommentCount int64
+}
+
+func newTestCounter(t *testing.T) (*Counter, *gorm.DB) {
+	c, db, _ := newTestCounterWithRedis(t)
+	return c, db
+}
+
+func newTestCounterWithRedis(t *testing.T) (*Counter, *gorm.DB, *miniredis.Miniredis) {
+	s := miniredis.RunT(t)
+	client := redis.NewClient(&redis.Options{Addr: s.Addr()})
+	t.Cleanup(func() {
+		_ = client.Close()
+	})
+
+	db, err := gorm.Open(sqlite.Open(":memory:"), &gorm.Config{})
+	assert.Nil(t, err)
+	assert.Nil(t, db.Table("video").AutoMigrate(&video{}))
+	assert.Nil(t, db.Table("video").Create([]*video{{Id: 1, LikeCount: 10}, {Id: 2, CommentCount: 3}}).Error)
+
+	c := New("video", client, db,
+		WithField("like", "like_count"),
+		WithField("comment", "comment_count"),
+		WithBatchSize(1),
+	)
+	return c, db, s
+}
+
+func TestCounter(t *testing.T) {
+	c, db := newTestCounter(t)
+	ctx := context.Background()
+
+	assert.Nil(t, c.Incr(ctx, 1, "like", 1))
+	assert.NotNil(t, c.Incr(ctx, 1, "play", 1))
+
+	counts, err := c.Get(ctx, []int64{1, 2, 3})
+	assert.Nil(t, err)
+	assert.Equal(t, map[int64]map[string]int64{
+		1: {"like": 11, "comment": 0},
+		2: {"like": 0, "comment": 3},
+	}, counts)
+
+	assert.Nil(t, c.Incr(ctx, 1, "like", 2))
+	assert.Nil(t, c.Incr(ctx, 2, "comment", -1))
+	counts, err = c.Get(ctx, []int64{1, 2})
+	assert.Nil(t, err)
+	assert.Equal(t, int64(13), counts[1]["like"])
+	assert.Equal(t, int64(2), counts[2]["comment"])
+
+	assert.Nil(t, c.Flush(ctx))
+	var videos []*video
+	assert.Nil(t, db.Table("video").Order("id").Find(&videos).Error)
+	assert.Equal(t, int64(13), videos[0].LikeCount)
+	assert.Equal(t, int64(2), videos[1].CommentCount)
+
+	// flushing again must not apply the same deltas twice
+	assert.Nil(t, c.Flush(ctx))
+	assert.Nil(t, db.Table("video").Order("id").Find(&videos).Error)
+	assert.Equal(t, int64(13), videos[0].LikeCount)
+}
+
+func TestCounter_StartStop(t *testing.T) {
+	c, db := newTestCounter(t)
+	c.flushInterval = 10 * time.Millisecond
+	c.Start()
+
+	assert.Nil(t, c.Incr(context.Background(), 2, "like", 5))
+	assert.Eventually(t, func() bool {
+		var v video
+		_ = db.Table("video").Where("id = ?", 2).Take(&v).Error
+		return v.LikeCount == 5
+	}, time.Second, 10*time.Millisecond)
+
+	assert.Nil(t, c.Stop(context.Background()))
+}
+
+func TestCounter_LoadDuringFlush(t *testing.T) {
+	c, _, s := newTestCounterWithRedis(t)
+	ctx := context.Background()
+	assert.Nil(t, c.Incr(ctx, 1, "like", 1))
+
+	// stop a flush right after it wrote the table, before the deltas are removed from redis
+	exists, err := takeDeltasScript.Run(ctx, c.client, []string{c.key("delta"), c.key("flushing")}).Int()
+	assert.Nil(t, err)
+	assert.Equal(t, 1, exists)
+	assert.Nil(t, c.client.Incr(ctx, c.key("epoch")).Err())
+	assert.Nil(t, c.flushBatch(ctx, []int64{1}, map[int64]map[string]int64{1: {"like": 1}}))
+
+	_, err = c.Get(ctx, []int64{1})
+	assert.ErrorIs(t, err, ErrFlushInProgress)
+	assert.False(t, s.Exists(c.countsKey(1)))
+
+	assert.Nil(t, c.client.HDel(ctx, c.key("flushing"), c.deltaField(1, "like")).Err())
+	assert.Nil(t, c.client.Incr(ctx, c.key("epoch")).Err())
+
+	counts, err := c.Get(ctx, []int64{1})
+	assert.Nil(t, err)
+	assert.Equal(t, int64(11), counts[1]["like"])
+	assert.Equal(t, "11", s.HGet(c.countsKey(1), "like"))
+}
+
+func TestCounter_FlushRepairsEpoch(t *testing.T) {
+	c, db := newTestCounter(t)
+	ctx := context.Background()
+
+	assert.Nil(t, c.client.Set(ctx, c.key("epoch"), 3, 0).Err())
+	assert.Nil(t, c.Incr(ctx, 1, "like", 1))
+	assert.Nil(t, c.Flush(ctx))
+
+	epoch, err := c.client.Get(ctx, c.key("epoch")).Int64()
+	assert.Nil(t, err)
+	assert.Equal(t, int64(0), epoch%2)
+
+	var v video
+	assert.Nil(t, db.Table("video").Where("id = ?", 1).Take(&v).Error)
+	assert.Equal(t, int64(11), v.LikeCount)
+
+	counts, err := c.Get(ctx, []int64{1})
+	assert.Nil(t, err)
+	assert.Equal(t, int64(11), counts[1]["like"])
+}
+
+func TestCounter_IncrDuringLoad(t *testing.T) {
+	c, _, s := newTestCounterWithRedis(t)
+	ctx := context.Background()
+
+	counts, lease, err := c.read(ctx, []int64{1}, 0)
+	assert.Nil(t, err)
+	assert.Equal(t, int64(10), counts[1]["like"])
+
+	// the increment lands after the load read the deltas, the load must not cache its stale counts
+	assert.Nil(t, c.Incr(ctx, 1, "like", 1))
+	c.cache(ctx, counts, lease)
+	assert.False(t, s.Exists(c.countsKey(1)))
+
+	counts, err = c.Get(ctx, []int64{1})
+	assert.Nil(t, err)
+	assert.Equal(t, int64(11), counts[1]["like"])
+	assert.Equal(t, "11", s.HGet(c.countsKey(1), "like"))
+	assert.False(t, s.Exists(c.leaseKey(1)))
+}
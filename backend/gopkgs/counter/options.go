@@ -0,0 +1,43 @@
+package counter
+
+import "time"
+
+type Option func(c *Counter)
+
+// WithTable sets the table the counters are flushed to and its primary key column, which defaults to id
+func WithTable(table string, idColumn ...string) Option {
+	return func(c *Counter) {
+		c.table = table
+		if len(idColumn) > 0 {
+			c.idColumn = idColumn[0]
+		}
+	}
+}
+
+// WithField maps a counter field to its column, such as like to like_count
+func WithField(field, column string) Option {
+	return func(c *Counter) {
+		c.columns[field] = column
+	}
+}
+
+// WithFlushInterval sets how often the pending deltas are flushed to the table
+func WithFlushInterval(interval time.Duration) Option {
+	return func(c *Counter) {
+		c.flushInterval = interval
+	}
+}
+
+// WithBatchSize sets how many rows are updated in one transaction when flushing
+func WithBatchSize(size int) Option {
+	return func(c *Counter) {
+		c.batchSize = size
+	}
+}
+
+// WithCacheTTL sets how long the counts read from the table are cached in redis
+func WithCacheTTL(ttl time.Duration) Option {
+	return func(c *Counter) {
+		c.cacheTTL = ttl
+	}
+}
@@ -0,0 +1,202 @@
+package gofer
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/TremblingV5/box/rearer"
+)
+
+// errTaskNotRun is reported for tasks skipped because the group was done before they started
+var errTaskNotRun = errors.New("task not run: group is done")
+
+// Task is a unit of work of a GroupOf returning a result
+type Task[T any] func(ctx context.Context) (T, error)
+
+// TaskErrors holds the error of every task of a best effort GroupOf in submission order,
+// the errors of succeeded tasks are nil
+type TaskErrors []error
+
+func (e TaskErrors) Error() string {
+	var messages []string
+	for i, err := range e {
+		if err != nil {
+			messages = append(messages, fmt.Sprintf("task %d: %v", i, err))
+		}
+	}
+
+	return strings.Join(messages, "; ")
+}
+
+// Unwrap allows errors.Is and errors.As to match the error of any task
+func (e TaskErrors) Unwrap() []error {
+	return e
+}
+
+type taskSlot[T any] struct {
+	value T
+	err   error
+}
+
+// GroupOf runs tasks with bounded concurrency and collects their results in submission order.
+// By default the first failed task cancels the others like an error Group, in best effort mode
+// every task runs to completion and its error is reported separately.
+type GroupOf[T any] struct {
+	group      *Group
+	timeout    time.Duration
+	bestEffort bool
+
+	mu    sync.Mutex
+	slots []*taskSlot[T]
+}
+
+type collectOptions struct {
+	concurrency int
+	timeout     time.Duration
+	bestEffort  bool
+}
+
+type CollectOption func(*collectOptions)
+
+// WithConcurrency limits the number of tasks running at the same time, runtime.NumCPU() by default
+func WithConcurrency(n int) CollectOption {
+	return func(o *collectOptions) {
+		o.concurrency = n
+	}
+}
+
+// WithTaskTimeout cancels the context of every task after d
+func WithTaskTimeout(d time.Duration) CollectOption {
+	return func(o *collectOptions) {
+		o.timeout = d
+	}
+}
+
+// BestEffort keeps running the other tasks when a task fails
+func BestEffort() CollectOption {
+	return func(o *collectOptions) {
+		o.bestEffort = true
+	}
+}
+
+func NewGroupOf[T any](ctx context.Context, options ...CollectOption) *GroupOf[T] {
+	opts := &collectOptions{}
+	for _, option := range options {
+		option(opts)
+	}
+
+	groupOptions := []GroupOption{UseErrorGroup()}
+	if opts.concurrency > 0 {
+		groupOptions = append(groupOptions, WithUsableG(opts.concurrency))
+	}
+
+	return &GroupOf[T]{
+		group:      NewGroup(ctx, groupOptions...),
+		timeout:    opts.timeout,
+		bestEffort: opts.bestEffort,
+	}
+}
+
+// Go submits task, it blocks while the wait queue of the group is full
+func (g *GroupOf[T]) Go(task Task[T]) error {
+	if task == nil {
+		return errors.New(ErrSubmittedTaskNil)
+	}
+
+	slot := &taskSlot[T]{err: errTaskNotRun}
+	g.mu.Lock()
+	g.slots = append(g.slots, slot)
+	g.mu.Unlock()
+
+	err := g.group.Run(func() error {
+		slot.value, slot.err = g.run(task)
+		if g.bestEffort {
+			return nil
+		}
+
+		return slot.err
+	})
+	if err != nil {
+		slot.err = err
+	}
+
+	return err
+}
+
+func (g *GroupOf[T]) run(task Task[T]) (value T, err error) {
+	ctx := g.group.ctx
+	if g.timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, g.timeout)
+		defer cancel()
+	}
+
+	defer func() {
+		if panicError := recover(); panicError != nil {
+			rearer.LogRecoverStack(panicError, rearer.WithCtx(ctx))
+			err = fmt.Errorf("task panic: %v", panicError)
+		}
+	}()
+
+	return task(ctx)
+}
+
+// Wait waits for all submitted tasks and returns their results in submission order.
+// In best effort mode the results of failed tasks are zero values and the error, if any, is a TaskErrors.
+func (g *GroupOf[T]) Wait() ([]T, error) {
+	err := g.group.Wait()
+
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	results := make([]T, len(g.slots))
+	for i, slot := range g.slots {
+		results[i] = slot.value
+	}
+
+	if !g.bestEffort {
+		return results, err
+	}
+
+	errs := make(TaskErrors, len(g.slots))
+	var failed bool
+	for i, slot := range g.slots {
+		errs[i] = slot.err
+		failed = failed || slot.err != nil
+	}
+
+	if !failed {
+		return results, nil
+	}
+
+	return results, errs
+}
+
+// Collect runs tasks and returns their results in input order, the first error cancels the remaining tasks
+func Collect[T any](ctx context.Context, tasks []Task[T], options ...CollectOption) ([]T, error) {
+	g := NewGroupOf[T](ctx, options...)
+	for _, task := range tasks {
+		if err := g.Go(task); err != nil {
+			_, _ = g.Wait()
+			return nil, err
+		}
+	}
+
+	return g.Wait()
+}
+
+// CollectBestEffort runs all tasks to completion and returns their results and errors in input order
+func CollectBestEffort[T any](ctx context.Context, tasks []Task[T], options ...CollectOption) ([]T, []error) {
+	results, err := Collect(ctx, tasks, append(options, BestEffort())...)
+
+	var errs TaskErrors
+	if !errors.As(err, &errs) {
+		errs = make(TaskErrors, len(results))
+	}
+
+	return results, errs
+}
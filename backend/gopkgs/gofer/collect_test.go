@@ -0,0 +1,110 @@
+package gofer
+
+import (
+	"context"
+	"errors"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func sleepTask(d time.Duration, value int) Task[int] {
+	return func(ctx context.Context) (int, error) {
+		select {
+		case <-ctx.Done():
+			return 0, ctx.Err()
+		case <-time.After(d):
+			return value, nil
+		}
+	}
+}
+
+func TestCollect_Order(t *testing.T) {
+	results, err := Collect(context.Background(), []Task[int]{
+		sleepTask(30*time.Millisecond, 1),
+		sleepTask(10*time.Millisecond, 2),
+		sleepTask(20*time.Millisecond, 3),
+	})
+	assert.Nil(t, err)
+	assert.Equal(t, []int{1, 2, 3}, results)
+}
+
+func TestCollect_Concurrency(t *testing.T) {
+	var running, maxRunning int64
+	task := func(ctx context.Context) (int, error) {
+		n := atomic.AddInt64(&running, 1)
+		for {
+			m := atomic.LoadInt64(&maxRunning)
+			if n <= m || atomic.CompareAndSwapInt64(&maxRunning, m, n) {
+				break
+			}
+		}
+		time.Sleep(10 * time.Millisecond)
+		atomic.AddInt64(&running, -1)
+		return 0, nil
+	}
+
+	_, err := Collect(context.Background(), []Task[int]{task, task, task, task, task, task}, WithConcurrency(2))
+	assert.Nil(t, err)
+	assert.LessOrEqual(t, maxRunning, int64(2))
+}
+
+func TestCollect_FailFast(t *testing.T) {
+	failure := errors.New("failure")
+	start := time.Now()
+	_, err := Collect(context.Background(), []Task[int]{
+		func(ctx context.Context) (int, error) {
+			return 0, failure
+		},
+		sleepTask(time.Second, 2),
+	})
+	assert.True(t, errors.Is(err, failure))
+	assert.Less(t, time.Since(start), time.Second)
+}
+
+func TestCollect_TaskTimeout(t *testing.T) {
+	_, err := Collect(context.Background(), []Task[int]{
+		sleepTask(time.Second, 1),
+	}, WithTaskTimeout(10*time.Millisecond))
+	assert.True(t, errors.Is(err, context.DeadlineExceeded))
+}
+
+func TestCollectBestEffort(t *testing.T) {
+	failure := errors.New("failure")
+	results, errs := CollectBestEffort(context.Background(), []Task[int]{
+		sleepTask(time.Millisecond, 1),
+		func(ctx context.Context) (int, error) {
+			return 0, failure
+		},
+		func(ctx context.Context) (int, error) {
+			panic("boom")
+		},
+		sleepTask(20*time.Millisecond, 4),
+	})
+	assert.Equal(t, []int{1, 0, 0, 4}, results)
+	assert.Nil(t, errs[0])
+	assert.Equal(t, failure, errs[1])
+	assert.NotNil(t, errs[2])
+	assert.Nil(t, errs[3])
+}
+
+func TestGroupOf_BestEffortWait(t *testing.T) {
+	failure := errors.New("failure")
+	g := NewGroupOf[string](context.Background(), BestEffort())
+	_ = g.Go(func(ctx context.Context) (string, error) {
+		return "a", nil
+	})
+	_ = g.Go(func(ctx context.Context) (string, error) {
+		return "", failure
+	})
+
+	results, err := g.Wait()
+	assert.Equal(t, []string{"a", ""}, results)
+	assert.True(t, errors.Is(err, failure))
+
+	var errs TaskErrors
+	assert.True(t, errors.As(err, &errs))
+	assert.Len(t, errs, 2)
+}
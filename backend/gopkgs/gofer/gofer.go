@@ -19,11 +19,7 @@ func SetUseGlobalPool(value bool) {
 
 func Go(f func()) {
 	if useGlobalPool {
-		if pool == nil {
-			InitGlobalPool()
-		}
-
-		_ = pool.Submit(f)
+		_ = globalPool().Submit(f)
 		return
 	}
 
@@ -35,7 +31,7 @@ func Go(f func()) {
 
 func GoWithCtx(ctx context.Context, f func(context.Context)) {
 	if useGlobalPool {
-		_ = pool.Submit(func() {
+		_ = globalPool().Submit(func() {
 			f(ctx)
 		})
 		return
@@ -0,0 +1,191 @@
+package gofer
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/panjf2000/ants/v2"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// PoolConfig declares a named pool
+type PoolConfig struct {
+	Size             int  `json:"size" yaml:"size"`
+	Nonblocking      bool `json:"nonblocking" yaml:"nonblocking"`
+	MaxBlockingTasks int  `json:"max_blocking_tasks" yaml:"max_blocking_tasks"`
+}
+
+func (c *PoolConfig) SetDefault() {
+	if c.Size == 0 {
+		c.Size = defaultPoolSize
+	}
+}
+
+var (
+	namedPools   sync.Map
+	namedPoolsMu sync.Mutex
+
+	registerPoolMetricsOnce sync.Once
+)
+
+// NewNamedPool creates a pool named name so background jobs do not share workers with each other,
+// it fails when a pool with the same name exists
+func NewNamedPool(name string, cfg *PoolConfig) (*Pool, error) {
+	cfg.SetDefault()
+
+	namedPoolsMu.Lock()
+	defer namedPoolsMu.Unlock()
+
+	if _, ok := namedPools.Load(name); ok {
+		return nil, fmt.Errorf("pool %s already exists", name)
+	}
+
+	p, err := ants.NewPool(
+		cfg.Size,
+		panicHandler,
+		ants.WithNonblocking(cfg.Nonblocking),
+		ants.WithMaxBlockingTasks(cfg.MaxBlockingTasks),
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	registerPoolMetricsOnce.Do(func() {
+		prometheus.MustRegister(poolCollector{})
+	})
+
+	named := &Pool{pool: p, name: name, config: *cfg}
+	namedPools.Store(name, named)
+	return named, nil
+}
+
+// GetPool returns the pool named name
+func GetPool(name string) (*Pool, bool) {
+	v, ok := namedPools.Load(name)
+	if !ok {
+		return nil, false
+	}
+
+	return v.(*Pool), true
+}
+
+// GoIn runs f on the pool named name, it returns an error when the pool does not exist
+// or rejects f because it is overloaded
+func GoIn(name string, f func()) error {
+	p, ok := GetPool(name)
+	if !ok {
+		return fmt.Errorf("pool %s not found", name)
+	}
+
+	return p.Submit(f)
+}
+
+// GoInWithCtx is GoIn for functions taking a context
+func GoInWithCtx(ctx context.Context, name string, f func(context.Context)) error {
+	return GoIn(name, func() {
+		f(ctx)
+	})
+}
+
+// Config returns the config the pool was created with, the size is the one at creation
+func (p *Pool) Config() PoolConfig {
+	return p.config
+}
+
+// TunePool changes the size of the pool named name at runtime
+func TunePool(name string, size int) error {
+	p, ok := GetPool(name)
+	if !ok {
+		return fmt.Errorf("pool %s not found", name)
+	}
+
+	p.Tune(size)
+	return nil
+}
+
+const idlePollInterval = 20 * time.Millisecond
+
+// WaitPools waits until no task is running or waiting in any named pool, nor in the global pool of Go
+// when it is used, it returns the error of ctx
+// when ctx is done first
+func WaitPools(ctx context.Context) error {
+	ticker := time.NewTicker(idlePollInterval)
+	defer ticker.Stop()
+
+	for !poolsIdle() {
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-ticker.C:
+		}
+	}
+
+	return nil
+}
+
+func poolsIdle() bool {
+	if useGlobalPool {
+		if p := globalPool(); p.Running() != 0 || p.Waiting() != 0 {
+			return false
+		}
+	}
+
+	idle := true
+	namedPools.Range(func(_, value any) bool {
+		p := value.(*Pool)
+		idle = p.Running() == 0 && p.Waiting() == 0
+		return idle
+	})
+
+	return idle
+}
+
+// ReleasePools releases and forgets all named pools
+func ReleasePools() {
+	namedPoolsMu.Lock()
+	defer namedPoolsMu.Unlock()
+
+	namedPools.Range(func(key, value any) bool {
+		value.(*Pool).Release()
+		namedPools.Delete(key)
+		return true
+	})
+}
+
+var (
+	poolCapacityDesc = prometheus.NewDesc(
+		"gofer_pool_capacity", "Size of a named pool.", []string{"pool"}, nil,
+	)
+	poolRunningDesc = prometheus.NewDesc(
+		"gofer_pool_running", "Number of running tasks of a named pool.", []string{"pool"}, nil,
+	)
+	poolWaitingDesc = prometheus.NewDesc(
+		"gofer_pool_waiting", "Number of tasks blocked on submitting to a named pool.", []string{"pool"}, nil,
+	)
+	poolRejectedDesc = prometheus.NewDesc(
+		"gofer_pool_rejected_total", "Number of tasks rejected by an overloaded named pool.", []string{"pool"}, nil,
+	)
+)
+
+// poolCollector reads the state of the named pools when they are scraped
+type poolCollector struct{}
+
+func (poolCollector) Describe(ch chan<- *prometheus.Desc) {
+	ch <- poolCapacityDesc
+	ch <- poolRunningDesc
+	ch <- poolWaitingDesc
+	ch <- poolRejectedDesc
+}
+
+func (poolCollector) Collect(ch chan<- prometheus.Metric) {
+	namedPools.Range(func(key, value any) bool {
+		p := value.(*Pool)
+		ch <- prometheus.MustNewConstMetric(poolCapacityDesc, prometheus.GaugeValue, float64(p.Cap()), p.name)
+		ch <- prometheus.MustNewConstMetric(poolRunningDesc, prometheus.GaugeValue, float64(p.Running()), p.name)
+		ch <- prometheus.MustNewConstMetric(poolWaitingDesc, prometheus.GaugeValue, float64(p.Waiting()), p.name)
+		ch <- prometheus.MustNewConstMetric(poolRejectedDesc, prometheus.CounterValue, float64(p.Rejected()), p.name)
+		return true
+	})
+}
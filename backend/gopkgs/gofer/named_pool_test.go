@@ -0,0 +1,94 @@
+package gofer
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestNamedPool(t *testing.T) {
+	defer ReleasePools()
+
+	_, err := NewNamedPool("feedback", &PoolConfig{Size: 2})
+	assert.Nil(t, err)
+
+	_, err = NewNamedPool("feedback", &PoolConfig{Size: 2})
+	assert.NotNil(t, err)
+
+	var wg sync.WaitGroup
+	wg.Add(1)
+	assert.Nil(t, GoIn("feedback", wg.Done))
+	wg.Wait()
+
+	assert.NotNil(t, GoIn("unknown", func() {}))
+
+	assert.Nil(t, TunePool("feedback", 4))
+	p, ok := GetPool("feedback")
+	assert.True(t, ok)
+	assert.Equal(t, 4, p.Cap())
+}
+
+func TestNamedPool_Nonblocking(t *testing.T) {
+	defer ReleasePools()
+
+	p, err := NewNamedPool("notify", &PoolConfig{Size: 1, Nonblocking: true})
+	assert.Nil(t, err)
+
+	release := make(chan struct{})
+	started := make(chan struct{})
+	assert.Nil(t, p.Submit(func() {
+		close(started)
+		<-release
+	}))
+	<-started
+
+	assert.NotNil(t, GoIn("notify", func() {}))
+	assert.Equal(t, int64(1), p.Rejected())
+	close(release)
+
+	// capacity, running, waiting and rejected of the pool
+	assert.Equal(t, 4, testutil.CollectAndCount(poolCollector{}))
+}
+
+func TestWaitPools(t *testing.T) {
+	defer ReleasePools()
+
+	_, err := NewNamedPool("export", &PoolConfig{Size: 1})
+	assert.Nil(t, err)
+
+	release := make(chan struct{})
+	started := make(chan struct{})
+	assert.Nil(t, GoIn("export", func() {
+		close(started)
+		<-release
+	}))
+	<-started
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	assert.ErrorIs(t, WaitPools(ctx), context.DeadlineExceeded)
+
+	close(release)
+	assert.Nil(t, WaitPools(context.Background()))
+
+	// the tasks of Go run in the global pool
+	SetUseGlobalPool(true)
+	release = make(chan struct{})
+	started = make(chan struct{})
+	Go(func() {
+		close(started)
+		<-release
+	})
+	<-started
+
+	ctx, cancel = context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	assert.ErrorIs(t, WaitPools(ctx), context.DeadlineExceeded)
+
+	close(release)
+	assert.Nil(t, WaitPools(context.Background()))
+}
@@ -0,0 +1,253 @@
+package gofer
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync/atomic"
+)
+
+// Pipeline runs stages connected by bounded channels, every stage has its own workers. The workers of all stages
+// run in one error Group, so the first error or panic cancels the whole pipeline and is returned by Run.
+// A full channel blocks the stage writing to it, which slows the upstream stages down to the pace of the slowest.
+type Pipeline struct {
+	ctx     context.Context
+	workers []func(ctx context.Context) error
+	started atomic.Bool
+}
+
+func NewPipeline(ctx context.Context) *Pipeline {
+	return &Pipeline{ctx: ctx}
+}
+
+// Stream is the output of a stage and the input of the next one
+type Stream[T any] struct {
+	pipeline *Pipeline
+	ch       chan T
+}
+
+type stageOptions struct {
+	workers int
+	buffer  int
+}
+
+type StageOption func(*stageOptions)
+
+// WithWorkers sets the number of workers of a stage, 1 by default
+func WithWorkers(n int) StageOption {
+	return func(o *stageOptions) {
+		o.workers = n
+	}
+}
+
+// WithBuffer sets the capacity of the output channel of a stage, which equals its number of workers by default
+func WithBuffer(n int) StageOption {
+	return func(o *stageOptions) {
+		o.buffer = n
+	}
+}
+
+func newStageOptions(options ...StageOption) *stageOptions {
+	o := &stageOptions{workers: 1}
+	for _, option := range options {
+		option(o)
+	}
+
+	if o.workers <= 0 {
+		o.workers = 1
+	}
+
+	if o.buffer <= 0 {
+		o.buffer = o.workers
+	}
+
+	return o
+}
+
+// addStage registers the workers of a stage, out is closed once all of them returned
+func (p *Pipeline) addStage(workers int, out func(), work func(ctx context.Context) error) {
+	if p.started.Load() {
+		panic("can't add a stage to a pipeline after Run()")
+	}
+
+	remaining := int64(workers)
+	for i := 0; i < workers; i++ {
+		p.workers = append(p.workers, func(ctx context.Context) error {
+			defer func() {
+				if out != nil && atomic.AddInt64(&remaining, -1) == 0 {
+					out()
+				}
+			}()
+
+			return work(ctx)
+		})
+	}
+}
+
+// Run runs all stages and waits for them, it returns the first error of any stage or the error of the context.
+// Every stream must be consumed by another stage or a Sink, otherwise its stage blocks once the channel is full.
+func (p *Pipeline) Run() error {
+	if !p.started.CompareAndSwap(false, true) {
+		return errors.New("pipeline has been run")
+	}
+
+	if len(p.workers) == 0 {
+		return nil
+	}
+
+	// every worker lives as long as its stage, so each needs its own G
+	g := NewGroup(p.ctx, UseErrorGroup(), WithUsableG(len(p.workers)), WithWaitQueue(len(p.workers)))
+	for _, worker := range p.workers {
+		worker := worker
+		if err := g.Run(func() error {
+			return worker(g.ctx)
+		}); err != nil {
+			return err
+		}
+	}
+
+	err := g.Wait()
+	if err != nil && p.ctx.Err() != nil {
+		return p.ctx.Err()
+	}
+
+	return err
+}
+
+func emitter[T any](ctx context.Context, ch chan T) func(T) error {
+	return func(item T) error {
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case ch <- item:
+			return nil
+		}
+	}
+}
+
+// Source starts a pipeline with items produced by generate, every worker calls generate once
+func Source[T any](p *Pipeline, generate func(ctx context.Context, emit func(T) error) error, options ...StageOption) *Stream[T] {
+	o := newStageOptions(options...)
+	out := &Stream[T]{pipeline: p, ch: make(chan T, o.buffer)}
+
+	p.addStage(o.workers, func() { close(out.ch) }, func(ctx context.Context) error {
+		return generate(ctx, emitter(ctx, out.ch))
+	})
+
+	return out
+}
+
+// FromSlice starts a pipeline with items
+func FromSlice[T any](p *Pipeline, items []T, options ...StageOption) *Stream[T] {
+	return Source(p, func(ctx context.Context, emit func(T) error) error {
+		for _, item := range items {
+			if err := emit(item); err != nil {
+				return err
+			}
+		}
+		return nil
+	}, options...)
+}
+
+// Process is the most general stage, fn may emit any number of items for every input item
+func Process[In, Out any](
+	in *Stream[In],
+	fn func(ctx context.Context, item In, emit func(Out) error) error,
+	options ...StageOption,
+) *Stream[Out] {
+	o := newStageOptions(options...)
+	out := &Stream[Out]{pipeline: in.pipeline, ch: make(chan Out, o.buffer)}
+
+	in.pipeline.addStage(o.workers, func() { close(out.ch) }, func(ctx context.Context) error {
+		emit := emitter(ctx, out.ch)
+		return consume(ctx, in.ch, func(item In) error {
+			return fn(ctx, item, emit)
+		})
+	})
+
+	return out
+}
+
+// Map transforms every item with fn
+func Map[In, Out any](in *Stream[In], fn func(ctx context.Context, item In) (Out, error), options ...StageOption) *Stream[Out] {
+	return Process(in, func(ctx context.Context, item In, emit func(Out) error) error {
+		result, err := fn(ctx, item)
+		if err != nil {
+			return err
+		}
+		return emit(result)
+	}, options...)
+}
+
+// Filter keeps the items keep returns true for
+func Filter[T any](in *Stream[T], keep func(ctx context.Context, item T) (bool, error), options ...StageOption) *Stream[T] {
+	return Process(in, func(ctx context.Context, item T, emit func(T) error) error {
+		ok, err := keep(ctx, item)
+		if err != nil || !ok {
+			return err
+		}
+		return emit(item)
+	}, options...)
+}
+
+// Batch groups items into slices of at most size items, such as rows written by one insert.
+// It always runs a single worker so only the last batch may be smaller than size.
+// Run fails when size is not positive.
+func Batch[T any](in *Stream[T], size int, options ...StageOption) *Stream[[]T] {
+	o := newStageOptions(options...)
+	out := &Stream[[]T]{pipeline: in.pipeline, ch: make(chan []T, o.buffer)}
+
+	in.pipeline.addStage(1, func() { close(out.ch) }, func(ctx context.Context) error {
+		if size <= 0 {
+			return fmt.Errorf("batch size should be positive, got %d", size)
+		}
+
+		emit := emitter(ctx, out.ch)
+		batch := make([]T, 0, size)
+		err := consume(ctx, in.ch, func(item T) error {
+			batch = append(batch, item)
+			if len(batch) < size {
+				return nil
+			}
+
+			full := batch
+			batch = make([]T, 0, size)
+			return emit(full)
+		})
+		if err != nil || len(batch) == 0 {
+			return err
+		}
+
+		return emit(batch)
+	})
+
+	return out
+}
+
+// Sink ends a pipeline by calling fn for every item
+func Sink[T any](in *Stream[T], fn func(ctx context.Context, item T) error, options ...StageOption) {
+	o := newStageOptions(options...)
+	in.pipeline.addStage(o.workers, nil, func(ctx context.Context) error {
+		return consume(ctx, in.ch, func(item T) error {
+			return fn(ctx, item)
+		})
+	})
+}
+
+// consume calls fn for every item of ch until ch is closed, fn fails or ctx is done
+func consume[T any](ctx context.Context, ch <-chan T, fn func(T) error) error {
+	for {
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case item, ok := <-ch:
+			if !ok {
+				return nil
+			}
+
+			if err := fn(item); err != nil {
+				return err
+			}
+		}
+	}
+}
@@ -0,0 +1,136 @@
+package gofer
+
+import (
+	"context"
+	"errors"
+	"sort"
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestPipeline(t *testing.T) {
+	p := NewPipeline(context.Background())
+	ids := FromSlice(p, []int{1, 2, 3, 4, 5, 6, 7})
+	even := Filter(ids, func(ctx context.Context, id int) (bool, error) {
+		return id%2 == 0, nil
+	})
+	names := Map(even, func(ctx context.Context, id int) (string, error) {
+		return strconv.Itoa(id), nil
+	}, WithWorkers(3))
+	batches := Batch(names, 2)
+
+	var (
+		mu      sync.Mutex
+		written [][]string
+	)
+	Sink(batches, func(ctx context.Context, batch []string) error {
+		mu.Lock()
+		defer mu.Unlock()
+		written = append(written, batch)
+		return nil
+	})
+
+	assert.Nil(t, p.Run())
+	assert.Len(t, written, 2)
+
+	var all []string
+	for _, batch := range written {
+		all = append(all, batch...)
+	}
+	sort.Strings(all)
+	assert.Equal(t, []string{"2", "4", "6"}, all)
+	assert.NotNil(t, p.Run())
+}
+
+func TestPipeline_Error(t *testing.T) {
+	failure := errors.New("failure")
+	var produced atomic.Int64
+
+	p := NewPipeline(context.Background())
+	items := Source(p, func(ctx context.Context, emit func(int) error) error {
+		for i := 0; ; i++ {
+			if err := emit(i); err != nil {
+				return err
+			}
+			produced.Add(1)
+		}
+	})
+	Sink(items, func(ctx context.Context, item int) error {
+		if item == 10 {
+			return failure
+		}
+		return nil
+	})
+
+	assert.ErrorIs(t, p.Run(), failure)
+	assert.Less(t, produced.Load(), int64(20))
+}
+
+func TestPipeline_InvalidBatchSize(t *testing.T) {
+	for _, size := range []int{0, -1} {
+		p := NewPipeline(context.Background())
+		batches := Batch(FromSlice(p, []int{1, 2, 3}), size)
+		Sink(batches, func(ctx context.Context, batch []int) error {
+			return nil
+		})
+
+		assert.NotNil(t, p.Run())
+	}
+}
+
+func TestPipeline_Backpressure(t *testing.T) {
+	var produced atomic.Int64
+	release := make(chan struct{})
+
+	p := NewPipeline(context.Background())
+	items := Source(p, func(ctx context.Context, emit func(int) error) error {
+		for i := 0; i < 100; i++ {
+			if err := emit(i); err != nil {
+				return err
+			}
+			produced.Add(1)
+		}
+		return nil
+	}, WithBuffer(2))
+	Sink(items, func(ctx context.Context, item int) error {
+		<-release
+		return nil
+	})
+
+	done := make(chan error)
+	go func() {
+		done <- p.Run()
+	}()
+
+	time.Sleep(50 * time.Millisecond)
+	// the sink holds one item and the channel two more
+	assert.LessOrEqual(t, produced.Load(), int64(3))
+
+	close(release)
+	assert.Nil(t, <-done)
+	assert.Equal(t, int64(100), produced.Load())
+}
+
+func TestPipeline_Cancel(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+
+	p := NewPipeline(ctx)
+	items := Source(p, func(ctx context.Context, emit func(int) error) error {
+		for {
+			if err := emit(0); err != nil {
+				return err
+			}
+		}
+	})
+	Sink(items, func(ctx context.Context, item int) error {
+		cancel()
+		return nil
+	})
+
+	assert.ErrorIs(t, p.Run(), context.Canceled)
+}
@@ -1,13 +1,18 @@
 package gofer
 
 import (
+	"errors"
+	"sync"
+	"sync/atomic"
+
 	"github.com/TremblingV5/box/rearer"
 	"github.com/panjf2000/ants/v2"
 )
 
 var (
-	pool         *Pool
-	panicHandler = ants.WithPanicHandler(func(err any) {
+	pool           *Pool
+	globalPoolOnce sync.Once
+	panicHandler   = ants.WithPanicHandler(func(err any) {
 		rearer.LogRecoverStack(err)
 	})
 )
@@ -21,6 +26,9 @@ func SetPoolSize(size int) {
 type Pool struct {
 	poolWithFunc *ants.PoolWithFunc
 	pool         *ants.Pool
+	name         string
+	config       PoolConfig
+	rejected     atomic.Int64
 }
 
 func InitGlobalPool() {
@@ -30,6 +38,17 @@ func InitGlobalPool() {
 	}
 }
 
+// globalPool returns the global pool, it is created on the first use unless InitGlobalPool has created it
+func globalPool() *Pool {
+	globalPoolOnce.Do(func() {
+		if pool == nil {
+			InitGlobalPool()
+		}
+	})
+
+	return pool
+}
+
 func NewWithPoolFunc(poolSize int, f func(a any), options ...ants.Option) (*Pool, error) {
 	options = append(options, panicHandler)
 
@@ -45,7 +64,7 @@ func NewWithPoolFunc(poolSize int, f func(a any), options ...ants.Option) (*Pool
 
 func (p *Pool) Release() {
 	if p.pool != nil {
-		p.Release()
+		p.pool.Release()
 	}
 
 	if p.poolWithFunc != nil {
@@ -72,9 +91,31 @@ func (p *Pool) Submit(task func()) error {
 	}
 
 	if err := p.pool.Submit(task); err != nil {
-		// TODO: log errors here
+		if errors.Is(err, ants.ErrPoolOverload) {
+			p.rejected.Add(1)
+		}
 		return err
 	}
 
 	return nil
 }
+
+// Waiting returns the number of tasks blocked on Submit
+func (p *Pool) Waiting() int {
+	return p.pool.Waiting()
+}
+
+// Cap returns the size of the pool
+func (p *Pool) Cap() int {
+	return p.pool.Cap()
+}
+
+// Rejected returns the number of tasks rejected because the pool was overloaded
+func (p *Pool) Rejected() int64 {
+	return p.rejected.Load()
+}
+
+// Tune changes the size of the pool at runtime
+func (p *Pool) Tune(size int) {
+	p.pool.Tune(size)
+}
@@ -0,0 +1,248 @@
+package health
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/cloudzenith/DouTok/backend/gopkgs/gofer"
+	"github.com/go-kratos/kratos/v2/log"
+	"github.com/go-kratos/kratos/v2/transport/grpc"
+	"google.golang.org/grpc/health"
+	"google.golang.org/grpc/health/grpc_health_v1"
+)
+
+const (
+	StatusUp   = "up"
+	StatusDown = "down"
+
+	defaultInterval = 10 * time.Second
+	defaultTimeout  = 5 * time.Second
+)
+
+type ComponentHealth struct {
+	Status  string `json:"status"`
+	Latency string `json:"latency"`
+	Error   string `json:"error,omitempty"`
+}
+
+type Report struct {
+	Status     string                      `json:"status"`
+	Components map[string]*ComponentHealth `json:"components"`
+}
+
+// Checker checks the components of a service periodically, the last report backs the readiness endpoint
+// and the grpc health service
+type Checker struct {
+	mu       sync.Mutex
+	checks   map[string]func(ctx context.Context) error
+	interval time.Duration
+	timeout  time.Duration
+
+	report   atomic.Pointer[Report]
+	draining atomic.Bool
+	grpc     *health.Server
+
+	stopOnce sync.Once
+	stop     chan struct{}
+}
+
+type Option func(c *Checker)
+
+// WithInterval sets how often the checks run, 10 seconds by default
+func WithInterval(interval time.Duration) Option {
+	return func(c *Checker) {
+		c.interval = interval
+	}
+}
+
+// WithTimeout bounds every check, 5 seconds by default
+func WithTimeout(timeout time.Duration) Option {
+	return func(c *Checker) {
+		c.timeout = timeout
+	}
+}
+
+func NewChecker(options ...Option) *Checker {
+	c := &Checker{
+		checks:   make(map[string]func(ctx context.Context) error),
+		interval: defaultInterval,
+		timeout:  defaultTimeout,
+		grpc:     health.NewServer(),
+		stop:     make(chan struct{}),
+	}
+
+	for _, option := range options {
+		option(c)
+	}
+
+	return c
+}
+
+// Register adds a check, name is the key of its status in the report
+func (c *Checker) Register(name string, check func(ctx context.Context) error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.checks[name] = check
+}
+
+// RegisterComponent registers a component health check, which does not take a context
+func (c *Checker) RegisterComponent(name string, check func() error) {
+	if check == nil {
+		return
+	}
+
+	c.Register(name, func(ctx context.Context) error {
+		done := make(chan error, 1)
+		gofer.Go(func() {
+			done <- check()
+		})
+
+		select {
+		case err := <-done:
+			return err
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	})
+}
+
+// Check runs all checks concurrently
+func (c *Checker) Check(ctx context.Context) *Report {
+	c.mu.Lock()
+	names := make([]string, 0, len(c.checks))
+	tasks := make([]gofer.Task[*ComponentHealth], 0, len(c.checks))
+	for name, check := range c.checks {
+		check := check
+		names = append(names, name)
+		tasks = append(tasks, func(ctx context.Context) (*ComponentHealth, error) {
+			start := time.Now()
+			err := check(ctx)
+
+			status := &ComponentHealth{Status: StatusUp, Latency: time.Since(start).String()}
+			if err != nil {
+				status.Status = StatusDown
+				status.Error = err.Error()
+			}
+			return status, nil
+		})
+	}
+	c.mu.Unlock()
+
+	report := &Report{Status: StatusUp, Components: make(map[string]*ComponentHealth, len(names))}
+	results, _ := gofer.CollectBestEffort(ctx, tasks, gofer.WithConcurrency(len(tasks)+1), gofer.WithTaskTimeout(c.timeout))
+	for i, name := range names {
+		status := results[i]
+		if status == nil {
+			status = &ComponentHealth{Status: StatusDown, Error: "not checked"}
+		}
+
+		if status.Status == StatusDown {
+			report.Status = StatusDown
+		}
+		report.Components[name] = status
+	}
+
+	return report
+}
+
+// Start runs the checks right away and then every interval until stopped
+func (c *Checker) Start() {
+	c.Refresh()
+
+	gofer.Go(func() {
+		ticker := time.NewTicker(c.interval)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-c.stop:
+				return
+			case <-ticker.C:
+				c.Refresh()
+			}
+		}
+	})
+}
+
+// Refresh runs the checks and updates the last report and the grpc serving status
+func (c *Checker) Refresh() {
+	report := c.Check(context.Background())
+	previous := c.report.Swap(report)
+	if previous != nil && previous.Status != report.Status {
+		log.Warnf("health status changed from %s to %s", previous.Status, report.Status)
+	}
+
+	if c.draining.Load() {
+		return
+	}
+
+	status := grpc_health_v1.HealthCheckResponse_SERVING
+	if report.Status != StatusUp {
+		status = grpc_health_v1.HealthCheckResponse_NOT_SERVING
+	}
+	c.grpc.SetServingStatus("", status)
+}
+
+// Drain reports the service as not ready, so no new traffic is routed to it while it shuts down
+func (c *Checker) Drain() {
+	c.draining.Store(true)
+	c.grpc.Shutdown()
+}
+
+// Close stops the periodic checks
+func (c *Checker) Close() {
+	c.stopOnce.Do(func() {
+		close(c.stop)
+	})
+}
+
+// Ready returns the last report and whether the service is ready to serve traffic
+func (c *Checker) Ready() (*Report, bool) {
+	report := c.report.Load()
+	if report == nil {
+		return &Report{Status: StatusDown}, false
+	}
+
+	return report, report.Status == StatusUp && !c.draining.Load()
+}
+
+// RegisterGrpc serves the grpc health protocol on s, which requires s to be created with grpc.CustomHealth(),
+// otherwise kratos registers its own health service which does not know about the components
+func (c *Checker) RegisterGrpc(s *grpc.Server) {
+	if _, ok := s.GetServiceInfo()[grpc_health_v1.Health_ServiceDesc.ServiceName]; ok {
+		log.Warn("grpc health service is registered by kratos, create the grpc server with grpc.CustomHealth() " +
+			"to report the health of components")
+		return
+	}
+
+	grpc_health_v1.RegisterHealthServer(s, c.grpc)
+}
+
+// HandleHealthz reports liveness, the process is alive as long as it answers
+func (c *Checker) HandleHealthz(w http.ResponseWriter, _ *http.Request) {
+	writeJSON(w, http.StatusOK, &Report{Status: StatusUp})
+}
+
+// HandleReadyz reports readiness with the status and latency of every check, it answers 503 when not ready
+func (c *Checker) HandleReadyz(w http.ResponseWriter, _ *http.Request) {
+	report, ready := c.Ready()
+	code := http.StatusOK
+	if !ready {
+		code = http.StatusServiceUnavailable
+	}
+
+	writeJSON(w, code, report)
+}
+
+func writeJSON(w http.ResponseWriter, code int, v any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(code)
+	if err := json.NewEncoder(w).Encode(v); err != nil {
+		log.Errorf("failed to write response: %v", err)
+	}
+}
@@ -0,0 +1,89 @@
+package health
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"google.golang.org/grpc/health/grpc_health_v1"
+)
+
+func readyz(h *Checker) (int, *Report) {
+	recorder := httptest.NewRecorder()
+	h.HandleReadyz(recorder, httptest.NewRequest(http.MethodGet, "/readyz", nil))
+
+	report := &Report{}
+	_ = json.Unmarshal(recorder.Body.Bytes(), report)
+	return recorder.Code, report
+}
+
+func grpcStatus(h *Checker) grpc_health_v1.HealthCheckResponse_ServingStatus {
+	resp, err := h.grpc.Check(context.Background(), &grpc_health_v1.HealthCheckRequest{})
+	if err != nil {
+		return grpc_health_v1.HealthCheckResponse_UNKNOWN
+	}
+
+	return resp.Status
+}
+
+func TestHealthChecker(t *testing.T) {
+	h := NewChecker()
+	defer h.Close()
+
+	code, _ := readyz(h)
+	assert.Equal(t, http.StatusServiceUnavailable, code)
+
+	var redisErr error
+	h.RegisterComponent("redis", func() error {
+		return redisErr
+	})
+	h.Register("mysql", func(ctx context.Context) error {
+		return nil
+	})
+	h.Start()
+
+	code, report := readyz(h)
+	assert.Equal(t, http.StatusOK, code)
+	assert.Equal(t, StatusUp, report.Status)
+	assert.Equal(t, StatusUp, report.Components["redis"].Status)
+	assert.NotEmpty(t, report.Components["mysql"].Latency)
+	assert.Equal(t, grpc_health_v1.HealthCheckResponse_SERVING, grpcStatus(h))
+
+	redisErr = errors.New("connection refused")
+	h.Refresh()
+	code, report = readyz(h)
+	assert.Equal(t, http.StatusServiceUnavailable, code)
+	assert.Equal(t, StatusDown, report.Status)
+	assert.Equal(t, "connection refused", report.Components["redis"].Error)
+	assert.Equal(t, StatusUp, report.Components["mysql"].Status)
+	assert.Equal(t, grpc_health_v1.HealthCheckResponse_NOT_SERVING, grpcStatus(h))
+
+	redisErr = nil
+	h.Refresh()
+	h.Drain()
+	code, _ = readyz(h)
+	assert.Equal(t, http.StatusServiceUnavailable, code)
+	assert.Equal(t, grpc_health_v1.HealthCheckResponse_NOT_SERVING, grpcStatus(h))
+
+	recorder := httptest.NewRecorder()
+	h.HandleHealthz(recorder, httptest.NewRequest(http.MethodGet, "/healthz", nil))
+	assert.Equal(t, http.StatusOK, recorder.Code)
+}
+
+func TestHealthChecker_Timeout(t *testing.T) {
+	h := NewChecker()
+	h.timeout = 10 * time.Millisecond
+	h.RegisterComponent("minio", func() error {
+		time.Sleep(time.Second)
+		return nil
+	})
+
+	report := h.Check(context.Background())
+	assert.Equal(t, StatusDown, report.Status)
+	assert.Equal(t, context.DeadlineExceeded.Error(), report.Components["minio"].Error)
+}
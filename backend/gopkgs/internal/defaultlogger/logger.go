@@ -20,6 +20,19 @@ const (
 	LogCompress   = false
 )
 
+// level is shared by all loggers returned by GetLogger so it can be changed at runtime
+var level = zap.NewAtomicLevelAt(zapcore.DebugLevel)
+
+// SetLevel changes the level of the default logger, such as "info" or "warn"
+func SetLevel(text string) error {
+	return level.UnmarshalText([]byte(text))
+}
+
+// Level returns the current level of the default logger
+func Level() string {
+	return level.String()
+}
+
 func getJsonLogEncoder() zapcore.Encoder {
 	encoderConfig := zap.NewProductionEncoderConfig()
 	encoderConfig.EncodeTime = zapcore.ISO8601TimeEncoder
@@ -48,7 +61,7 @@ func GetLogger() log.Logger {
 	writeSyncer := getLogSyncWriter()
 	encoder := getJsonLogEncoder()
 
-	core := zapcore.NewCore(encoder, writeSyncer, zapcore.DebugLevel)
+	core := zapcore.NewCore(encoder, writeSyncer, level)
 	z := zap.New(core)
 	tracing.Server()
 	zapLogger := kratoszap.NewLogger(z)
@@ -0,0 +1,45 @@
+package shutdown
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/go-kratos/kratos/v2/log"
+)
+
+// RunPhase runs a phase of the shutdown and waits at most timeout for it. A phase which times out is left
+// running in the background so the following phases are not blocked by it.
+func RunPhase(name string, timeout time.Duration, fn func(ctx context.Context) error) error {
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+
+	log.Infof("shutdown phase %s started", name)
+	start := time.Now()
+
+	// a plain goroutine, the phase may wait for the gofer pools to be idle
+	done := make(chan error, 1)
+	go func() {
+		defer func() {
+			if r := recover(); r != nil {
+				done <- fmt.Errorf("panic: %v", r)
+			}
+		}()
+
+		done <- fn(ctx)
+	}()
+
+	select {
+	case err := <-done:
+		if err != nil {
+			log.Errorf("shutdown phase %s failed after %s: %v", name, time.Since(start), err)
+			return err
+		}
+
+		log.Infof("shutdown phase %s finished in %s", name, time.Since(start))
+		return nil
+	case <-ctx.Done():
+		log.Errorf("shutdown phase %s timed out after %s", name, timeout)
+		return ctx.Err()
+	}
+}
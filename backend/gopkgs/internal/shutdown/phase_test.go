@@ -0,0 +1,32 @@
+package shutdown
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRunPhase(t *testing.T) {
+	assert.Nil(t, RunPhase("ok", time.Second, func(ctx context.Context) error {
+		return nil
+	}))
+
+	failed := errors.New("failed")
+	assert.ErrorIs(t, RunPhase("error", time.Second, func(ctx context.Context) error {
+		return failed
+	}), failed)
+
+	assert.NotNil(t, RunPhase("panic", time.Second, func(ctx context.Context) error {
+		panic("boom")
+	}))
+
+	start := time.Now()
+	assert.ErrorIs(t, RunPhase("timeout", 50*time.Millisecond, func(ctx context.Context) error {
+		time.Sleep(time.Second)
+		return nil
+	}), context.DeadlineExceeded)
+	assert.Less(t, time.Since(start), time.Second)
+}
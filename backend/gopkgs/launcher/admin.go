@@ -0,0 +1,68 @@
+package launcher
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"time"
+
+	"github.com/go-kratos/kratos/v2/log"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+)
+
+const (
+	metricsPath = "/metrics"
+	healthzPath = "/healthz"
+	readyzPath  = "/readyz"
+)
+
+// runAdminServer serves metrics, liveness and readiness on the admin address
+func (l *Launcher) runAdminServer() {
+	if l.adminAddr == "" {
+		return
+	}
+
+	for _, collector := range l.componentsLauncher.Collectors() {
+		if err := prometheus.Register(collector); err != nil && !errors.As(err, &prometheus.AlreadyRegisteredError{}) {
+			log.Errorf("failed to register component metrics: %v", err)
+		}
+	}
+
+	mux := http.NewServeMux()
+	mux.Handle(metricsPath, promhttp.Handler())
+	mux.HandleFunc(healthzPath, l.health.HandleHealthz)
+	mux.HandleFunc(readyzPath, l.health.HandleReadyz)
+
+	l.adminServer = &http.Server{
+		Addr:              l.adminAddr,
+		Handler:           mux,
+		ReadHeaderTimeout: 5 * time.Second,
+	}
+
+	go func() {
+		log.Infof("admin server listening on %s", l.adminAddr)
+		if err := l.adminServer.ListenAndServe(); err != nil && !errors.Is(err, http.ErrServerClosed) {
+			log.Errorf("admin server stopped: %v", err)
+		}
+	}()
+}
+
+// stopAdminServers stops the admin and debug servers
+func (l *Launcher) stopAdminServers() {
+	stopServer("admin", l.adminServer)
+	stopServer("debug", l.debugServer)
+}
+
+func stopServer(name string, server *http.Server) {
+	if server == nil {
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	if err := server.Shutdown(ctx); err != nil {
+		log.Errorf("failed to shutdown %s server: %v", name, err)
+	}
+}
@@ -1,9 +1,9 @@
 package launcher
 
 import (
-	"context"
+	"fmt"
+	"reflect"
 
-	"github.com/cloudzenith/DouTok/backend/gopkgs/components"
 	"github.com/cloudzenith/DouTok/backend/gopkgs/components/consulx"
 	"github.com/cloudzenith/DouTok/backend/gopkgs/components/etcdx"
 	"github.com/cloudzenith/DouTok/backend/gopkgs/components/miniox"
@@ -11,73 +11,183 @@ import (
 	"github.com/cloudzenith/DouTok/backend/gopkgs/components/redisx"
 	"github.com/cloudzenith/DouTok/backend/gopkgs/components/rmqconsumerx"
 	"github.com/cloudzenith/DouTok/backend/gopkgs/components/rmqproducerx"
-	"github.com/cloudzenith/DouTok/backend/gopkgs/gofer"
 
 	"github.com/go-kratos/kratos/v2/config"
 	"github.com/go-kratos/kratos/v2/log"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/samber/lo"
 )
 
+func init() {
+	RegisterComponent(
+		"mysql", mysqlx.Init, WithCloser(mysqlx.Close), WithReload(mysqlx.Reload), WithCollector(mysqlx.Collector()),
+	)
+	RegisterComponent("redis", redisx.Init, WithCloser(redisx.Close), WithCollector(redisx.Collector()))
+	RegisterComponent("minio", miniox.Init)
+	RegisterComponent("etcd", etcdx.Init, WithCloser(etcdx.Close))
+	RegisterComponent("consul", consulx.Init)
+	RegisterComponent(
+		"rmqconsumer", rmqconsumerx.Init, WithSuspender(rmqconsumerx.Suspend), WithCloser(rmqconsumerx.Close),
+	)
+	RegisterComponent("rmqproducer", rmqproducerx.Init, WithCloser(rmqproducerx.Close))
+}
+
+const componentsConfigKey = "components"
+
 type ComponentsLauncher struct {
-	group  *gofer.Group
-	config map[string]config.Value
+	config       map[string]config.Value
+	healthChecks map[string]func() error
+	launched     []*componentDefinition
+	// applied holds the configs applied to the launched components, to find the ones changed by a reload
+	applied map[string]any
 }
 
 func NewComponentsLauncher(config config.Config) *ComponentsLauncher {
-	configMap, err := config.Value("components").Map()
+	configMap, err := config.Value(componentsConfigKey).Map()
 	if err != nil {
 		panic("get components config error: " + err.Error())
 	}
 
 	return &ComponentsLauncher{
-		group: gofer.NewGroup(
-			context.Background(),
-			gofer.UseErrorGroup(),
-		),
-		config: configMap,
+		config:       configMap,
+		healthChecks: make(map[string]func() error),
+		applied:      make(map[string]any),
 	}
 }
 
-func launchWrapper(cfg config.Value, componentsName string) {
-	switch componentsName {
-	case "mysql":
-		launchComponent(cfg, mysqlx.Init)
-	case "redis":
-		launchComponent(cfg, redisx.Init)
-	case "minio":
-		launchComponent(cfg, miniox.Init)
-	case "etcd":
-		launchComponent(cfg, etcdx.Init)
-	case "consul":
-		launchComponent(cfg, consulx.Init)
-	case "rmqconsumer":
-		launchComponent(cfg, rmqconsumerx.Init)
-	case "rmqproducer":
-		launchComponent(cfg, rmqproducerx.Init)
-	default:
-		panic("unknown components name: " + componentsName)
+// Launch launches the configured components after their dependencies, it stops at the first component
+// failing to launch and returns its error
+func (l *ComponentsLauncher) Launch() error {
+	order, err := launchOrder(lo.Keys(l.config))
+	if err != nil {
+		return err
 	}
+
+	for _, d := range order {
+		log.Infof("launch component: %s", d.name)
+		healthCheck, err := d.launch(l.config[d.name])
+		if err != nil {
+			return fmt.Errorf("launch component %s: %w", d.name, err)
+		}
+
+		l.launched = append(l.launched, d)
+		l.applied[d.name] = rawConfig(l.config[d.name])
+		if healthCheck != nil {
+			l.healthChecks[d.name] = healthCheck
+		}
+	}
+
+	return nil
 }
 
-func (l *ComponentsLauncher) Launch() {
-	for componentsName, cfg := range l.config {
-		log.Infof("launch component: %s", componentsName)
-		log.Infof("%s config: %v", componentsName, cfg)
-		launchWrapper(cfg, componentsName)
+// Reload applies the changed component configs of value to the launched components which support it,
+// the changes of the other components are rejected until a restart. It returns the configs applied
+// to the launched components.
+func (l *ComponentsLauncher) Reload(value config.Value) map[string]any {
+	configMap, err := value.Map()
+	if err != nil {
+		log.Errorf("failed to reload components config: %v", err)
+		return l.appliedConfigs()
+	}
+
+	for _, d := range l.launched {
+		cfg, ok := configMap[d.name]
+		if !ok {
+			log.Errorf("component %s was removed from the config, it will be closed on the next restart", d.name)
+			continue
+		}
+
+		raw := rawConfig(cfg)
+		if reflect.DeepEqual(raw, l.applied[d.name]) {
+			continue
+		}
+
+		if d.reload == nil {
+			log.Errorf("rejected config change of component %s, it needs a restart", d.name)
+			continue
+		}
+
+		if err := d.reload(cfg); err != nil {
+			log.Errorf("rejected config change of component %s: %v", d.name, err)
+			continue
+		}
+
+		l.applied[d.name] = raw
+		log.Infof("reloaded config of component %s", d.name)
 	}
 
-	if err := l.group.Wait(); err != nil {
-		panic("launch components error: " + err.Error())
+	for name := range configMap {
+		if _, ok := l.applied[name]; !ok {
+			log.Errorf("component %s was added to the config, it will be launched on the next restart", name)
+		}
 	}
+
+	return l.appliedConfigs()
 }
 
-func launchComponent[T any](cfg config.Value, initMethod func(cfg components.ConfigMap[*T]) (func() error, error)) {
-	configs, err := cfg.Map()
-	if err != nil {
-		panic("get component config error: " + err.Error())
+func (l *ComponentsLauncher) appliedConfigs() map[string]any {
+	applied := make(map[string]any, len(l.applied))
+	for name, raw := range l.applied {
+		applied[name] = raw
 	}
 
-	_, component := components.Load(configs, initMethod)
-	if err := component.Start(); err != nil {
-		panic("launch component error: " + err.Error())
+	return applied
+}
+
+func rawConfig(value config.Value) any {
+	var raw any
+	if err := value.Scan(&raw); err != nil {
+		return nil
+	}
+
+	return raw
+}
+
+// Collectors returns the metrics collectors of the launched components
+func (l *ComponentsLauncher) Collectors() []prometheus.Collector {
+	collectors := make([]prometheus.Collector, 0, len(l.launched))
+	for _, d := range l.launched {
+		if d.collector != nil {
+			collectors = append(collectors, d.collector)
+		}
 	}
+
+	return collectors
+}
+
+// Suspend stops the launched components from taking new work, in reverse launch order
+func (l *ComponentsLauncher) Suspend() {
+	for i := len(l.launched) - 1; i >= 0; i-- {
+		d := l.launched[i]
+		if d.suspender == nil {
+			continue
+		}
+
+		if err := d.suspender(); err != nil {
+			log.Errorf("failed to suspend component %s: %v", d.name, err)
+		}
+	}
+}
+
+// Close closes the launched components in reverse launch order
+func (l *ComponentsLauncher) Close() {
+	for i := len(l.launched) - 1; i >= 0; i-- {
+		d := l.launched[i]
+		if d.closer == nil {
+			continue
+		}
+
+		if err := d.closer(); err != nil {
+			log.Errorf("failed to close component %s: %v", d.name, err)
+			continue
+		}
+		log.Infof("closed component: %s", d.name)
+	}
+
+	l.launched = nil
+}
+
+// HealthChecks returns the health checks of the launched components by component name
+func (l *ComponentsLauncher) HealthChecks() map[string]func() error {
+	return l.healthChecks
 }
@@ -1,8 +1,87 @@
 package launcher
 
+import (
+	"fmt"
+	"net"
+)
+
 type App struct {
 	Name          string `yaml:"name" json:"name"`
 	Version       string `yaml:"version" json:"version"`
 	Node          int64  `yaml:"node" json:"node"`
 	TraceEndpoint string `json:"trace_endpoint" yaml:"trace_endpoint"`
+	// LogLevel is the level of the default logger, such as "info", it can be changed without a restart
+	LogLevel string    `json:"log_level" yaml:"log_level"`
+	Shutdown *Shutdown `json:"shutdown" yaml:"shutdown"`
+	Debug    *Debug    `json:"debug" yaml:"debug"`
+}
+
+// Debug is the opt-in debug server, it serves pprof, goroutine dumps, the build info, the redacted config,
+// the status of the components and the log level
+type Debug struct {
+	// Addr is such as 127.0.0.1:9101, the debug server is disabled when it is empty
+	Addr string `json:"addr" yaml:"addr"`
+	// Secret is the bearer token every request must carry, without it Addr must be a loopback address
+	Secret string `json:"secret" yaml:"secret"`
+}
+
+func (d *Debug) Validate() error {
+	if d.Secret != "" {
+		return nil
+	}
+
+	host, _, err := net.SplitHostPort(d.Addr)
+	if err != nil {
+		return fmt.Errorf("invalid debug server addr %s: %w", d.Addr, err)
+	}
+
+	if ip := net.ParseIP(host); host == "localhost" || (ip != nil && ip.IsLoopback()) {
+		return nil
+	}
+
+	return fmt.Errorf("debug server on %s needs a secret, or a loopback addr such as 127.0.0.1:9101", d.Addr)
+}
+
+// Shutdown holds the timeouts of the shutdown phases in seconds
+type Shutdown struct {
+	// DeregisterDelay is how long the servers keep serving after the service is deregistered,
+	// so clients notice the deregistration before connections are refused
+	DeregisterDelay   int `json:"deregister_delay" yaml:"deregister_delay"`
+	DeregisterTimeout int `json:"deregister_timeout" yaml:"deregister_timeout"`
+	// DrainTimeout bounds waiting for the in-flight requests of the http and grpc servers
+	DrainTimeout    int `json:"drain_timeout" yaml:"drain_timeout"`
+	SuspendTimeout  int `json:"suspend_timeout" yaml:"suspend_timeout"`
+	PoolsTimeout    int `json:"pools_timeout" yaml:"pools_timeout"`
+	HandlersTimeout int `json:"handlers_timeout" yaml:"handlers_timeout"`
+	CloseTimeout    int `json:"close_timeout" yaml:"close_timeout"`
+}
+
+func (s *Shutdown) SetDefault() {
+	if s.DeregisterDelay == 0 {
+		s.DeregisterDelay = 2
+	}
+
+	if s.DeregisterTimeout == 0 {
+		s.DeregisterTimeout = 5
+	}
+
+	if s.DrainTimeout == 0 {
+		s.DrainTimeout = 10
+	}
+
+	if s.SuspendTimeout == 0 {
+		s.SuspendTimeout = 5
+	}
+
+	if s.PoolsTimeout == 0 {
+		s.PoolsTimeout = 10
+	}
+
+	if s.HandlersTimeout == 0 {
+		s.HandlersTimeout = 10
+	}
+
+	if s.CloseTimeout == 0 {
+		s.CloseTimeout = 10
+	}
 }
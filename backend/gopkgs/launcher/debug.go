@@ -0,0 +1,184 @@
+package launcher
+
+import (
+	"crypto/subtle"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/pprof"
+	"runtime"
+	"runtime/debug"
+	runtimepprof "runtime/pprof"
+	"time"
+
+	"github.com/cloudzenith/DouTok/backend/gopkgs/configx"
+	"github.com/cloudzenith/DouTok/backend/gopkgs/internal/defaultlogger"
+	"github.com/go-kratos/kratos/v2/log"
+)
+
+const (
+	debugPprofPath      = "/debug/pprof/"
+	debugGoroutinesPath = "/debug/goroutines"
+	debugBuildInfoPath  = "/debug/buildinfo"
+	debugConfigPath     = "/debug/config"
+	debugComponentsPath = "/debug/components"
+	debugLogLevelPath   = "/debug/loglevel"
+)
+
+type buildInfo struct {
+	Name         string `json:"name"`
+	Version      string `json:"version"`
+	Node         int64  `json:"node"`
+	GoVersion    string `json:"go_version"`
+	Module       string `json:"module,omitempty"`
+	Revision     string `json:"revision,omitempty"`
+	RevisionTime string `json:"revision_time,omitempty"`
+	Modified     bool   `json:"modified,omitempty"`
+	StartTime    string `json:"start_time"`
+	Uptime       string `json:"uptime"`
+	Goroutines   int    `json:"goroutines"`
+}
+
+// runDebugServer serves the debug endpoints when app.debug.addr is set:
+//
+//	/debug/pprof/      the pprof profiles
+//	/debug/goroutines  the stacks of all goroutines
+//	/debug/buildinfo   the app version, the go version and the vcs revision
+//	/debug/config      the current config with secrets redacted, and the origin of every key for layered configs
+//	/debug/components  the health of every component, checked on request
+//	/debug/loglevel    the level of the default logger, PUT /debug/loglevel?level=debug changes it
+//
+// every request must carry app.debug.secret as a bearer token, without a secret the server only listens on
+// a loopback address
+func (l *Launcher) runDebugServer() {
+	cfg := l.appConfig.Debug
+	if cfg == nil || cfg.Addr == "" {
+		return
+	}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc(debugPprofPath, pprof.Index)
+	mux.HandleFunc(debugPprofPath+"cmdline", pprof.Cmdline)
+	mux.HandleFunc(debugPprofPath+"profile", pprof.Profile)
+	mux.HandleFunc(debugPprofPath+"symbol", pprof.Symbol)
+	mux.HandleFunc(debugPprofPath+"trace", pprof.Trace)
+	mux.HandleFunc(debugGoroutinesPath, handleGoroutines)
+	mux.HandleFunc(debugBuildInfoPath, l.handleBuildInfo)
+	mux.HandleFunc(debugConfigPath, l.handleConfig)
+	mux.HandleFunc(debugComponentsPath, l.handleComponents)
+	mux.HandleFunc(debugLogLevelPath, handleLogLevel)
+
+	l.debugServer = &http.Server{
+		Addr:              cfg.Addr,
+		Handler:           authorizeDebug(cfg.Secret, mux),
+		ReadHeaderTimeout: 5 * time.Second,
+	}
+
+	go func() {
+		log.Infof("debug server listening on %s", cfg.Addr)
+		if err := l.debugServer.ListenAndServe(); err != nil && !errors.Is(err, http.ErrServerClosed) {
+			log.Errorf("debug server stopped: %v", err)
+		}
+	}()
+}
+
+// authorizeDebug rejects the requests without secret as bearer token, it lets all requests in without a secret
+func authorizeDebug(secret string, next http.Handler) http.Handler {
+	if secret == "" {
+		return next
+	}
+
+	expected := []byte("Bearer " + secret)
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if subtle.ConstantTimeCompare([]byte(r.Header.Get("Authorization")), expected) != 1 {
+			w.Header().Set("WWW-Authenticate", "Bearer")
+			http.Error(w, "unauthorized", http.StatusUnauthorized)
+			return
+		}
+
+		next.ServeHTTP(w, r)
+	})
+}
+
+func handleGoroutines(w http.ResponseWriter, _ *http.Request) {
+	w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+	if err := runtimepprof.Lookup("goroutine").WriteTo(w, 2); err != nil {
+		log.Errorf("failed to dump goroutines: %v", err)
+	}
+}
+
+func (l *Launcher) handleBuildInfo(w http.ResponseWriter, _ *http.Request) {
+	info := &buildInfo{
+		Name:       l.appConfig.Name,
+		Version:    l.appConfig.Version,
+		Node:       l.appConfig.Node,
+		GoVersion:  runtime.Version(),
+		StartTime:  l.startTime.Format(time.RFC3339),
+		Uptime:     time.Since(l.startTime).Truncate(time.Second).String(),
+		Goroutines: runtime.NumGoroutine(),
+	}
+
+	if build, ok := debug.ReadBuildInfo(); ok {
+		info.Module = build.Main.Path
+		for _, setting := range build.Settings {
+			switch setting.Key {
+			case "vcs.revision":
+				info.Revision = setting.Value
+			case "vcs.time":
+				info.RevisionTime = setting.Value
+			case "vcs.modified":
+				info.Modified = setting.Value == "true"
+			}
+		}
+	}
+
+	writeJSON(w, http.StatusOK, info)
+}
+
+func (l *Launcher) handleConfig(w http.ResponseWriter, _ *http.Request) {
+	tree := make(map[string]interface{})
+	if err := l.config.Scan(&tree); err != nil {
+		http.Error(w, fmt.Sprintf("failed to scan config: %v", err), http.StatusInternalServerError)
+		return
+	}
+
+	resp := map[string]interface{}{"config": configx.Redact(tree)}
+	if l.layeredConfig != nil {
+		resp["origins"] = l.layeredConfig.Origins()
+	}
+
+	writeJSON(w, http.StatusOK, resp)
+}
+
+func (l *Launcher) handleComponents(w http.ResponseWriter, r *http.Request) {
+	writeJSON(w, http.StatusOK, l.health.Check(r.Context()))
+}
+
+// handleLogLevel reports the level of the default logger, PUT changes it until the next reload of app.log_level
+func handleLogLevel(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case http.MethodGet:
+	case http.MethodPut:
+		level := r.URL.Query().Get("level")
+		if err := defaultlogger.SetLevel(level); err != nil {
+			http.Error(w, fmt.Sprintf("invalid log level %s: %v", level, err), http.StatusBadRequest)
+			return
+		}
+		log.Infof("log level changed to %s by the debug server", level)
+	default:
+		w.Header().Set("Allow", "GET, PUT")
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	writeJSON(w, http.StatusOK, map[string]string{"level": defaultlogger.Level()})
+}
+
+func writeJSON(w http.ResponseWriter, code int, v any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(code)
+	if err := json.NewEncoder(w).Encode(v); err != nil {
+		log.Errorf("failed to write response: %v", err)
+	}
+}
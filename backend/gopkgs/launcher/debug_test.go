@@ -0,0 +1,72 @@
+package launcher
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/cloudzenith/DouTok/backend/gopkgs/internal/defaultlogger"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestDebug_Validate(t *testing.T) {
+	for addr, ok := range map[string]bool{
+		"127.0.0.1:9101": true,
+		"[::1]:9101":     true,
+		"localhost:9101": true,
+		":9101":          false,
+		"0.0.0.0:9101":   false,
+		"10.0.0.8:9101":  false,
+		"9101":           false,
+	} {
+		err := (&Debug{Addr: addr}).Validate()
+		assert.Equal(t, ok, err == nil, addr)
+	}
+
+	assert.NoError(t, (&Debug{Addr: ":9101", Secret: "s3cr3t"}).Validate())
+}
+
+func TestAuthorizeDebug(t *testing.T) {
+	handler := authorizeDebug("s3cr3t", http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		w.WriteHeader(http.StatusNoContent)
+	}))
+
+	for header, code := range map[string]int{
+		"":              http.StatusUnauthorized,
+		"Bearer wrong":  http.StatusUnauthorized,
+		"s3cr3t":        http.StatusUnauthorized,
+		"Bearer s3cr3t": http.StatusNoContent,
+	} {
+		req := httptest.NewRequest(http.MethodGet, debugBuildInfoPath, nil)
+		if header != "" {
+			req.Header.Set("Authorization", header)
+		}
+
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, req)
+		assert.Equal(t, code, rec.Code, header)
+	}
+}
+
+func TestHandleLogLevel(t *testing.T) {
+	previous := defaultlogger.Level()
+	t.Cleanup(func() {
+		require.NoError(t, defaultlogger.SetLevel(previous))
+	})
+
+	rec := httptest.NewRecorder()
+	handleLogLevel(rec, httptest.NewRequest(http.MethodPut, debugLogLevelPath+"?level=warn", nil))
+	assert.Equal(t, http.StatusOK, rec.Code)
+	assert.JSONEq(t, `{"level":"warn"}`, rec.Body.String())
+	assert.Equal(t, "warn", defaultlogger.Level())
+
+	rec = httptest.NewRecorder()
+	handleLogLevel(rec, httptest.NewRequest(http.MethodPut, debugLogLevelPath+"?level=loud", nil))
+	assert.Equal(t, http.StatusBadRequest, rec.Code)
+	assert.Equal(t, "warn", defaultlogger.Level())
+
+	rec = httptest.NewRecorder()
+	handleLogLevel(rec, httptest.NewRequest(http.MethodPost, debugLogLevelPath, nil))
+	assert.Equal(t, http.StatusMethodNotAllowed, rec.Code)
+}
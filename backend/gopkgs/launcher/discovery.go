@@ -0,0 +1,22 @@
+package launcher
+
+import (
+	"fmt"
+
+	"github.com/cloudzenith/DouTok/backend/gopkgs/registryx"
+)
+
+const registryConfigKey = "registry"
+
+// initRegistry creates the registry chosen by the registry key of the config, consul when it is not set,
+// the service registers to it and registryx.GetGrpcConn discovers the other services with it
+func (l *Launcher) initRegistry() {
+	cfg := &registryx.Config{}
+	if err := l.config.Value(registryConfigKey).Scan(cfg); err != nil && l.notNeedServiceDiscovery {
+		return
+	}
+
+	if err := registryx.Init(cfg); err != nil {
+		panic(fmt.Errorf("failed to init registry: %v", err))
+	}
+}
@@ -8,21 +8,22 @@ import (
 	"github.com/cloudzenith/DouTok/backend/gopkgs/components/redisx"
 	"github.com/cloudzenith/DouTok/backend/gopkgs/components/rmqconsumerx"
 	"github.com/cloudzenith/DouTok/backend/gopkgs/components/rmqproducerx"
+	"github.com/cloudzenith/DouTok/backend/gopkgs/configx"
 	"github.com/cloudzenith/DouTok/backend/gopkgs/launcher"
 	"github.com/cloudzenith/DouTok/backend/gopkgs/launcher/example/api"
 	"github.com/cloudzenith/DouTok/backend/gopkgs/launcher/example/application"
-	"github.com/go-kratos/kratos/v2/config"
-	"github.com/go-kratos/kratos/v2/config/file"
+	"github.com/cloudzenith/DouTok/backend/gopkgs/middlewares/metrics"
 	"github.com/go-kratos/kratos/v2/log"
 	"github.com/go-kratos/kratos/v2/transport/grpc"
 	"github.com/go-kratos/kratos/v2/transport/http"
-	"gopkg.in/yaml.v2"
+	"os"
 )
 
 func initHttpServer() func(configValue interface{}) *http.Server {
 	return func(configValue interface{}) *http.Server {
 		srv := http.NewServer(
 			http.Address(":8000"),
+			http.Middleware(metrics.Server()),
 		)
 
 		redisClient := redisx.GetClient(context.Background())
@@ -49,6 +50,8 @@ func initGrpcServer() func(configValue interface{}) *grpc.Server {
 	return func(configValue interface{}) *grpc.Server {
 		srv := grpc.NewServer(
 			grpc.Address(":9000"),
+			grpc.CustomHealth(),
+			grpc.Middleware(metrics.Server()),
 		)
 
 		api.RegisterTestServiceServer(srv, application.Application{})
@@ -64,16 +67,14 @@ type TestMessage struct {
 func main() {
 	launcher.New(
 		launcher.WithConfigValue(&struct{}{}),
-		launcher.WithConfigOptions(
-			config.WithSource(
-				file.NewSource("configs/"),
-			),
-			config.WithDecoder(func(keyValue *config.KeyValue, m map[string]interface{}) error {
-				return yaml.Unmarshal(keyValue.Value, m)
-			}),
+		launcher.WithConfigLayers(
+			configx.File("configs/"),
+			configx.Env("DOUTOK_"),
+			configx.Flags(os.Args[1:]),
 		),
 		launcher.WithHttpServer(initHttpServer()),
 		launcher.WithGrpcServer(initGrpcServer()),
+		launcher.WithAdminServer(":9100"),
 		launcher.WithAfterServerStartHandler(func() {
 			redisClient := redisx.GetClient(context.Background())
 			if err := redisClient.Ping(context.Background()).Err(); err != nil {
@@ -3,18 +3,24 @@ package launcher
 import (
 	"context"
 	"fmt"
-	"github.com/cloudzenith/DouTok/backend/gopkgs/components/consulx"
+	"github.com/cloudzenith/DouTok/backend/gopkgs/configx"
+	"github.com/cloudzenith/DouTok/backend/gopkgs/gofer"
+	"github.com/cloudzenith/DouTok/backend/gopkgs/health"
 	"github.com/cloudzenith/DouTok/backend/gopkgs/internal/defaultlogger"
 	"github.com/cloudzenith/DouTok/backend/gopkgs/internal/shutdown"
+	"github.com/cloudzenith/DouTok/backend/gopkgs/registryx"
+	"github.com/cloudzenith/DouTok/backend/gopkgs/scheduler"
 	"github.com/cloudzenith/DouTok/backend/gopkgs/snowflakeutil"
-	consul "github.com/go-kratos/kratos/contrib/registry/consul/v2"
 	"github.com/go-kratos/kratos/v2"
 	"github.com/go-kratos/kratos/v2/config"
 	"github.com/go-kratos/kratos/v2/log"
+	"github.com/go-kratos/kratos/v2/transport"
 	"github.com/go-kratos/kratos/v2/transport/grpc"
 	"github.com/go-kratos/kratos/v2/transport/http"
+	stdhttp "net/http"
 	"os"
 	"sync"
+	"sync/atomic"
 	"time"
 )
 
@@ -26,14 +32,38 @@ type Launcher struct {
 	configWatchMap map[string]config.Observer
 	config         config.Config
 	configValue    interface{}
+	layeredConfig  *configx.Source
+	// currentConfig holds the configValue scanned by the last accepted reload
+	currentConfig atomic.Value
+	reloadMu      sync.Mutex
+	// appliedConfig holds the raw value of every top level key as it was applied, guarded by reloadMu
+	appliedConfig    map[string]interface{}
+	configValidators []func(old, new interface{}) error
+	// startupValidators check the config value before any component starts
+	startupValidators []func(cfg interface{}) error
+	reloadHandlers    []func(old, new interface{})
 
 	logger        log.Logger
 	grpcServer    func(configValue interface{}) *grpc.Server
 	ginServer     func(configValue interface{}) *http.Server
+	scheduler     func(configValue interface{}) *scheduler.Scheduler
 	kratosOptions []kratos.Option
 
 	componentsLauncher *ComponentsLauncher
 
+	appConfig         *App
+	registrar         *drainingRegistrar
+	appDone           chan struct{}
+	stopAccepting     chan struct{}
+	stopAcceptingOnce sync.Once
+	stopOnce          sync.Once
+
+	adminAddr   string
+	adminServer *stdhttp.Server
+	debugServer *stdhttp.Server
+	startTime   time.Time
+	health      *health.Checker
+
 	beforeConfigInitHandlers  []func()
 	afterConfigInitHandlers   []func()
 	beforeServerStartHandlers []func()
@@ -44,6 +74,9 @@ type Launcher struct {
 func New(options ...Option) *Launcher {
 	launcher := &Launcher{
 		configWatchMap: make(map[string]config.Observer),
+		appDone:        make(chan struct{}),
+		stopAccepting:  make(chan struct{}),
+		health:         health.NewChecker(),
 	}
 	for _, option := range options {
 		option(launcher)
@@ -56,20 +89,50 @@ func (l *Launcher) Run() {
 	dir, _ := os.Getwd()
 	log.Context(context.Background()).Info("current work directory: %s", dir)
 
-	l.runInitConfig()
+	if err := l.Start(); err != nil {
+		log.Errorf("failed to start: %v", err)
+		os.Exit(1)
+	}
+
+	<-shutdown.FiredCh()
+	l.Stop()
+}
+
+// Start loads the config, launches the components and starts the servers, it returns once the handlers
+// after server start are done. Run starts the launcher and stops it on signals, tests such as the ones of
+// launcher/testkit start and stop it themselves.
+func (l *Launcher) Start() error {
+	l.startTime = time.Now()
+	if err := l.runInitConfig(); err != nil {
+		return err
+	}
 
 	l.runHandlers(l.beforeServerStartHandlers, "start to run handlers before server start")
-	l.componentsLauncher.Launch()
+	if err := l.componentsLauncher.Launch(); err != nil {
+		l.componentsLauncher.Close()
+		gofer.ReleasePools()
+		return fmt.Errorf("failed to launch components: %w", err)
+	}
+	l.initRegistry()
+	for name, check := range l.componentsLauncher.HealthChecks() {
+		l.health.RegisterComponent(name, check)
+	}
+	l.health.Start()
+	l.runAdminServer()
 	l.newKratosApp()
+	l.runDebugServer()
 	<-l.run()
 	l.runHandlers(l.afterServerStartHandlers, "start to run handlers after server start")
 
-	<-shutdown.FiredCh()
-	shutdown.Wait(10 * time.Second)
-	l.runHandlers(l.shutdownHandlers, "start to run shutdown handlers")
+	return nil
+}
+
+// Stop shuts the started launcher down in phases, it is done once
+func (l *Launcher) Stop() {
+	l.stopOnce.Do(l.shutdown)
 }
 
-func (l *Launcher) runInitConfig() {
+func (l *Launcher) runInitConfig() error {
 	l.runHandlers(l.beforeConfigInitHandlers, "start to run handlers before config init")
 
 	cfg := config.New(
@@ -80,18 +143,24 @@ func (l *Launcher) runInitConfig() {
 	}
 
 	l.config = cfg
-	for key, observer := range l.configWatchMap {
-		if err := cfg.Watch(key, observer); err != nil {
-			panic(fmt.Errorf("failed to watch config: %v", err))
-		}
-	}
-
 	if err := cfg.Scan(l.configValue); err != nil {
 		panic(fmt.Errorf("failed to scan config value: %v", err))
 	}
+	l.currentConfig.Store(configHolder{l.configValue})
+	if l.layeredConfig != nil {
+		log.Infof("effective config:\n%s", l.layeredConfig.Dump())
+	}
+	if err := l.validateConfig(); err != nil {
+		_ = cfg.Close()
+		return err
+	}
 
+	l.initLogLevel()
+	l.initPools()
 	l.componentsLauncher = NewComponentsLauncher(cfg)
+	l.watchConfig()
 	l.runHandlers(l.afterConfigInitHandlers, "start to run handlers after config init")
+	return nil
 }
 
 func (l *Launcher) initTracer(cfg *App) {
@@ -113,7 +182,19 @@ func (l *Launcher) runHandlers(handlers []func(), info string) {
 }
 
 func (l *Launcher) newKratosApp() {
-	options := make([]kratos.Option, 0)
+	l.appConfig = &App{}
+	if err := l.config.Value(appConfigKey).Scan(l.appConfig); err != nil {
+		panic(fmt.Errorf("failed to scan app config: %v", err))
+	}
+
+	options := []kratos.Option{
+		kratos.StopTimeout(time.Duration(l.shutdownConfig().DrainTimeout) * time.Second),
+		// kratos stops the app on signals as well, it has to wait until the service is deregistered
+		kratos.BeforeStop(func(context.Context) error {
+			<-l.stopAccepting
+			return nil
+		}),
+	}
 
 	if l.logger != nil {
 		options = append(options, kratos.Logger(l.logger))
@@ -121,36 +202,39 @@ func (l *Launcher) newKratosApp() {
 		options = append(options, kratos.Logger(defaultlogger.GetLogger()))
 	}
 
+	// kratos.Server replaces the servers of the previous options, so they are passed at once
+	var servers []transport.Server
 	if l.grpcServer != nil {
-		options = append(options, kratos.Server(l.grpcServer(l.configValue)))
+		grpcServer := l.grpcServer(l.configValue)
+		l.health.RegisterGrpc(grpcServer)
+		servers = append(servers, grpcServer)
 	}
 
 	if l.ginServer != nil {
-		options = append(options, kratos.Server(l.ginServer(l.configValue)))
+		servers = append(servers, l.ginServer(l.configValue))
+	}
+
+	if l.scheduler != nil {
+		servers = append(servers, l.scheduler(l.configValue))
 	}
+	options = append(options, kratos.Server(servers...))
 
 	if len(l.kratosOptions) > 0 {
 		options = append(options, l.kratosOptions...)
 	}
 
 	if !l.notNeedServiceDiscovery {
-		consulClient := consulx.GetClient(context.Background())
-		consulReg := consul.New(consulClient)
-		options = append(options, kratos.Registrar(consulReg))
+		l.registrar = &drainingRegistrar{Registrar: registryx.GetRegistry()}
+		options = append(options, kratos.Registrar(l.registrar))
 	}
 
-	value := l.config.Value("app")
-	appConfig := &App{}
-	if err := value.Scan(appConfig); err != nil {
-		panic(fmt.Errorf("failed to scan app config: %v", err))
-	}
 	options = append(
-		options, kratos.Name(appConfig.Name), kratos.Version(appConfig.Version),
+		options, kratos.Name(l.appConfig.Name), kratos.Version(l.appConfig.Version),
 	)
 
 	l.app = kratos.New(options...)
 
-	l.initTracer(appConfig)
+	l.initTracer(l.appConfig)
 }
 
 // nolint
@@ -178,6 +262,7 @@ func (l *Launcher) runKratosApp() <-chan struct{} {
 			log.Context(context.Background()).Fatal("failed to run app")
 			panic(err)
 		}
+		close(l.appDone)
 	}()
 
 	go func() {
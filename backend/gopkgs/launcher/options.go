@@ -1,6 +1,12 @@
 package launcher
 
 import (
+	"context"
+	"time"
+
+	"github.com/cloudzenith/DouTok/backend/gopkgs/configx"
+	"github.com/cloudzenith/DouTok/backend/gopkgs/health"
+	"github.com/cloudzenith/DouTok/backend/gopkgs/scheduler"
 	"github.com/go-kratos/kratos/v2"
 	"github.com/go-kratos/kratos/v2/config"
 	"github.com/go-kratos/kratos/v2/log"
@@ -46,6 +52,18 @@ func WithConfigOptions(options ...config.Option) Option {
 	}
 }
 
+// WithConfigLayers loads the config from layers, from the lowest to the highest precedence, such as
+//
+//	WithConfigLayers(configx.File("configs/"), configx.Consul(client, key), configx.Env("DOUTOK_"), configx.Flags(os.Args[1:]))
+//
+// the effective config is logged with the origin of every key and secrets redacted once it is loaded
+func WithConfigLayers(layers ...configx.Layer) Option {
+	return func(l *Launcher) {
+		l.layeredConfig = configx.New(layers...)
+		l.configOptions = append(l.configOptions, config.WithSource(l.layeredConfig))
+	}
+}
+
 func WithConfigWatcher(key string, observer config.Observer) Option {
 	return func(l *Launcher) {
 		l.configWatchMap[key] = observer
@@ -64,6 +82,13 @@ func WithGrpcServer(s func(configValue interface{}) *grpc.Server) Option {
 	}
 }
 
+// WithScheduler serves the jobs of the scheduler returned by s along with the other servers
+func WithScheduler(s func(configValue interface{}) *scheduler.Scheduler) Option {
+	return func(l *Launcher) {
+		l.scheduler = s
+	}
+}
+
 func WithConfigValue(value interface{}) Option {
 	return func(l *Launcher) {
 		l.configValue = value
@@ -82,8 +107,34 @@ func WithLogger(logger log.Logger) Option {
 	}
 }
 
+// WithoutServiceDiscovery does not register the service, the registry key of the config is still used
+// to discover other services when it is set
 func WithoutServiceDiscovery() Option {
 	return func(l *Launcher) {
 		l.notNeedServiceDiscovery = true
 	}
 }
+
+// WithAdminServer serves the metrics registered to the default prometheus registry on /metrics, which include
+// the go runtime, the pools of the mysql and redis clients and the gofer pools, as well as
+// the requests recorded by the middlewares/metrics middlewares. It serves liveness on /healthz
+// and readiness with the status of every component on /readyz, addr is such as ":9100"
+func WithAdminServer(addr string) Option {
+	return func(l *Launcher) {
+		l.adminAddr = addr
+	}
+}
+
+// WithHealthCheck adds a check to the readiness of the service besides the components, such as a downstream service
+func WithHealthCheck(name string, check func(ctx context.Context) error) Option {
+	return func(l *Launcher) {
+		l.health.Register(name, check)
+	}
+}
+
+// WithHealthCheckInterval sets how often the components are checked, 10 seconds by default
+func WithHealthCheckInterval(interval time.Duration) Option {
+	return func(l *Launcher) {
+		health.WithInterval(interval)(l.health)
+	}
+}
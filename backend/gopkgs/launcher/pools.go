@@ -0,0 +1,100 @@
+package launcher
+
+import (
+	"fmt"
+
+	"github.com/cloudzenith/DouTok/backend/gopkgs/gofer"
+	"github.com/go-kratos/kratos/v2/config"
+	"github.com/go-kratos/kratos/v2/log"
+)
+
+const poolsConfigKey = "pools"
+
+// initPools creates the named gofer pools declared under the pools key of the config, such as
+//
+//	pools:
+//	  notify:
+//	    size: 100
+//	    nonblocking: true
+//
+// and resizes them when their sizes change in the config
+func (l *Launcher) initPools() {
+	pools, ok, err := l.scanPoolsConfig(l.config.Value(poolsConfigKey))
+	if err != nil {
+		panic(err)
+	}
+
+	if !ok {
+		return
+	}
+
+	for name, cfg := range pools {
+		if _, err := gofer.NewNamedPool(name, cfg); err != nil {
+			panic(fmt.Errorf("failed to create pool %s: %v", name, err))
+		}
+		log.Infof("created pool %s with size %d", name, cfg.Size)
+	}
+}
+
+// scanPoolsConfig returns the pool configs of value, it reports false when value has no pools
+func (l *Launcher) scanPoolsConfig(value config.Value) (map[string]*gofer.PoolConfig, bool, error) {
+	values, err := value.Map()
+	if err != nil {
+		return nil, false, nil
+	}
+
+	pools := make(map[string]*gofer.PoolConfig, len(values))
+	for name, v := range values {
+		cfg := &gofer.PoolConfig{}
+		if err := v.Scan(cfg); err != nil {
+			return nil, false, fmt.Errorf("failed to scan config of pool %s: %v", name, err)
+		}
+		cfg.SetDefault()
+		pools[name] = cfg
+	}
+
+	return pools, true, nil
+}
+
+// tunePools resizes the pools whose sizes changed in value and returns the applied pools config,
+// an invalid config is rejected and the pools keep their current sizes. nonblocking and max_blocking_tasks
+// only take effect on a restart, the applied config keeps the values the pools were created with
+func (l *Launcher) tunePools(old any, value config.Value) any {
+	pools, ok, err := l.scanPoolsConfig(value)
+	if err != nil {
+		log.Errorf("rejected config change of pools: %v", err)
+		return old
+	}
+
+	if !ok {
+		return old
+	}
+
+	applied, _ := rawConfig(value).(map[string]interface{})
+	for name, cfg := range pools {
+		p, ok := gofer.GetPool(name)
+		if !ok {
+			log.Errorf("pool %s was added to the config, it will be created on the next restart", name)
+			delete(applied, name)
+			continue
+		}
+
+		created := p.Config()
+		if cfg.Nonblocking != created.Nonblocking || cfg.MaxBlockingTasks != created.MaxBlockingTasks {
+			log.Errorf("rejected change of nonblocking and max_blocking_tasks of pool %s, they can't change without a restart", name)
+			if entry, ok := applied[name].(map[string]interface{}); ok {
+				entry["nonblocking"] = created.Nonblocking
+				entry["max_blocking_tasks"] = created.MaxBlockingTasks
+			}
+		}
+
+		if p.Cap() == cfg.Size {
+			continue
+		}
+
+		p.Tune(cfg.Size)
+		log.Infof("resized pool %s to %d", name, cfg.Size)
+	}
+
+	return applied
+}
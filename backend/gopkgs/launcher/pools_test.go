@@ -0,0 +1,79 @@
+package launcher
+
+import (
+	"context"
+	"sync"
+	"testing"
+
+	"github.com/cloudzenith/DouTok/backend/gopkgs/gofer"
+	"github.com/go-kratos/kratos/v2/config"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type testConfigSource string
+
+func (s testConfigSource) Load() ([]*config.KeyValue, error) {
+	return []*config.KeyValue{{Key: "config.json", Value: []byte(s), Format: "json"}}, nil
+}
+
+func (s testConfigSource) Watch() (config.Watcher, error) {
+	return &testConfigWatcher{stopped: make(chan struct{})}, nil
+}
+
+// testConfigWatcher never reports a change, Next blocks until Stop
+type testConfigWatcher struct {
+	stopped  chan struct{}
+	stopOnce sync.Once
+}
+
+func (w *testConfigWatcher) Next() ([]*config.KeyValue, error) {
+	<-w.stopped
+	return nil, context.Canceled
+}
+
+func (w *testConfigWatcher) Stop() error {
+	w.stopOnce.Do(func() {
+		close(w.stopped)
+	})
+	return nil
+}
+
+func testConfigValue(t *testing.T, content, key string) config.Value {
+	c := config.New(config.WithSource(testConfigSource(content)))
+	require.NoError(t, c.Load())
+	t.Cleanup(func() {
+		_ = c.Close()
+	})
+	return c.Value(key)
+}
+
+func TestTunePools(t *testing.T) {
+	t.Cleanup(gofer.ReleasePools)
+	_, err := gofer.NewNamedPool("tune_test", &gofer.PoolConfig{Size: 10})
+	require.NoError(t, err)
+
+	l := &Launcher{}
+	applied := l.tunePools(nil, testConfigValue(t, `{"pools":{"tune_test":{"size":20},"added":{"size":5}}}`, poolsConfigKey))
+	assert.Equal(t, map[string]interface{}{"tune_test": map[string]interface{}{"size": float64(20)}}, applied)
+	p, _ := gofer.GetPool("tune_test")
+	assert.Equal(t, 20, p.Cap())
+	_, ok := gofer.GetPool("added")
+	assert.False(t, ok)
+
+	// an invalid config is rejected instead of panicking in the config watcher
+	_, _, err = l.scanPoolsConfig(testConfigValue(t, `{"pools":{"tune_test":{"size":"big"}}}`, poolsConfigKey))
+	assert.Error(t, err)
+	assert.NotPanics(t, func() {
+		assert.Equal(t, applied, l.tunePools(applied, testConfigValue(t, `{"pools":{"tune_test":{"size":"big"}}}`, poolsConfigKey)))
+	})
+	assert.Equal(t, 20, p.Cap())
+
+	// the blocking options can't be tuned, the applied config keeps the created ones
+	applied = l.tunePools(applied, testConfigValue(t,
+		`{"pools":{"tune_test":{"size":30,"nonblocking":true,"max_blocking_tasks":5}}}`, poolsConfigKey))
+	assert.Equal(t, map[string]interface{}{"tune_test": map[string]interface{}{
+		"size": float64(30), "nonblocking": false, "max_blocking_tasks": 0,
+	}}, applied)
+	assert.Equal(t, 30, p.Cap())
+}
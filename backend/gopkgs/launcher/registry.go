@@ -0,0 +1,215 @@
+package launcher
+
+import (
+	"fmt"
+	"sort"
+	"sync"
+
+	"github.com/cloudzenith/DouTok/backend/gopkgs/components"
+	"github.com/go-kratos/kratos/v2/config"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// componentDefinition is a component registered to the launcher
+type componentDefinition struct {
+	name      string
+	dependsOn []string
+	closer    func() error
+	// suspender stops the component from taking new work on shutdown, before the gofer pools are drained
+	suspender func() error
+	// collector exports the metrics of the component on the admin server
+	collector prometheus.Collector
+	// reload applies a changed config to the launched component, nil when changes need a restart
+	reload func(cfg config.Value) error
+	// launch scans the config of the component, initializes it and returns its health check
+	launch func(cfg config.Value) (func() error, error)
+}
+
+var (
+	registryMu  sync.RWMutex
+	definitions = make(map[string]*componentDefinition)
+)
+
+type ComponentOption func(d *componentDefinition)
+
+// DependsOn makes the component launch after the named components, which must be configured too
+func DependsOn(names ...string) ComponentOption {
+	return func(d *componentDefinition) {
+		d.dependsOn = append(d.dependsOn, names...)
+	}
+}
+
+// WithCloser sets how to close the component on shutdown, components are closed in reverse launch order
+func WithCloser(closer func() error) ComponentOption {
+	return func(d *componentDefinition) {
+		d.closer = closer
+	}
+}
+
+// WithSuspender sets how to stop the component from taking new work on shutdown, such as pausing
+// the consumption of messages, it runs after the servers are drained and before the components are closed
+func WithSuspender(suspender func() error) ComponentOption {
+	return func(d *componentDefinition) {
+		d.suspender = suspender
+	}
+}
+
+// WithCollector registers the metrics of the component once it is launched
+func WithCollector(collector prometheus.Collector) ComponentOption {
+	return func(d *componentDefinition) {
+		d.collector = collector
+	}
+}
+
+// WithReload applies changed configs of the component without a restart, reload should reject the changes
+// it can't apply safely by returning an error
+func WithReload[T any](reload func(cfg components.ConfigMap[*T]) error) ComponentOption {
+	return func(d *componentDefinition) {
+		d.reload = func(cfg config.Value) error {
+			cm, err := scanComponentConfig[T](cfg)
+			if err != nil {
+				return err
+			}
+
+			return reload(cm)
+		}
+	}
+}
+
+// RegisterComponent makes the launcher launch the component configured under components.<name>. Every key
+// of its config is scanned into a T and passed to initFunc, which returns the health check of the component.
+// Registering a name twice replaces the previous component.
+func RegisterComponent[T any](
+	name string,
+	initFunc func(cfg components.ConfigMap[*T]) (func() error, error),
+	options ...ComponentOption,
+) {
+	d := &componentDefinition{
+		name: name,
+		launch: func(cfg config.Value) (func() error, error) {
+			return launchComponent(cfg, initFunc)
+		},
+	}
+
+	for _, option := range options {
+		option(d)
+	}
+
+	registryMu.Lock()
+	defer registryMu.Unlock()
+
+	definitions[name] = d
+}
+
+func getComponent(name string) (*componentDefinition, bool) {
+	registryMu.RLock()
+	defer registryMu.RUnlock()
+
+	d, ok := definitions[name]
+	return d, ok
+}
+
+func launchComponent[T any](
+	cfg config.Value,
+	initFunc func(cfg components.ConfigMap[*T]) (func() error, error),
+) (healthCheck func() error, err error) {
+	cm, err := scanComponentConfig[T](cfg)
+	if err != nil {
+		return nil, err
+	}
+
+	// the init functions of the built in components panic when they fail to connect
+	defer func() {
+		if r := recover(); r != nil {
+			err = fmt.Errorf("%v", r)
+		}
+	}()
+
+	healthCheck, err = initFunc(cm)
+	if err != nil {
+		return nil, err
+	}
+
+	if healthCheck == nil {
+		return nil, nil
+	}
+
+	if err := healthCheck(); err != nil {
+		return nil, fmt.Errorf("health check: %w", err)
+	}
+
+	return healthCheck, nil
+}
+
+func scanComponentConfig[T any](cfg config.Value) (components.ConfigMap[*T], error) {
+	values, err := cfg.Map()
+	if err != nil {
+		return nil, fmt.Errorf("get config: %w", err)
+	}
+
+	cm := make(components.ConfigMap[*T], len(values))
+	for key, value := range values {
+		t := new(T)
+		if err := value.Scan(t); err != nil {
+			return nil, fmt.Errorf("scan config %s: %w", key, err)
+		}
+		cm[key] = t
+	}
+
+	return cm, nil
+}
+
+// launchOrder sorts the configured components so every component comes after its dependencies
+func launchOrder(configured []string) ([]*componentDefinition, error) {
+	sort.Strings(configured)
+
+	definitions := make(map[string]*componentDefinition, len(configured))
+	for _, name := range configured {
+		d, ok := getComponent(name)
+		if !ok {
+			return nil, fmt.Errorf("unknown component %s", name)
+		}
+		definitions[name] = d
+	}
+
+	const (
+		visiting = 1
+		visited  = 2
+	)
+	states := make(map[string]int, len(configured))
+	order := make([]*componentDefinition, 0, len(configured))
+
+	var visit func(name string, path []string) error
+	visit = func(name string, path []string) error {
+		switch states[name] {
+		case visited:
+			return nil
+		case visiting:
+			return fmt.Errorf("components depend on each other: %v", append(path, name))
+		}
+
+		d, ok := definitions[name]
+		if !ok {
+			return fmt.Errorf("component %s depends on %s which is not configured", path[len(path)-1], name)
+		}
+
+		states[name] = visiting
+		for _, dependency := range d.dependsOn {
+			if err := visit(dependency, append(path, name)); err != nil {
+				return err
+			}
+		}
+		states[name] = visited
+
+		order = append(order, d)
+		return nil
+	}
+
+	for _, name := range configured {
+		if err := visit(name, nil); err != nil {
+			return nil, err
+		}
+	}
+
+	return order, nil
+}
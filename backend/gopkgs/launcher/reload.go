@@ -0,0 +1,279 @@
+package launcher
+
+import (
+	"encoding/json"
+	"fmt"
+	"reflect"
+
+	"github.com/cloudzenith/DouTok/backend/gopkgs/internal/defaultlogger"
+	"github.com/go-kratos/kratos/v2/config"
+	"github.com/go-kratos/kratos/v2/log"
+)
+
+const appConfigKey = "app"
+
+// configHolder keeps the dynamic type of the values stored in currentConfig the same
+type configHolder struct {
+	value interface{}
+}
+
+// WithConfigValidator checks a reloaded config value before it is applied, the reload is rejected when
+// validate returns an error. T is the type WithConfigValue points to.
+// Use WithStartupConfigValidator to check the config value at startup.
+func WithConfigValidator[T any](validate func(old, new *T) error) Option {
+	return func(l *Launcher) {
+		l.configValidators = append(l.configValidators, func(old, new interface{}) error {
+			o, ok1 := old.(*T)
+			n, ok2 := new.(*T)
+			if !ok1 || !ok2 {
+				return fmt.Errorf("config value is %T, not %T", new, (*T)(nil))
+			}
+
+			return validate(o, n)
+		})
+	}
+}
+
+// WithStartupConfigValidator checks the config value before any component starts, the launcher fails to start
+// when validate returns an error. T is the type WithConfigValue points to.
+func WithStartupConfigValidator[T any](validate func(cfg *T) error) Option {
+	return func(l *Launcher) {
+		l.startupValidators = append(l.startupValidators, func(cfg interface{}) error {
+			c, ok := cfg.(*T)
+			if !ok {
+				return fmt.Errorf("config value is %T, not %T", cfg, (*T)(nil))
+			}
+
+			return validate(c)
+		})
+	}
+}
+
+// validator is implemented by config values which check themselves
+type validator interface {
+	Validate() error
+}
+
+// validateConfig checks the config value at startup with its Validate method and the startup validators,
+// and the debug server config of the app, before any component starts
+func (l *Launcher) validateConfig() error {
+	if v, ok := l.configValue.(validator); ok {
+		if err := v.Validate(); err != nil {
+			return fmt.Errorf("invalid config: %v", err)
+		}
+	}
+
+	for _, validate := range l.startupValidators {
+		if err := validate(l.configValue); err != nil {
+			return fmt.Errorf("invalid config: %v", err)
+		}
+	}
+
+	if l.config == nil {
+		return nil
+	}
+
+	app := &App{}
+	if err := l.config.Value(appConfigKey).Scan(app); err != nil || app.Debug == nil || app.Debug.Addr == "" {
+		return nil
+	}
+
+	if err := app.Debug.Validate(); err != nil {
+		return fmt.Errorf("invalid config of the debug server: %v", err)
+	}
+
+	return nil
+}
+
+// WithConfigReloadHandler is called with the old and the new config value after a reload is applied.
+// T is the type WithConfigValue points to.
+func WithConfigReloadHandler[T any](handler func(old, new *T)) Option {
+	return func(l *Launcher) {
+		l.reloadHandlers = append(l.reloadHandlers, func(old, new interface{}) {
+			o, ok1 := old.(*T)
+			n, ok2 := new.(*T)
+			if !ok1 || !ok2 {
+				log.Errorf("config reload handler expects %T, but config value is %T", (*T)(nil), new)
+				return
+			}
+
+			handler(o, n)
+		})
+	}
+}
+
+// CurrentConfig returns the config value of the last accepted reload, the value passed to WithConfigValue
+// stays the config at startup. It returns nil when the config value is not a *T.
+func CurrentConfig[T any](l *Launcher) *T {
+	holder, ok := l.currentConfig.Load().(configHolder)
+	if !ok {
+		return nil
+	}
+
+	value, _ := holder.value.(*T)
+	return value
+}
+
+// watchConfig observes every top level key of the config, since the config keeps one observer per key,
+// the observers of WithConfigWatcher on top level keys are called by the launcher's observer
+func (l *Launcher) watchConfig() {
+	var root map[string]interface{}
+	if err := l.config.Scan(&root); err != nil {
+		log.Warnf("failed to scan config keys, config reload is disabled: %v", err)
+		return
+	}
+	l.appliedConfig = root
+
+	for key := range root {
+		if err := l.config.Watch(key, l.onConfigChanged); err != nil {
+			panic(fmt.Errorf("failed to watch config: %v", err))
+		}
+	}
+
+	for key, observer := range l.configWatchMap {
+		if _, ok := root[key]; ok {
+			continue
+		}
+
+		if err := l.config.Watch(key, observer); err != nil {
+			panic(fmt.Errorf("failed to watch config: %v", err))
+		}
+	}
+}
+
+func (l *Launcher) onConfigChanged(key string, value config.Value) {
+	log.Infof("config %s changed", key)
+	if observer, ok := l.configWatchMap[key]; ok {
+		observer(key, value)
+	}
+
+	l.reloadMu.Lock()
+	defer l.reloadMu.Unlock()
+
+	// the reloaders return what they applied, the rejected changes keep their applied values
+	// so that they do not reach the config value
+	applied := rawConfig(value)
+	switch key {
+	case poolsConfigKey:
+		applied = l.tunePools(l.appliedConfig[key], value)
+	case componentsConfigKey:
+		applied = l.componentsLauncher.Reload(value)
+	case appConfigKey:
+		applied = l.reloadApp(l.appliedConfig[key], value)
+	}
+
+	l.reloadConfigValue(key, applied)
+}
+
+// reloadConfigValue decodes the applied config with the applied value of key into a new config value,
+// and swaps it in when all validators accept it. The caller must hold reloadMu.
+func (l *Launcher) reloadConfigValue(key string, applied any) {
+	tree := make(map[string]interface{}, len(l.appliedConfig)+1)
+	for k, v := range l.appliedConfig {
+		tree[k] = v
+	}
+	tree[key] = applied
+	if applied == nil {
+		delete(tree, key)
+	}
+
+	if l.configValue == nil {
+		l.appliedConfig = tree
+		return
+	}
+
+	old := l.currentConfig.Load().(configHolder).value
+	next := reflect.New(reflect.TypeOf(l.configValue).Elem()).Interface()
+	if err := decodeConfig(tree, next); err != nil {
+		log.Errorf("rejected config reload, failed to scan config value: %v", err)
+		return
+	}
+
+	if reflect.DeepEqual(old, next) {
+		l.appliedConfig = tree
+		return
+	}
+
+	if v, ok := next.(validator); ok {
+		if err := v.Validate(); err != nil {
+			log.Errorf("rejected config reload: %v", err)
+			return
+		}
+	}
+
+	for _, validate := range l.configValidators {
+		if err := validate(old, next); err != nil {
+			log.Errorf("rejected config reload: %v", err)
+			return
+		}
+	}
+
+	l.appliedConfig = tree
+	l.currentConfig.Store(configHolder{next})
+	for _, handler := range l.reloadHandlers {
+		handler(old, next)
+	}
+}
+
+// decodeConfig decodes a config tree into v the way the config scans its sources
+func decodeConfig(tree map[string]interface{}, v interface{}) error {
+	data, err := json.Marshal(tree)
+	if err != nil {
+		return err
+	}
+
+	return json.Unmarshal(data, v)
+}
+
+func (l *Launcher) initLogLevel() {
+	appConfig := &App{}
+	if err := l.config.Value(appConfigKey).Scan(appConfig); err != nil || appConfig.LogLevel == "" {
+		return
+	}
+
+	if err := defaultlogger.SetLevel(appConfig.LogLevel); err != nil {
+		panic(fmt.Errorf("invalid log level %s: %v", appConfig.LogLevel, err))
+	}
+}
+
+// reloadApp applies the log level and returns the app config with it, the other app settings are used
+// to register and trace the service and need a restart
+func (l *Launcher) reloadApp(old any, value config.Value) any {
+	appConfig := &App{}
+	if err := value.Scan(appConfig); err != nil {
+		log.Errorf("rejected app config change, failed to scan: %v", err)
+		return old
+	}
+
+	oldMap, _ := old.(map[string]interface{})
+	newMap, _ := rawConfig(value).(map[string]interface{})
+	if !reflect.DeepEqual(withoutKey(oldMap, "log_level"), withoutKey(newMap, "log_level")) {
+		log.Errorf("rejected change of app config other than log_level, it needs a restart")
+	}
+
+	applied := withoutKey(oldMap, "")
+	if appConfig.LogLevel == "" {
+		return applied
+	}
+
+	if err := defaultlogger.SetLevel(appConfig.LogLevel); err != nil {
+		log.Errorf("rejected log level %s: %v", appConfig.LogLevel, err)
+		return applied
+	}
+	log.Infof("log level set to %s", appConfig.LogLevel)
+
+	applied["log_level"] = newMap["log_level"]
+	return applied
+}
+
+// withoutKey returns a copy of m without key
+func withoutKey(m map[string]interface{}, key string) map[string]interface{} {
+	copied := make(map[string]interface{}, len(m))
+	for k, v := range m {
+		if k != key {
+			copied[k] = v
+		}
+	}
+
+	return copied
+}
@@ -0,0 +1,79 @@
+package launcher
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/cloudzenith/DouTok/backend/gopkgs/internal/defaultlogger"
+	"github.com/go-kratos/kratos/v2/config"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type testReloadConfig struct {
+	App    App `json:"app"`
+	Custom struct {
+		Limit int `json:"limit"`
+	} `json:"custom"`
+}
+
+func TestReload_RejectedChangesAreNotApplied(t *testing.T) {
+	previous := defaultlogger.Level()
+	t.Cleanup(func() {
+		require.NoError(t, defaultlogger.SetLevel(previous))
+	})
+
+	content := `{"app":{"name":"baseService","log_level":"info"},"custom":{"limit":1}}`
+	l := &Launcher{
+		config:             config.New(config.WithSource(testConfigSource(content))),
+		configValue:        &testReloadConfig{},
+		componentsLauncher: &ComponentsLauncher{applied: make(map[string]any)},
+	}
+	require.NoError(t, l.config.Load())
+	t.Cleanup(func() {
+		_ = l.config.Close()
+	})
+	require.NoError(t, l.config.Scan(l.configValue))
+	l.currentConfig.Store(configHolder{l.configValue})
+	require.NoError(t, l.config.Scan(&l.appliedConfig))
+
+	// the log level is applied, the name needs a restart
+	l.onConfigChanged(appConfigKey, testConfigValue(t, `{"app":{"name":"renamed","log_level":"warn"}}`, appConfigKey))
+	assert.Equal(t, "warn", defaultlogger.Level())
+	assert.Equal(t, "baseService", CurrentConfig[testReloadConfig](l).App.Name)
+	assert.Equal(t, "warn", CurrentConfig[testReloadConfig](l).App.LogLevel)
+
+	// a later change of another key does not bring the rejected name in
+	l.onConfigChanged("custom", testConfigValue(t, `{"custom":{"limit":2}}`, "custom"))
+	assert.Equal(t, 2, CurrentConfig[testReloadConfig](l).Custom.Limit)
+	assert.Equal(t, "baseService", CurrentConfig[testReloadConfig](l).App.Name)
+}
+
+func TestValidators(t *testing.T) {
+	var reloadCalls int
+	l := &Launcher{configValue: &testReloadConfig{}}
+	WithConfigValidator(func(old, new *testReloadConfig) error {
+		reloadCalls++
+		assert.NotNil(t, old)
+		return nil
+	})(l)
+	WithStartupConfigValidator(func(cfg *testReloadConfig) error {
+		if cfg.Custom.Limit <= 0 {
+			return errors.New("limit should be positive")
+		}
+		return nil
+	})(l)
+
+	assert.Error(t, l.validateConfig())
+	l.configValue.(*testReloadConfig).Custom.Limit = 1
+	assert.NoError(t, l.validateConfig())
+	assert.Equal(t, 0, reloadCalls)
+
+	// the debug server is checked before any component starts
+	l.config = config.New(config.WithSource(testConfigSource(`{"app":{"debug":{"addr":":9101"}}}`)))
+	require.NoError(t, l.config.Load())
+	t.Cleanup(func() {
+		_ = l.config.Close()
+	})
+	assert.Error(t, l.validateConfig())
+}
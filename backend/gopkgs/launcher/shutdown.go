@@ -0,0 +1,144 @@
+package launcher
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/cloudzenith/DouTok/backend/gopkgs/gofer"
+	"github.com/cloudzenith/DouTok/backend/gopkgs/internal/shutdown"
+	"github.com/go-kratos/kratos/v2/registry"
+)
+
+// drainingRegistrar lets the launcher deregister the service before the servers stop,
+// the deregistration kratos makes when it stops the app is skipped when it is already done
+type drainingRegistrar struct {
+	registry.Registrar
+
+	mu           sync.Mutex
+	instance     *registry.ServiceInstance
+	deregistered bool
+}
+
+func (r *drainingRegistrar) Register(ctx context.Context, instance *registry.ServiceInstance) error {
+	r.mu.Lock()
+	r.instance = instance
+	r.mu.Unlock()
+
+	return r.Registrar.Register(ctx, instance)
+}
+
+func (r *drainingRegistrar) Deregister(ctx context.Context, instance *registry.ServiceInstance) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if r.deregistered {
+		return nil
+	}
+
+	if err := r.Registrar.Deregister(ctx, instance); err != nil {
+		return err
+	}
+	r.deregistered = true
+	return nil
+}
+
+// deregister deregisters the registered instance, it reports whether there was one
+func (r *drainingRegistrar) deregister(ctx context.Context) (bool, error) {
+	r.mu.Lock()
+	instance := r.instance
+	r.mu.Unlock()
+
+	if instance == nil {
+		return false, nil
+	}
+
+	return true, r.Deregister(ctx, instance)
+}
+
+// shutdown stops the service in phases, every phase is bounded by its timeout of the app config:
+// deregister from the registry, stop accepting requests and drain the servers, suspend the message consumers,
+// wait for the named gofer pools and the global one, run the shutdown handlers and close the components
+// in reverse launch order
+func (l *Launcher) shutdown() {
+	cfg := l.shutdownConfig()
+	seconds := func(n int) time.Duration {
+		return time.Duration(n) * time.Second
+	}
+
+	_ = shutdown.RunPhase("deregister", seconds(cfg.DeregisterTimeout+cfg.DeregisterDelay), func(ctx context.Context) error {
+		l.health.Drain()
+		if l.registrar == nil {
+			return nil
+		}
+
+		registered, err := l.registrar.deregister(ctx)
+		if err != nil || !registered {
+			return err
+		}
+
+		// keep serving until clients notice the deregistration
+		select {
+		case <-ctx.Done():
+		case <-time.After(seconds(cfg.DeregisterDelay)):
+		}
+		return nil
+	})
+
+	l.stopAcceptingOnce.Do(func() {
+		close(l.stopAccepting)
+	})
+	_ = shutdown.RunPhase("drain servers", seconds(cfg.DrainTimeout)+time.Second, func(ctx context.Context) error {
+		if l.app == nil {
+			return nil
+		}
+
+		if err := l.app.Stop(); err != nil {
+			return err
+		}
+
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-l.appDone:
+			return nil
+		}
+	})
+
+	_ = shutdown.RunPhase("suspend consumers", seconds(cfg.SuspendTimeout), func(ctx context.Context) error {
+		l.componentsLauncher.Suspend()
+		return nil
+	})
+
+	_ = shutdown.RunPhase("wait pools", seconds(cfg.PoolsTimeout), gofer.WaitPools)
+
+	_ = shutdown.RunPhase("shutdown handlers", seconds(cfg.HandlersTimeout), func(ctx context.Context) error {
+		// the handlers of the shutdown package only run on signals, not when the launcher is stopped by Stop
+		select {
+		case <-shutdown.FiredCh():
+			shutdown.Wait(seconds(cfg.HandlersTimeout))
+		default:
+		}
+		l.runHandlers(l.shutdownHandlers, "start to run shutdown handlers")
+		return nil
+	})
+
+	_ = shutdown.RunPhase("close components", seconds(cfg.CloseTimeout), func(ctx context.Context) error {
+		l.componentsLauncher.Close()
+		return nil
+	})
+
+	gofer.ReleasePools()
+	l.health.Close()
+	l.stopAdminServers()
+}
+
+func (l *Launcher) shutdownConfig() *Shutdown {
+	cfg := &Shutdown{}
+	if l.appConfig != nil && l.appConfig.Shutdown != nil {
+		cfg = l.appConfig.Shutdown
+	}
+
+	cfg.SetDefault()
+	return cfg
+}
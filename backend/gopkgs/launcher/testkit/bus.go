@@ -0,0 +1,295 @@
+package testkit
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/apache/rocketmq-client-go/v2"
+	"github.com/apache/rocketmq-client-go/v2/consumer"
+	"github.com/apache/rocketmq-client-go/v2/primitive"
+	"github.com/cloudzenith/DouTok/backend/gopkgs/components/rmqconsumerx"
+	"github.com/cloudzenith/DouTok/backend/gopkgs/components/rmqproducerx"
+)
+
+const maxRedeliveries = 3
+
+var ErrRequestNotSupported = errors.New("request reply is not supported by the in-memory bus")
+
+// Bus is an in-memory stand-in for rocketmq. Every consumer group of a topic receives each message once,
+// the consumers of a group take turns. Messages are delivered asynchronously, Wait blocks until they are consumed.
+type Bus struct {
+	mu     sync.Mutex
+	groups map[string]map[string]*busGroup // topic -> consumer group -> subscription
+	nextID atomic.Int64
+	wg     sync.WaitGroup
+}
+
+type busGroup struct {
+	consumers []*busSubscription
+	next      int
+}
+
+type busSubscription struct {
+	consumer *busConsumer
+	selector consumer.MessageSelector
+	handler  func(context.Context, ...*primitive.MessageExt) (consumer.ConsumeResult, error)
+}
+
+func NewBus() *Bus {
+	return &Bus{groups: make(map[string]map[string]*busGroup)}
+}
+
+// Wait blocks until every published message is consumed, or given up after its redeliveries
+func (b *Bus) Wait() {
+	b.wg.Wait()
+}
+
+// Install makes rmqconsumerx and rmqproducerx create their clients on the bus
+func (b *Bus) Install() {
+	rmqconsumerx.SetConsumerFactory(func(c *rmqconsumerx.Config) (rocketmq.PushConsumer, error) {
+		return &busConsumer{bus: b, group: c.ConsumerGroup}, nil
+	})
+	rmqproducerx.SetProducerFactory(func(c *rmqproducerx.Config) (rocketmq.Producer, error) {
+		return &busProducer{bus: b}, nil
+	})
+}
+
+func (b *Bus) publish(messages ...*primitive.Message) *primitive.SendResult {
+	result := &primitive.SendResult{Status: primitive.SendOK, MessageQueue: &primitive.MessageQueue{}}
+	ids := make([]string, 0, len(messages))
+
+	for _, msg := range messages {
+		id := fmt.Sprintf("%016X", b.nextID.Add(1))
+		ids = append(ids, id)
+		result.MessageQueue.Topic = msg.Topic
+
+		b.mu.Lock()
+		for _, group := range b.groups[msg.Topic] {
+			sub := group.pick(msg)
+			if sub == nil {
+				continue
+			}
+
+			ext := &primitive.MessageExt{MsgId: id, OffsetMsgId: id, BornTimestamp: time.Now().UnixMilli()}
+			copyMessage(&ext.Message, msg)
+			b.wg.Add(1)
+			go b.deliver(sub, ext)
+		}
+		b.mu.Unlock()
+	}
+
+	result.MsgID = strings.Join(ids, ",")
+	result.OffsetMsgID = result.MsgID
+	return result
+}
+
+func (b *Bus) deliver(sub *busSubscription, msg *primitive.MessageExt) {
+	defer b.wg.Done()
+
+	for ; msg.ReconsumeTimes <= maxRedeliveries; msg.ReconsumeTimes++ {
+		sub.consumer.waitResumed()
+
+		result, err := sub.handler(context.Background(), msg)
+		if err == nil && result == consumer.ConsumeSuccess {
+			return
+		}
+	}
+}
+
+func (b *Bus) subscribe(sub *busSubscription, topic string) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	groups, ok := b.groups[topic]
+	if !ok {
+		groups = make(map[string]*busGroup)
+		b.groups[topic] = groups
+	}
+
+	group, ok := groups[sub.consumer.group]
+	if !ok {
+		group = &busGroup{}
+		groups[sub.consumer.group] = group
+	}
+	group.consumers = append(group.consumers, sub)
+}
+
+func (b *Bus) unsubscribe(c *busConsumer, topic string) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	group, ok := b.groups[topic][c.group]
+	if !ok {
+		return
+	}
+
+	consumers := group.consumers[:0]
+	for _, sub := range group.consumers {
+		if sub.consumer != c {
+			consumers = append(consumers, sub)
+		}
+	}
+	group.consumers = consumers
+}
+
+// copyMessage gives every consumer group its own copy, the message carries a lock and its properties map
+func copyMessage(dst, src *primitive.Message) {
+	dst.Topic = src.Topic
+	dst.Body = src.Body
+	dst.Flag = src.Flag
+	dst.TransactionId = src.TransactionId
+	dst.Queue = src.Queue
+	dst.WithProperties(src.GetProperties())
+}
+
+// pick returns the next consumer of the group whose selector accepts msg
+func (g *busGroup) pick(msg *primitive.Message) *busSubscription {
+	for range g.consumers {
+		sub := g.consumers[g.next%len(g.consumers)]
+		g.next++
+		if matchTag(sub.selector, msg.GetTags()) {
+			return sub
+		}
+	}
+
+	return nil
+}
+
+func matchTag(selector consumer.MessageSelector, tag string) bool {
+	if selector.Type != consumer.TAG || selector.Expression == "" || selector.Expression == "*" {
+		return true
+	}
+
+	for _, expected := range strings.Split(selector.Expression, "||") {
+		if strings.TrimSpace(expected) == tag {
+			return true
+		}
+	}
+
+	return false
+}
+
+type busConsumer struct {
+	bus   *Bus
+	group string
+
+	mu      sync.Mutex
+	resumed chan struct{}
+	topics  map[string]struct{}
+}
+
+func (c *busConsumer) Start() error {
+	return nil
+}
+
+func (c *busConsumer) Shutdown() error {
+	c.mu.Lock()
+	topics := c.topics
+	c.topics = nil
+	c.mu.Unlock()
+
+	for topic := range topics {
+		c.bus.unsubscribe(c, topic)
+	}
+	return nil
+}
+
+func (c *busConsumer) Subscribe(
+	topic string, selector consumer.MessageSelector,
+	f func(context.Context, ...*primitive.MessageExt) (consumer.ConsumeResult, error),
+) error {
+	c.mu.Lock()
+	if c.topics == nil {
+		c.topics = make(map[string]struct{})
+	}
+	c.topics[topic] = struct{}{}
+	c.mu.Unlock()
+
+	c.bus.subscribe(&busSubscription{consumer: c, selector: selector, handler: f}, topic)
+	return nil
+}
+
+func (c *busConsumer) Unsubscribe(topic string) error {
+	c.mu.Lock()
+	delete(c.topics, topic)
+	c.mu.Unlock()
+
+	c.bus.unsubscribe(c, topic)
+	return nil
+}
+
+// Suspend holds the deliveries to the consumer until it is resumed
+func (c *busConsumer) Suspend() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.resumed == nil {
+		c.resumed = make(chan struct{})
+	}
+}
+
+func (c *busConsumer) Resume() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.resumed != nil {
+		close(c.resumed)
+		c.resumed = nil
+	}
+}
+
+func (c *busConsumer) waitResumed() {
+	c.mu.Lock()
+	resumed := c.resumed
+	c.mu.Unlock()
+
+	if resumed != nil {
+		<-resumed
+	}
+}
+
+func (c *busConsumer) GetOffsetDiffMap() map[string]int64 {
+	return map[string]int64{}
+}
+
+// busProducer publishes to the bus, RequestAsync is left to the nil rocketmq.Producer since its callback type
+// is internal to rocketmq, it must not be called
+type busProducer struct {
+	rocketmq.Producer
+	bus *Bus
+}
+
+func (p *busProducer) Start() error {
+	return nil
+}
+
+func (p *busProducer) Shutdown() error {
+	return nil
+}
+
+func (p *busProducer) SendSync(_ context.Context, messages ...*primitive.Message) (*primitive.SendResult, error) {
+	return p.bus.publish(messages...), nil
+}
+
+func (p *busProducer) SendAsync(
+	ctx context.Context, callback func(ctx context.Context, result *primitive.SendResult, err error),
+	messages ...*primitive.Message,
+) error {
+	result := p.bus.publish(messages...)
+	go callback(ctx, result, nil)
+	return nil
+}
+
+func (p *busProducer) SendOneWay(_ context.Context, messages ...*primitive.Message) error {
+	p.bus.publish(messages...)
+	return nil
+}
+
+func (p *busProducer) Request(context.Context, time.Duration, *primitive.Message) (*primitive.Message, error) {
+	return nil, ErrRequestNotSupported
+}
@@ -0,0 +1,74 @@
+package testkit
+
+import (
+	"context"
+	"sync"
+	"testing"
+
+	"github.com/apache/rocketmq-client-go/v2/consumer"
+	"github.com/apache/rocketmq-client-go/v2/primitive"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestBus(t *testing.T) {
+	b := NewBus()
+	var (
+		mu       sync.Mutex
+		received = make(map[string][]string)
+		attempts int
+	)
+	record := func(name string) func(context.Context, ...*primitive.MessageExt) (consumer.ConsumeResult, error) {
+		return func(_ context.Context, msgs ...*primitive.MessageExt) (consumer.ConsumeResult, error) {
+			mu.Lock()
+			defer mu.Unlock()
+			for _, msg := range msgs {
+				received[name] = append(received[name], string(msg.Body))
+			}
+			return consumer.ConsumeSuccess, nil
+		}
+	}
+
+	// the consumers of a group take turns, every group receives each message
+	feedA := &busConsumer{bus: b, group: "feed"}
+	feedB := &busConsumer{bus: b, group: "feed"}
+	require.NoError(t, feedA.Subscribe("published", consumer.MessageSelector{}, record("feedA")))
+	require.NoError(t, feedB.Subscribe("published", consumer.MessageSelector{}, record("feedB")))
+
+	tagged := &busConsumer{bus: b, group: "search"}
+	selector := consumer.MessageSelector{Type: consumer.TAG, Expression: "video || live"}
+	require.NoError(t, tagged.Subscribe("published", selector, record("search")))
+
+	failing := &busConsumer{bus: b, group: "failing"}
+	require.NoError(t, failing.Subscribe("published", consumer.MessageSelector{},
+		func(context.Context, ...*primitive.MessageExt) (consumer.ConsumeResult, error) {
+			mu.Lock()
+			defer mu.Unlock()
+			attempts++
+			return consumer.ConsumeRetryLater, nil
+		},
+	))
+
+	p := &busProducer{bus: b}
+	video := primitive.NewMessage("published", []byte("1"))
+	video.WithTag("video")
+	_, err := p.SendSync(context.Background(), video)
+	require.NoError(t, err)
+	require.NoError(t, p.SendOneWay(context.Background(), primitive.NewMessage("published", []byte("2"))))
+	b.Wait()
+
+	assert.Equal(t, []string{"1"}, received["feedA"])
+	assert.Equal(t, []string{"2"}, received["feedB"])
+	assert.Equal(t, []string{"1"}, received["search"])
+	assert.Equal(t, 2*(maxRedeliveries+1), attempts)
+
+	// suspended consumers get the messages once resumed
+	require.NoError(t, failing.Shutdown())
+	feedA.Suspend()
+	feedB.Suspend()
+	require.NoError(t, p.SendOneWay(context.Background(), primitive.NewMessage("published", []byte("3"))))
+	feedA.Resume()
+	feedB.Resume()
+	b.Wait()
+	assert.Equal(t, []string{"1", "3"}, received["feedA"])
+}
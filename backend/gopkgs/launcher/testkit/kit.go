@@ -0,0 +1,459 @@
+// Package testkit boots a launcher based service in process for end to end tests, its grpc and http servers
+// listen on random local ports and its components are replaced by in-process stand-ins:
+//
+//	mysql         sqlite databases in a temporary directory
+//	redis         miniredis
+//	minio         S3, a local filesystem fake
+//	rmqconsumer   Bus, an in-memory message bus shared with rmqproducer
+//	rmqproducer
+//
+// consul and etcd are dropped and the service discovers itself through a static registry. The components are
+// global, so the kits of a test binary must not run in parallel.
+package testkit
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net"
+	"net/url"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/cloudzenith/DouTok/backend/gopkgs/components/mysqlx"
+	"github.com/cloudzenith/DouTok/backend/gopkgs/components/rmqconsumerx"
+	"github.com/cloudzenith/DouTok/backend/gopkgs/components/rmqproducerx"
+	"github.com/cloudzenith/DouTok/backend/gopkgs/configx"
+	"github.com/cloudzenith/DouTok/backend/gopkgs/launcher"
+	"github.com/cloudzenith/DouTok/backend/gopkgs/registryx"
+	"github.com/glebarez/sqlite"
+	"github.com/go-kratos/kratos/v2/config"
+	"github.com/go-kratos/kratos/v2/transport/grpc"
+	"github.com/go-kratos/kratos/v2/transport/http"
+	ggrpc "google.golang.org/grpc"
+	"gorm.io/gorm"
+)
+
+const (
+	localAddr       = "127.0.0.1:0"
+	configLayerName = "testkit"
+	sqlitePragmas   = "?_pragma=busy_timeout(5000)&_pragma=journal_mode(WAL)"
+)
+
+// dialects numbers the sqlite dialect of every kit, the dialectors of mysqlx are global
+var dialects atomic.Int64
+
+type Option func(k *Kit)
+
+// WithConfigFile loads the config of the service from path, a file or a directory, before its components
+// are replaced by the stand-ins
+func WithConfigFile(path string) Option {
+	return func(k *Kit) {
+		k.configFile = path
+	}
+}
+
+// WithConfigValue is the config value of the service, see launcher.WithConfigValue
+func WithConfigValue(value interface{}) Option {
+	return func(k *Kit) {
+		k.configValue = value
+	}
+}
+
+// WithSet sets the config key, such as "components.mysql.default.db_name", after the stand-ins are configured
+func WithSet(key string, value interface{}) Option {
+	return func(k *Kit) {
+		k.sets = append(k.sets, setting{path: strings.Split(key, "."), value: value})
+	}
+}
+
+// WithGrpcServer builds the grpc server of the service, it listens on a random local port whatever its address
+func WithGrpcServer(s func(configValue interface{}) *grpc.Server) Option {
+	return func(k *Kit) {
+		k.grpcServer = s
+	}
+}
+
+// WithHttpServer builds the http server of the service, it listens on a random local port whatever its address
+func WithHttpServer(s func(configValue interface{}) *http.Server) Option {
+	return func(k *Kit) {
+		k.httpServer = s
+	}
+}
+
+// WithLauncherOptions passes options to the launcher, such as handlers after server start. They must not set
+// the config source, servers or service discovery, which are set by the kit.
+func WithLauncherOptions(options ...launcher.Option) Option {
+	return func(k *Kit) {
+		k.launcherOptions = append(k.launcherOptions, options...)
+	}
+}
+
+type setting struct {
+	path  []string
+	value interface{}
+}
+
+// Kit is a started service with its stand-ins, it is closed when the test ends
+type Kit struct {
+	// Bus delivers the messages of rmqproducer to rmqconsumer
+	Bus *Bus
+	// S3 serves the minio components
+	S3 *S3
+
+	t               testing.TB
+	dir             string
+	configFile      string
+	configValue     interface{}
+	sets            []setting
+	grpcServer      func(configValue interface{}) *grpc.Server
+	httpServer      func(configValue interface{}) *http.Server
+	launcherOptions []launcher.Option
+
+	name         string
+	services     map[string][]string
+	dialect      string
+	redis        map[string]*miniredis.Miniredis
+	launcher     *launcher.Launcher
+	grpcEndpoint *url.URL
+	httpEndpoint *url.URL
+
+	mu         sync.Mutex
+	grpcConn   *ggrpc.ClientConn
+	httpClient *http.Client
+	closeOnce  sync.Once
+}
+
+// New starts the service configured by options, the test fails when it can't start
+func New(t testing.TB, options ...Option) *Kit {
+	t.Helper()
+
+	k := &Kit{
+		t:           t,
+		dir:         t.TempDir(),
+		configValue: &struct{}{},
+		redis:       make(map[string]*miniredis.Miniredis),
+		Bus:         NewBus(),
+	}
+	for _, option := range options {
+		option(k)
+	}
+	t.Cleanup(k.Close)
+
+	k.S3 = NewS3(filepath.Join(k.dir, "s3"))
+	k.Bus.Install()
+	k.dialect = fmt.Sprintf("testkit-sqlite-%d", dialects.Add(1))
+	mysqlx.RegisterDialector(k.dialect, k.openSqlite)
+
+	tree, err := k.buildConfig()
+	if err != nil {
+		t.Fatalf("testkit: %v", err)
+	}
+
+	value, err := json.Marshal(tree)
+	if err != nil {
+		t.Fatalf("testkit: marshal config: %v", err)
+	}
+
+	launcherOptions := append(append([]launcher.Option{}, k.launcherOptions...),
+		launcher.WithConfigValue(k.configValue),
+		launcher.WithConfigLayers(configx.NewLayer(configLayerName, &source{value: value})),
+		launcher.WithoutServiceDiscovery(),
+	)
+	if k.grpcServer != nil {
+		launcherOptions = append(launcherOptions, launcher.WithGrpcServer(k.buildGrpcServer))
+	}
+	if k.httpServer != nil {
+		launcherOptions = append(launcherOptions, launcher.WithHttpServer(k.buildHttpServer))
+	}
+
+	k.launcher = launcher.New(launcherOptions...)
+	if err := k.launcher.Start(); err != nil {
+		k.launcher = nil
+		t.Fatalf("testkit: %v", err)
+	}
+
+	return k
+}
+
+// buildConfig loads the config file and points the components at the stand-ins
+func (k *Kit) buildConfig() (map[string]interface{}, error) {
+	tree := make(map[string]interface{})
+	if k.configFile != "" {
+		kvs, err := configx.New(configx.File(k.configFile)).Load()
+		if err != nil {
+			return nil, err
+		}
+
+		if err := json.Unmarshal(kvs[0].Value, &tree); err != nil {
+			return nil, err
+		}
+	}
+
+	app := child(tree, "app")
+	k.name, _ = app["name"].(string)
+	if k.name == "" {
+		k.name = "testkit"
+		app["name"] = k.name
+	}
+	delete(app, "trace_endpoint")
+
+	components := child(tree, "components")
+	delete(components, "consul")
+	delete(components, "etcd")
+
+	for kind, instances := range components {
+		instances, ok := instances.(map[string]interface{})
+		if !ok {
+			continue
+		}
+
+		for name, c := range instances {
+			c, ok := c.(map[string]interface{})
+			if !ok {
+				c = make(map[string]interface{})
+				instances[name] = c
+			}
+
+			if err := k.standIn(kind, name, c); err != nil {
+				return nil, err
+			}
+		}
+	}
+
+	registry := child(tree, "registry")
+	k.services = make(map[string][]string)
+	if services, ok := registry["services"].(map[string]interface{}); ok {
+		for name, endpoints := range services {
+			endpoints, _ := endpoints.([]interface{})
+			for _, endpoint := range endpoints {
+				k.services[name] = append(k.services[name], fmt.Sprint(endpoint))
+			}
+		}
+	}
+	registry["mode"] = registryx.ModeStatic
+
+	for _, s := range k.sets {
+		node := tree
+		for _, key := range s.path[:len(s.path)-1] {
+			node = child(node, key)
+		}
+		node[s.path[len(s.path)-1]] = s.value
+	}
+
+	return tree, nil
+}
+
+// standIn points the config c of the component kind at its stand-in
+func (k *Kit) standIn(kind, name string, c map[string]interface{}) error {
+	switch kind {
+	case "mysql":
+		c["dialect"] = k.dialect
+	case "redis":
+		m := miniredis.NewMiniRedis()
+		if err := m.Start(); err != nil {
+			return fmt.Errorf("start miniredis of %s: %w", name, err)
+		}
+		if password, _ := c["password"].(string); password != "" {
+			m.RequireAuth(password)
+		}
+		k.redis[name] = m
+		c["dsn"] = m.Addr()
+	case "minio":
+		host, port, _ := net.SplitHostPort(k.S3.Addr())
+		c["host"] = host
+		c["port"], _ = strconv.Atoi(port)
+	case "rmqconsumer", "rmqproducer":
+		c["name_server"] = "testkit"
+	}
+
+	return nil
+}
+
+func (k *Kit) openSqlite(c *mysqlx.Config) gorm.Dialector {
+	name := c.DbName
+	if name == "" {
+		name = "default"
+	}
+
+	return sqlite.Open(filepath.Join(k.dir, name+".db") + sqlitePragmas)
+}
+
+func (k *Kit) buildGrpcServer(configValue interface{}) *grpc.Server {
+	srv := k.grpcServer(configValue)
+	grpc.Address(localAddr)(srv)
+
+	endpoint, err := srv.Endpoint()
+	if err != nil {
+		panic(fmt.Errorf("testkit: listen grpc server: %v", err))
+	}
+	k.grpcEndpoint = endpoint
+
+	// the registry is initialized before the servers are built, the service is added once its port is known
+	services := make(map[string][]string, len(k.services)+1)
+	for name, endpoints := range k.services {
+		services[name] = endpoints
+	}
+	services[k.name] = []string{endpoint.String()}
+	if err := registryx.Init(&registryx.Config{Mode: registryx.ModeStatic, Services: services}); err != nil {
+		panic(fmt.Errorf("testkit: init registry: %v", err))
+	}
+
+	return srv
+}
+
+func (k *Kit) buildHttpServer(configValue interface{}) *http.Server {
+	srv := k.httpServer(configValue)
+	http.Address(localAddr)(srv)
+
+	endpoint, err := srv.Endpoint()
+	if err != nil {
+		panic(fmt.Errorf("testkit: listen http server: %v", err))
+	}
+	k.httpEndpoint = endpoint
+
+	return srv
+}
+
+// GrpcConn returns a connection to the grpc server, the generated clients of the service are built on it
+func (k *Kit) GrpcConn() *ggrpc.ClientConn {
+	k.mu.Lock()
+	defer k.mu.Unlock()
+
+	if k.grpcConn != nil {
+		return k.grpcConn
+	}
+
+	if k.grpcEndpoint == nil {
+		k.t.Fatalf("testkit: no grpc server, use WithGrpcServer")
+	}
+
+	conn, err := grpc.DialInsecure(context.Background(), grpc.WithEndpoint(k.grpcEndpoint.Host))
+	if err != nil {
+		k.t.Fatalf("testkit: dial grpc server: %v", err)
+	}
+
+	k.grpcConn = conn
+	return conn
+}
+
+// HttpClient returns a client of the http server, the generated http clients of the service are built on it
+func (k *Kit) HttpClient() *http.Client {
+	k.mu.Lock()
+	defer k.mu.Unlock()
+
+	if k.httpClient != nil {
+		return k.httpClient
+	}
+
+	client, err := http.NewClient(context.Background(), http.WithEndpoint(k.HttpEndpoint()))
+	if err != nil {
+		k.t.Fatalf("testkit: create http client: %v", err)
+	}
+
+	k.httpClient = client
+	return client
+}
+
+// HttpEndpoint is the base url of the http server, such as http://127.0.0.1:51234
+func (k *Kit) HttpEndpoint() string {
+	if k.httpEndpoint == nil {
+		k.t.Fatalf("testkit: no http server, use WithHttpServer")
+	}
+
+	return k.httpEndpoint.String()
+}
+
+// DB returns the gorm client of the mysql component keys, such as for migrations, see mysqlx.GetDBClient
+func (k *Kit) DB(keys ...string) *gorm.DB {
+	return mysqlx.GetDBClient(context.Background(), keys...)
+}
+
+// Redis returns the miniredis serving the redis component key, "default" when key is empty,
+// such as to fast forward its ttls
+func (k *Kit) Redis(key ...string) *miniredis.Miniredis {
+	name := "default"
+	if len(key) > 0 {
+		name = key[0]
+	}
+
+	m, ok := k.redis[name]
+	if !ok {
+		k.t.Fatalf("testkit: redis component %s is not configured", name)
+	}
+
+	return m
+}
+
+// Close stops the service and its stand-ins, it is registered to the cleanup of the test
+func (k *Kit) Close() {
+	k.closeOnce.Do(func() {
+		if k.grpcConn != nil {
+			_ = k.grpcConn.Close()
+		}
+
+		if k.httpClient != nil {
+			_ = k.httpClient.Close()
+		}
+
+		if k.launcher != nil {
+			k.launcher.Stop()
+		}
+
+		rmqconsumerx.SetConsumerFactory(nil)
+		rmqproducerx.SetProducerFactory(nil)
+
+		for _, m := range k.redis {
+			m.Close()
+		}
+
+		if k.S3 != nil {
+			k.S3.Close()
+		}
+	})
+}
+
+// child returns the map under key of tree, replacing anything else
+func child(tree map[string]interface{}, key string) map[string]interface{} {
+	m, ok := tree[key].(map[string]interface{})
+	if !ok {
+		m = make(map[string]interface{})
+		tree[key] = m
+	}
+
+	return m
+}
+
+// source is the config built by the kit, it does not change
+type source struct {
+	value []byte
+}
+
+func (s *source) Load() ([]*config.KeyValue, error) {
+	return []*config.KeyValue{{Key: configLayerName, Value: s.value, Format: "json"}}, nil
+}
+
+func (s *source) Watch() (config.Watcher, error) {
+	ctx, cancel := context.WithCancel(context.Background())
+	return &watcher{ctx: ctx, cancel: cancel}, nil
+}
+
+type watcher struct {
+	ctx    context.Context
+	cancel context.CancelFunc
+}
+
+func (w *watcher) Next() ([]*config.KeyValue, error) {
+	<-w.ctx.Done()
+	return nil, w.ctx.Err()
+}
+
+func (w *watcher) Stop() error {
+	w.cancel()
+	return nil
+}
@@ -0,0 +1,99 @@
+package testkit_test
+
+import (
+	"bytes"
+	"context"
+	"testing"
+
+	"github.com/apache/rocketmq-client-go/v2/consumer"
+	"github.com/apache/rocketmq-client-go/v2/primitive"
+	"github.com/cloudzenith/DouTok/backend/gopkgs/components/miniox"
+	"github.com/cloudzenith/DouTok/backend/gopkgs/components/redisx"
+	"github.com/cloudzenith/DouTok/backend/gopkgs/components/rmqconsumerx"
+	"github.com/cloudzenith/DouTok/backend/gopkgs/components/rmqproducerx"
+	"github.com/cloudzenith/DouTok/backend/gopkgs/launcher"
+	"github.com/cloudzenith/DouTok/backend/gopkgs/launcher/example/api"
+	"github.com/cloudzenith/DouTok/backend/gopkgs/launcher/example/application"
+	"github.com/cloudzenith/DouTok/backend/gopkgs/launcher/testkit"
+	"github.com/cloudzenith/DouTok/backend/gopkgs/registryx"
+	"github.com/go-kratos/kratos/v2/transport/grpc"
+	"github.com/go-kratos/kratos/v2/transport/http"
+	"github.com/minio/minio-go/v7"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type video struct {
+	ID    int64
+	Title string
+}
+
+type published struct {
+	VideoID int64 `json:"video_id"`
+}
+
+func TestKit(t *testing.T) {
+	feed := make(chan int64, 1)
+	k := testkit.New(t,
+		testkit.WithConfigFile("../example/configs/config.yaml"),
+		testkit.WithGrpcServer(func(interface{}) *grpc.Server {
+			srv := grpc.NewServer(grpc.Address(":9000"))
+			api.RegisterTestServiceServer(srv, application.Application{})
+			return srv
+		}),
+		testkit.WithHttpServer(func(interface{}) *http.Server {
+			srv := http.NewServer(http.Address(":8000"))
+			api.RegisterTestServiceHTTPServer(srv, application.Application{})
+			return srv
+		}),
+		testkit.WithLauncherOptions(launcher.WithAfterServerStartHandler(func() {
+			err := rmqconsumerx.GetConsumer[*published](context.Background(), "published").Subscribe(
+				func(_ context.Context, msg *published, _ *primitive.MessageExt) (consumer.ConsumeResult, error) {
+					feed <- msg.VideoID
+					return consumer.ConsumeSuccess, nil
+				},
+			)
+			if err != nil {
+				panic(err)
+			}
+		})),
+	)
+	ctx := context.Background()
+
+	resp, err := api.NewTestServiceClient(k.GrpcConn()).Test(ctx, &api.TestRequest{Test: "grpc"})
+	require.NoError(t, err)
+	assert.Equal(t, "grpcgrpc", resp.Test)
+
+	resp, err = api.NewTestServiceHTTPClient(k.HttpClient()).Test(ctx, &api.TestRequest{Test: "http"})
+	require.NoError(t, err)
+	assert.Equal(t, "httphttp", resp.Test)
+
+	// the service is registered to the static registry once its grpc server listens
+	conn, err := registryx.GetGrpcConn(ctx, "discovery:///launcher-example")
+	require.NoError(t, err)
+	defer conn.Close()
+	resp, err = api.NewTestServiceClient(conn).Test(ctx, &api.TestRequest{Test: "discovery"})
+	require.NoError(t, err)
+	assert.Equal(t, "discoverydiscovery", resp.Test)
+
+	require.NoError(t, k.DB().AutoMigrate(&video{}))
+	require.NoError(t, k.DB().Create(&video{ID: 1, Title: "hello"}).Error)
+	var stored video
+	require.NoError(t, k.DB().First(&stored, 1).Error)
+	assert.Equal(t, "hello", stored.Title)
+
+	require.NoError(t, redisx.GetClient(ctx).Set(ctx, "video:1", "hello", 0).Err())
+	cached, err := k.Redis().Get("video:1")
+	require.NoError(t, err)
+	assert.Equal(t, "hello", cached)
+
+	storage := miniox.GetClient(ctx)
+	require.NoError(t, storage.MakeBucket(ctx, "videos", minio.MakeBucketOptions{}))
+	_, err = storage.Client.PutObject(ctx, "videos", "1.mp4", bytes.NewReader([]byte("mp4")), 3, minio.PutObjectOptions{})
+	require.NoError(t, err)
+
+	_, err = rmqproducerx.GetProducer[*published](ctx, "published").SendSync(ctx, &published{VideoID: 1})
+	require.NoError(t, err)
+	k.Bus.Wait()
+	assert.Equal(t, int64(1), <-feed)
+}
@@ -0,0 +1,480 @@
+package testkit
+
+import (
+	"bufio"
+	"crypto/md5"
+	"encoding/hex"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	s3Namespace     = "http://s3.amazonaws.com/doc/2006-03-01/"
+	s3Region        = "us-east-1"
+	streamingPrefix = "STREAMING-"
+	uploadsDir      = ".uploads"
+)
+
+// S3 is a local filesystem stand-in for minio, it serves the bucket and object operations, multipart uploads
+// and presigned urls of the S3 api without checking signatures. Objects are stored as files under Dir.
+type S3 struct {
+	Dir    string
+	server *httptest.Server
+
+	mu      sync.Mutex
+	types   map[string]string // bucket/key -> content type
+	uploads map[string]*s3Upload
+	nextID  int
+}
+
+type s3Upload struct {
+	bucket string
+	key    string
+}
+
+func NewS3(dir string) *S3 {
+	s := &S3{
+		Dir:     dir,
+		types:   make(map[string]string),
+		uploads: make(map[string]*s3Upload),
+	}
+	s.server = httptest.NewServer(s)
+	return s
+}
+
+// Addr is the host:port of the fake
+func (s *S3) Addr() string {
+	return strings.TrimPrefix(s.server.URL, "http://")
+}
+
+func (s *S3) Close() {
+	s.server.Close()
+}
+
+func (s *S3) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	bucket, key, _ := strings.Cut(strings.TrimPrefix(r.URL.Path, "/"), "/")
+	query := r.URL.Query()
+
+	switch {
+	case bucket == "":
+		s.listBuckets(w)
+	case key == "":
+		s.serveBucket(w, r, bucket)
+	case query.Has("uploads") && r.Method == http.MethodPost:
+		s.createUpload(w, bucket, key)
+	case query.Has("uploadId"):
+		s.serveUpload(w, r, bucket, key, query.Get("uploadId"))
+	default:
+		s.serveObject(w, r, bucket, key)
+	}
+}
+
+func (s *S3) serveBucket(w http.ResponseWriter, r *http.Request, bucket string) {
+	dir := filepath.Join(s.Dir, bucket)
+	_, err := os.Stat(dir)
+	exists := err == nil
+
+	switch {
+	case r.Method == http.MethodPut:
+		if err := os.MkdirAll(dir, 0o755); err != nil {
+			s3Error(w, http.StatusInternalServerError, "InternalError", err.Error())
+			return
+		}
+		w.WriteHeader(http.StatusOK)
+	case !exists:
+		s3Error(w, http.StatusNotFound, "NoSuchBucket", "The specified bucket does not exist")
+	case r.Method == http.MethodHead:
+		w.WriteHeader(http.StatusOK)
+	case r.URL.Query().Has("location"):
+		writeXML(w, http.StatusOK, struct {
+			XMLName xml.Name `xml:"LocationConstraint"`
+			Xmlns   string   `xml:"xmlns,attr"`
+			Region  string   `xml:",chardata"`
+		}{Xmlns: s3Namespace, Region: s3Region})
+	case r.Method == http.MethodGet:
+		s.listObjects(w, bucket, r.URL.Query().Get("prefix"))
+	case r.Method == http.MethodDelete:
+		if err := os.Remove(dir); err != nil {
+			s3Error(w, http.StatusConflict, "BucketNotEmpty", err.Error())
+			return
+		}
+		w.WriteHeader(http.StatusNoContent)
+	default:
+		s3Error(w, http.StatusMethodNotAllowed, "MethodNotAllowed", r.Method)
+	}
+}
+
+func (s *S3) serveObject(w http.ResponseWriter, r *http.Request, bucket, key string) {
+	if _, err := os.Stat(filepath.Join(s.Dir, bucket)); err != nil {
+		s3Error(w, http.StatusNotFound, "NoSuchBucket", "The specified bucket does not exist")
+		return
+	}
+
+	path := s.objectPath(bucket, key)
+	switch r.Method {
+	case http.MethodPut:
+		etag, err := writeBody(path, r)
+		if err != nil {
+			s3Error(w, http.StatusInternalServerError, "InternalError", err.Error())
+			return
+		}
+
+		s.mu.Lock()
+		s.types[bucket+"/"+key] = r.Header.Get("Content-Type")
+		s.mu.Unlock()
+
+		w.Header().Set("ETag", strconv.Quote(etag))
+		w.WriteHeader(http.StatusOK)
+	case http.MethodGet, http.MethodHead:
+		f, err := os.Open(path)
+		if err != nil {
+			s3Error(w, http.StatusNotFound, "NoSuchKey", "The specified key does not exist.")
+			return
+		}
+		defer f.Close()
+
+		info, _ := f.Stat()
+		etag, _ := fileETag(path)
+		s.mu.Lock()
+		contentType := s.types[bucket+"/"+key]
+		s.mu.Unlock()
+		if contentType == "" {
+			contentType = "application/octet-stream"
+		}
+
+		w.Header().Set("Content-Type", contentType)
+		w.Header().Set("ETag", strconv.Quote(etag))
+		w.Header().Set("Last-Modified", info.ModTime().UTC().Format(http.TimeFormat))
+		http.ServeContent(w, r, key, info.ModTime(), f)
+	case http.MethodDelete:
+		_ = os.Remove(path)
+		w.WriteHeader(http.StatusNoContent)
+	default:
+		s3Error(w, http.StatusMethodNotAllowed, "MethodNotAllowed", r.Method)
+	}
+}
+
+func (s *S3) listBuckets(w http.ResponseWriter) {
+	type bucket struct {
+		Name         string
+		CreationDate string
+	}
+
+	result := struct {
+		XMLName xml.Name `xml:"ListAllMyBucketsResult"`
+		Xmlns   string   `xml:"xmlns,attr"`
+		Owner   struct{ ID, DisplayName string }
+		Buckets []bucket `xml:"Buckets>Bucket"`
+	}{Xmlns: s3Namespace}
+
+	entries, _ := os.ReadDir(s.Dir)
+	for _, entry := range entries {
+		if entry.IsDir() && entry.Name() != uploadsDir {
+			info, _ := entry.Info()
+			result.Buckets = append(result.Buckets, bucket{
+				Name: entry.Name(), CreationDate: info.ModTime().UTC().Format(time.RFC3339),
+			})
+		}
+	}
+
+	writeXML(w, http.StatusOK, result)
+}
+
+func (s *S3) listObjects(w http.ResponseWriter, bucket, prefix string) {
+	type object struct {
+		Key          string
+		LastModified string
+		ETag         string
+		Size         int64
+		StorageClass string
+	}
+
+	result := struct {
+		XMLName     xml.Name `xml:"ListBucketResult"`
+		Xmlns       string   `xml:"xmlns,attr"`
+		Name        string
+		Prefix      string
+		KeyCount    int
+		MaxKeys     int
+		IsTruncated bool
+		Contents    []object
+	}{Xmlns: s3Namespace, Name: bucket, Prefix: prefix, MaxKeys: 1000}
+
+	root := filepath.Join(s.Dir, bucket)
+	_ = filepath.WalkDir(root, func(path string, d fs.DirEntry, err error) error {
+		if err != nil || d.IsDir() {
+			return nil
+		}
+
+		key := filepath.ToSlash(strings.TrimPrefix(path, root+string(filepath.Separator)))
+		if !strings.HasPrefix(key, prefix) {
+			return nil
+		}
+
+		info, _ := d.Info()
+		etag, _ := fileETag(path)
+		result.Contents = append(result.Contents, object{
+			Key: key, LastModified: info.ModTime().UTC().Format(time.RFC3339), ETag: strconv.Quote(etag),
+			Size: info.Size(), StorageClass: "STANDARD",
+		})
+		return nil
+	})
+	result.KeyCount = len(result.Contents)
+
+	writeXML(w, http.StatusOK, result)
+}
+
+func (s *S3) createUpload(w http.ResponseWriter, bucket, key string) {
+	s.mu.Lock()
+	s.nextID++
+	id := fmt.Sprintf("upload-%d", s.nextID)
+	s.uploads[id] = &s3Upload{bucket: bucket, key: key}
+	s.mu.Unlock()
+
+	writeXML(w, http.StatusOK, struct {
+		XMLName  xml.Name `xml:"InitiateMultipartUploadResult"`
+		Xmlns    string   `xml:"xmlns,attr"`
+		Bucket   string
+		Key      string
+		UploadId string
+	}{Xmlns: s3Namespace, Bucket: bucket, Key: key, UploadId: id})
+}
+
+func (s *S3) serveUpload(w http.ResponseWriter, r *http.Request, bucket, key, id string) {
+	s.mu.Lock()
+	upload, ok := s.uploads[id]
+	s.mu.Unlock()
+	if !ok || upload.bucket != bucket || upload.key != key {
+		s3Error(w, http.StatusNotFound, "NoSuchUpload", "The specified upload does not exist.")
+		return
+	}
+
+	dir := filepath.Join(s.Dir, uploadsDir, id)
+	switch r.Method {
+	case http.MethodPut:
+		number, err := strconv.Atoi(r.URL.Query().Get("partNumber"))
+		if err != nil || number < 1 {
+			s3Error(w, http.StatusBadRequest, "InvalidArgument", "invalid part number")
+			return
+		}
+
+		etag, err := writeBody(filepath.Join(dir, strconv.Itoa(number)), r)
+		if err != nil {
+			s3Error(w, http.StatusInternalServerError, "InternalError", err.Error())
+			return
+		}
+		w.Header().Set("ETag", strconv.Quote(etag))
+		w.WriteHeader(http.StatusOK)
+	case http.MethodGet:
+		s.listParts(w, upload, id, dir)
+	case http.MethodPost:
+		s.completeUpload(w, r, upload, id, dir)
+	case http.MethodDelete:
+		s.mu.Lock()
+		delete(s.uploads, id)
+		s.mu.Unlock()
+		_ = os.RemoveAll(dir)
+		w.WriteHeader(http.StatusNoContent)
+	default:
+		s3Error(w, http.StatusMethodNotAllowed, "MethodNotAllowed", r.Method)
+	}
+}
+
+func (s *S3) listParts(w http.ResponseWriter, upload *s3Upload, id, dir string) {
+	type part struct {
+		PartNumber   int
+		LastModified string
+		ETag         string
+		Size         int64
+	}
+
+	result := struct {
+		XMLName     xml.Name `xml:"ListPartsResult"`
+		Xmlns       string   `xml:"xmlns,attr"`
+		Bucket      string
+		Key         string
+		UploadId    string
+		MaxParts    int
+		IsTruncated bool
+		Parts       []part `xml:"Part"`
+	}{Xmlns: s3Namespace, Bucket: upload.bucket, Key: upload.key, UploadId: id, MaxParts: 10000}
+
+	for _, number := range partNumbers(dir) {
+		path := filepath.Join(dir, strconv.Itoa(number))
+		info, _ := os.Stat(path)
+		etag, _ := fileETag(path)
+		result.Parts = append(result.Parts, part{
+			PartNumber: number, LastModified: info.ModTime().UTC().Format(time.RFC3339),
+			ETag: strconv.Quote(etag), Size: info.Size(),
+		})
+	}
+
+	writeXML(w, http.StatusOK, result)
+}
+
+func (s *S3) completeUpload(w http.ResponseWriter, r *http.Request, upload *s3Upload, id, dir string) {
+	var request struct {
+		Parts []struct {
+			PartNumber int
+		} `xml:"Part"`
+	}
+	if err := xml.NewDecoder(r.Body).Decode(&request); err != nil {
+		s3Error(w, http.StatusBadRequest, "MalformedXML", err.Error())
+		return
+	}
+
+	path := s.objectPath(upload.bucket, upload.key)
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		s3Error(w, http.StatusInternalServerError, "InternalError", err.Error())
+		return
+	}
+
+	out, err := os.Create(path)
+	if err != nil {
+		s3Error(w, http.StatusInternalServerError, "InternalError", err.Error())
+		return
+	}
+	defer out.Close()
+
+	for _, p := range request.Parts {
+		part, err := os.Open(filepath.Join(dir, strconv.Itoa(p.PartNumber)))
+		if err != nil {
+			s3Error(w, http.StatusBadRequest, "InvalidPart", fmt.Sprintf("part %d not found", p.PartNumber))
+			return
+		}
+		_, err = io.Copy(out, part)
+		part.Close()
+		if err != nil {
+			s3Error(w, http.StatusInternalServerError, "InternalError", err.Error())
+			return
+		}
+	}
+
+	s.mu.Lock()
+	delete(s.uploads, id)
+	s.mu.Unlock()
+	_ = os.RemoveAll(dir)
+
+	etag, _ := fileETag(path)
+	writeXML(w, http.StatusOK, struct {
+		XMLName xml.Name `xml:"CompleteMultipartUploadResult"`
+		Xmlns   string   `xml:"xmlns,attr"`
+		Bucket  string
+		Key     string
+		ETag    string
+	}{Xmlns: s3Namespace, Bucket: upload.bucket, Key: upload.key, ETag: strconv.Quote(etag)})
+}
+
+func (s *S3) objectPath(bucket, key string) string {
+	return filepath.Join(s.Dir, bucket, filepath.FromSlash(key))
+}
+
+func partNumbers(dir string) []int {
+	entries, _ := os.ReadDir(dir)
+	numbers := make([]int, 0, len(entries))
+	for _, entry := range entries {
+		if n, err := strconv.Atoi(entry.Name()); err == nil {
+			numbers = append(numbers, n)
+		}
+	}
+	sort.Ints(numbers)
+	return numbers
+}
+
+// writeBody stores the body of r at path and returns its md5, streaming uploads are decoded from aws-chunked
+func writeBody(path string, r *http.Request) (string, error) {
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return "", err
+	}
+
+	f, err := os.Create(path)
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+
+	hash := md5.New()
+	out := io.MultiWriter(f, hash)
+	if strings.HasPrefix(r.Header.Get("X-Amz-Content-Sha256"), streamingPrefix) {
+		err = decodeChunks(out, r.Body)
+	} else {
+		_, err = io.Copy(out, r.Body)
+	}
+	if err != nil {
+		return "", err
+	}
+
+	return hex.EncodeToString(hash.Sum(nil)), nil
+}
+
+// decodeChunks reads the aws-chunked encoding, chunks are "size[;chunk-signature=...]\r\ndata\r\n" up to
+// a chunk of size 0, which may be followed by trailing headers
+func decodeChunks(w io.Writer, body io.Reader) error {
+	reader := bufio.NewReader(body)
+	for {
+		header, err := reader.ReadString('\n')
+		if err != nil {
+			return err
+		}
+
+		sizeText, _, _ := strings.Cut(strings.TrimSpace(header), ";")
+		size, err := strconv.ParseInt(sizeText, 16, 64)
+		if err != nil {
+			return fmt.Errorf("invalid chunk size %q", sizeText)
+		}
+
+		if size == 0 {
+			return nil
+		}
+
+		if _, err := io.CopyN(w, reader, size); err != nil {
+			return err
+		}
+
+		if _, err := reader.Discard(2); err != nil && !errors.Is(err, io.EOF) {
+			return err
+		}
+	}
+}
+
+func fileETag(path string) (string, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+
+	hash := md5.New()
+	if _, err := io.Copy(hash, f); err != nil {
+		return "", err
+	}
+
+	return hex.EncodeToString(hash.Sum(nil)), nil
+}
+
+func writeXML(w http.ResponseWriter, code int, v any) {
+	w.Header().Set("Content-Type", "application/xml")
+	w.WriteHeader(code)
+	_, _ = w.Write([]byte(xml.Header))
+	_ = xml.NewEncoder(w).Encode(v)
+}
+
+func s3Error(w http.ResponseWriter, code int, s3Code, message string) {
+	writeXML(w, code, struct {
+		XMLName xml.Name `xml:"Error"`
+		Code    string
+		Message string
+	}{Code: s3Code, Message: message})
+}
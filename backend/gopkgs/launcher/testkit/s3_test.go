@@ -0,0 +1,132 @@
+package testkit
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/minio/minio-go/v7"
+	"github.com/minio/minio-go/v7/pkg/credentials"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func newTestS3Client(t *testing.T) *minio.Core {
+	s := NewS3(t.TempDir())
+	t.Cleanup(s.Close)
+
+	client, err := minio.NewCore(s.Addr(), &minio.Options{Creds: credentials.NewStaticV4("root", "rootroot", "")})
+	require.NoError(t, err)
+
+	return client
+}
+
+func TestS3Objects(t *testing.T) {
+	client := newTestS3Client(t)
+	ctx := context.Background()
+
+	exists, err := client.BucketExists(ctx, "videos")
+	require.NoError(t, err)
+	assert.False(t, exists)
+
+	require.NoError(t, client.MakeBucket(ctx, "videos", minio.MakeBucketOptions{}))
+	buckets, err := client.ListBuckets(ctx)
+	require.NoError(t, err)
+	require.Len(t, buckets, 1)
+	assert.Equal(t, "videos", buckets[0].Name)
+
+	// the high level client signs plain http uploads with the aws-chunked streaming encoding
+	body := bytes.Repeat([]byte("doutok"), 20000)
+	_, err = client.Client.PutObject(
+		ctx, "videos", "a/b.mp4", bytes.NewReader(body), int64(len(body)),
+		minio.PutObjectOptions{ContentType: "video/mp4"},
+	)
+	require.NoError(t, err)
+
+	info, err := client.StatObject(ctx, "videos", "a/b.mp4", minio.StatObjectOptions{})
+	require.NoError(t, err)
+	assert.Equal(t, int64(len(body)), info.Size)
+	assert.Equal(t, "video/mp4", info.ContentType)
+
+	object, err := client.Client.GetObject(ctx, "videos", "a/b.mp4", minio.GetObjectOptions{})
+	require.NoError(t, err)
+	got, err := io.ReadAll(object)
+	require.NoError(t, err)
+	assert.Equal(t, body, got)
+
+	var keys []string
+	for o := range client.Client.ListObjects(ctx, "videos", minio.ListObjectsOptions{Prefix: "a/", Recursive: true}) {
+		require.NoError(t, o.Err)
+		keys = append(keys, o.Key)
+	}
+	assert.Equal(t, []string{"a/b.mp4"}, keys)
+
+	require.NoError(t, client.RemoveObject(ctx, "videos", "a/b.mp4", minio.RemoveObjectOptions{}))
+	_, err = client.StatObject(ctx, "videos", "a/b.mp4", minio.StatObjectOptions{})
+	assert.Equal(t, "NoSuchKey", minio.ToErrorResponse(err).Code)
+}
+
+func TestS3Presigned(t *testing.T) {
+	client := newTestS3Client(t)
+	ctx := context.Background()
+	require.NoError(t, client.MakeBucket(ctx, "avatars", minio.MakeBucketOptions{}))
+
+	putURL, err := client.PresignedPutObject(ctx, "avatars", "1.png", time.Minute)
+	require.NoError(t, err)
+	req, err := http.NewRequest(http.MethodPut, putURL.String(), bytes.NewReader([]byte("png")))
+	require.NoError(t, err)
+	resp, err := http.DefaultClient.Do(req)
+	require.NoError(t, err)
+	resp.Body.Close()
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+
+	getURL, err := client.PresignedGetObject(ctx, "avatars", "1.png", time.Minute, nil)
+	require.NoError(t, err)
+	resp, err = http.Get(getURL.String())
+	require.NoError(t, err)
+	defer resp.Body.Close()
+	got, err := io.ReadAll(resp.Body)
+	require.NoError(t, err)
+	assert.Equal(t, "png", string(got))
+}
+
+func TestS3Multipart(t *testing.T) {
+	client := newTestS3Client(t)
+	ctx := context.Background()
+	require.NoError(t, client.MakeBucket(ctx, "videos", minio.MakeBucketOptions{}))
+
+	uploadID, err := client.NewMultipartUpload(ctx, "videos", "big.mp4", minio.PutObjectOptions{})
+	require.NoError(t, err)
+
+	parts := [][]byte{bytes.Repeat([]byte("a"), 1024), bytes.Repeat([]byte("b"), 512)}
+	var completed []minio.CompletePart
+	for i, part := range parts {
+		uploaded, err := client.PutObjectPart(
+			ctx, "videos", "big.mp4", uploadID, i+1, bytes.NewReader(part), int64(len(part)),
+			minio.PutObjectPartOptions{},
+		)
+		require.NoError(t, err)
+		completed = append(completed, minio.CompletePart{PartNumber: uploaded.PartNumber, ETag: uploaded.ETag})
+	}
+
+	listed, err := client.ListObjectParts(ctx, "videos", "big.mp4", uploadID, 0, 100)
+	require.NoError(t, err)
+	require.Len(t, listed.ObjectParts, 2)
+	assert.Equal(t, int64(512), listed.ObjectParts[1].Size)
+
+	_, err = client.CompleteMultipartUpload(ctx, "videos", "big.mp4", uploadID, completed, minio.PutObjectOptions{})
+	require.NoError(t, err)
+
+	info, err := client.StatObject(ctx, "videos", "big.mp4", minio.StatObjectOptions{})
+	require.NoError(t, err)
+	assert.Equal(t, int64(1536), info.Size)
+
+	abortedID, err := client.NewMultipartUpload(ctx, "videos", "aborted.mp4", minio.PutObjectOptions{})
+	require.NoError(t, err)
+	require.NoError(t, client.AbortMultipartUpload(ctx, "videos", "aborted.mp4", abortedID))
+	_, err = client.ListObjectParts(ctx, "videos", "aborted.mp4", abortedID, 0, 100)
+	assert.Equal(t, "NoSuchUpload", minio.ToErrorResponse(err).Code)
+}
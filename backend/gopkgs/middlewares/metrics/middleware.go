@@ -0,0 +1,104 @@
+package metrics
+
+import (
+	"context"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/go-kratos/kratos/v2/errors"
+	"github.com/go-kratos/kratos/v2/middleware"
+	"github.com/go-kratos/kratos/v2/transport"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+const (
+	sideServer = "server"
+	sideClient = "client"
+)
+
+var (
+	registerMetricsOnce sync.Once
+
+	requestsCounter = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: "kratos",
+		Name:      "requests_total",
+		Help:      "Number of handled requests, the errors are the requests with a code other than OK or 200.",
+	}, []string{"side", "transport", "operation", "code"})
+
+	durationHistogram = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: "kratos",
+		Name:      "request_duration_seconds",
+		Help:      "Latency of handled requests.",
+		Buckets:   []float64{.005, .01, .025, .05, .1, .25, .5, 1, 2.5, 5, 10},
+	}, []string{"side", "transport", "operation"})
+)
+
+func registerMetrics() {
+	registerMetricsOnce.Do(func() {
+		prometheus.MustRegister(requestsCounter, durationHistogram)
+	})
+}
+
+// Server records the rate, errors and duration of the requests served over grpc and http,
+// labelled by operation and code
+func Server() middleware.Middleware {
+	registerMetrics()
+
+	return func(handler middleware.Handler) middleware.Handler {
+		return func(ctx context.Context, req interface{}) (interface{}, error) {
+			info, ok := transport.FromServerContext(ctx)
+			if !ok {
+				return handler(ctx, req)
+			}
+
+			return observe(ctx, sideServer, info, handler, req)
+		}
+	}
+}
+
+// Client records the rate, errors and duration of the calls to downstream services over grpc and http,
+// labelled by operation and code
+func Client() middleware.Middleware {
+	registerMetrics()
+
+	return func(handler middleware.Handler) middleware.Handler {
+		return func(ctx context.Context, req interface{}) (interface{}, error) {
+			info, ok := transport.FromClientContext(ctx)
+			if !ok {
+				return handler(ctx, req)
+			}
+
+			return observe(ctx, sideClient, info, handler, req)
+		}
+	}
+}
+
+func observe(
+	ctx context.Context, side string, info transport.Transporter, handler middleware.Handler, req interface{},
+) (interface{}, error) {
+	start := time.Now()
+	reply, err := handler(ctx, req)
+
+	kind := info.Kind().String()
+	requestsCounter.WithLabelValues(side, kind, info.Operation(), code(info.Kind(), err)).Inc()
+	durationHistogram.WithLabelValues(side, kind, info.Operation()).Observe(time.Since(start).Seconds())
+	return reply, err
+}
+
+// code is the grpc status code name of err on grpc, such as Unavailable, and the http status code on http
+func code(kind transport.Kind, err error) string {
+	if kind == transport.KindGRPC {
+		if err == nil {
+			return "OK"
+		}
+
+		return errors.FromError(err).GRPCStatus().Code().String()
+	}
+
+	if err == nil {
+		return "200"
+	}
+
+	return strconv.Itoa(int(errors.FromError(err).Code))
+}
@@ -0,0 +1,67 @@
+package metrics
+
+import (
+	"context"
+	"testing"
+
+	"github.com/go-kratos/kratos/v2/errors"
+	"github.com/go-kratos/kratos/v2/transport"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/assert"
+)
+
+type testTransport struct {
+	transport.Transporter
+	kind      transport.Kind
+	operation string
+}
+
+func (t *testTransport) Kind() transport.Kind {
+	return t.kind
+}
+
+func (t *testTransport) Operation() string {
+	return t.operation
+}
+
+func TestServer(t *testing.T) {
+	var failed error
+	handler := Server()(func(ctx context.Context, req interface{}) (interface{}, error) {
+		return "ok", failed
+	})
+
+	const operation = "/svapi.UserService/Login"
+	ctx := transport.NewServerContext(context.Background(), &testTransport{kind: transport.KindGRPC, operation: operation})
+	_, err := handler(ctx, nil)
+	assert.Nil(t, err)
+
+	failed = errors.ServiceUnavailable("UNAVAILABLE", "unavailable")
+	_, err = handler(ctx, nil)
+	assert.NotNil(t, err)
+
+	httpCtx := transport.NewServerContext(context.Background(), &testTransport{kind: transport.KindHTTP, operation: operation})
+	_, err = handler(httpCtx, nil)
+	assert.NotNil(t, err)
+
+	assert.Equal(t, float64(1), testutil.ToFloat64(requestsCounter.WithLabelValues(sideServer, "grpc", operation, "OK")))
+	assert.Equal(t, float64(1), testutil.ToFloat64(requestsCounter.WithLabelValues(sideServer, "grpc", operation, "Unavailable")))
+	assert.Equal(t, float64(1), testutil.ToFloat64(requestsCounter.WithLabelValues(sideServer, "http", operation, "503")))
+	assert.Equal(t, 2, testutil.CollectAndCount(durationHistogram))
+}
+
+func TestClient(t *testing.T) {
+	handler := Client()(func(ctx context.Context, req interface{}) (interface{}, error) {
+		return "ok", nil
+	})
+
+	const operation = "/svapi.VideoService/Feed"
+	ctx := transport.NewClientContext(context.Background(), &testTransport{kind: transport.KindGRPC, operation: operation})
+	_, err := handler(ctx, nil)
+	assert.Nil(t, err)
+
+	// requests without transport are not recorded
+	_, err = handler(context.Background(), nil)
+	assert.Nil(t, err)
+
+	assert.Equal(t, float64(1), testutil.ToFloat64(requestsCounter.WithLabelValues(sideClient, "grpc", operation, "OK")))
+}
@@ -0,0 +1,231 @@
+package ratelimiter
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net"
+	nethttp "net/http"
+	"strings"
+
+	"github.com/cloudzenith/DouTok/backend/gopkgs/ratelimit"
+	"github.com/go-kratos/kratos/v2/errors"
+	"github.com/go-kratos/kratos/v2/log"
+	"github.com/go-kratos/kratos/v2/middleware"
+	"github.com/go-kratos/kratos/v2/middleware/auth/jwt"
+	"github.com/go-kratos/kratos/v2/transport"
+	"github.com/go-kratos/kratos/v2/transport/http"
+	"github.com/redis/go-redis/v9"
+	"google.golang.org/grpc/peer"
+)
+
+var ErrLimitExceed = errors.New(429, "RATELIMIT", "too many requests")
+
+type options struct {
+	client         *redis.Client
+	userExtractor  func(ctx context.Context) (string, bool)
+	trustedProxies []string
+}
+
+type Option func(*options)
+
+// WithRedisClient sets the redis client used by the redis backend
+func WithRedisClient(client *redis.Client) Option {
+	return func(o *options) {
+		o.client = client
+	}
+}
+
+// WithUserExtractor sets how to get the user id of a request,
+// by default it is the user_id of the jwt claims, or their subject without a user_id
+func WithUserExtractor(extractor func(ctx context.Context) (string, bool)) Option {
+	return func(o *options) {
+		o.userExtractor = extractor
+	}
+}
+
+// WithTrustedProxies sets the CIDRs of the proxies in front of the server, such as 10.0.0.0/8.
+// X-Forwarded-For and X-Real-IP are only read from the requests sent by these proxies,
+// by default the client ip is the address of the peer.
+func WithTrustedProxies(cidrs ...string) Option {
+	return func(o *options) {
+		o.trustedProxies = append(o.trustedProxies, cidrs...)
+	}
+}
+
+// userClaims reads the user of the claims of any type through their json, DouTok claims carry it in user_id
+type userClaims struct {
+	UserId  json.Number `json:"user_id"`
+	Subject string      `json:"sub"`
+}
+
+func defaultUserExtractor(ctx context.Context) (string, bool) {
+	claims, ok := jwt.FromContext(ctx)
+	if !ok {
+		return "", false
+	}
+
+	data, err := json.Marshal(claims)
+	if err != nil {
+		return "", false
+	}
+
+	var user userClaims
+	if err := json.Unmarshal(data, &user); err != nil {
+		return "", false
+	}
+
+	if user.UserId != "" && user.UserId != "0" {
+		return user.UserId.String(), true
+	}
+
+	return user.Subject, user.Subject != ""
+}
+
+// Server limits requests per operation with the rules of cfg, a request is keyed by its user id,
+// or by its client ip when the user is unknown. Requests are let through when the limiter fails.
+// The returned func stops the limiters, it should be called when the server is shut down.
+func Server(cfg *ratelimit.Config, opts ...Option) (middleware.Middleware, func()) {
+	o := &options{
+		userExtractor: defaultUserExtractor,
+	}
+	for _, opt := range opts {
+		opt(o)
+	}
+
+	proxies, err := parseCIDRs(o.trustedProxies)
+	if err != nil {
+		panic(fmt.Errorf("failed to parse trusted proxies: %v", err))
+	}
+
+	cfg.SetDefault()
+	limiters := make(map[string]ratelimit.Limiter, len(cfg.Rules))
+	stop := func() {
+		for _, limiter := range limiters {
+			limiter.Stop()
+		}
+	}
+
+	for _, rule := range cfg.Rules {
+		limiter, err := ratelimit.NewLimiter(cfg.Backend, rule, o.client)
+		if err != nil {
+			stop()
+			panic(fmt.Errorf("failed to create rate limiter: %v", err))
+		}
+
+		limiters[rule.Operation] = limiter
+	}
+
+	return func(handler middleware.Handler) middleware.Handler {
+		return func(ctx context.Context, req interface{}) (interface{}, error) {
+			info, ok := transport.FromServerContext(ctx)
+			if !ok {
+				return handler(ctx, req)
+			}
+
+			operation := info.Operation()
+			limiter, ok := limiters[operation]
+			if !ok {
+				if limiter, ok = limiters[ratelimit.MatchAllOperations]; !ok {
+					return handler(ctx, req)
+				}
+			}
+
+			key := strings.Join([]string{cfg.KeyPrefix, operation, requestKey(ctx, o.userExtractor, proxies)}, ":")
+			result, err := limiter.Allow(ctx, key)
+			if err != nil {
+				log.Context(ctx).Warnf("rate limiter of %s failed: %v", operation, err)
+				return handler(ctx, req)
+			}
+
+			if !result.Allowed {
+				return nil, ErrLimitExceed
+			}
+
+			return handler(ctx, req)
+		}
+	}, stop
+}
+
+func requestKey(ctx context.Context, userExtractor func(ctx context.Context) (string, bool), proxies []*net.IPNet) string {
+	if userId, ok := userExtractor(ctx); ok {
+		return "user:" + userId
+	}
+
+	return "ip:" + clientIP(ctx, proxies)
+}
+
+func clientIP(ctx context.Context, proxies []*net.IPNet) string {
+	if request, ok := http.RequestFromServerContext(ctx); ok {
+		return requestIP(request, proxies)
+	}
+
+	if p, ok := peer.FromContext(ctx); ok {
+		return hostOf(p.Addr.String())
+	}
+
+	return "unknown"
+}
+
+// requestIP returns the address of the peer, the forwarded headers are only trusted when the peer is a trusted proxy.
+// X-Forwarded-For is read from the right, the first address which is not a trusted proxy is the client.
+func requestIP(request *nethttp.Request, proxies []*net.IPNet) string {
+	remote := hostOf(request.RemoteAddr)
+	if !isTrusted(remote, proxies) {
+		return remote
+	}
+
+	if forwarded := request.Header.Get("X-Forwarded-For"); forwarded != "" {
+		hops := strings.Split(forwarded, ",")
+		for i := len(hops) - 1; i >= 0; i-- {
+			hop := strings.TrimSpace(hops[i])
+			if hop != "" && !isTrusted(hop, proxies) {
+				return hop
+			}
+		}
+	}
+
+	if realIP := strings.TrimSpace(request.Header.Get("X-Real-IP")); realIP != "" {
+		return realIP
+	}
+
+	return remote
+}
+
+func parseCIDRs(cidrs []string) ([]*net.IPNet, error) {
+	nets := make([]*net.IPNet, 0, len(cidrs))
+	for _, cidr := range cidrs {
+		_, n, err := net.ParseCIDR(cidr)
+		if err != nil {
+			return nil, err
+		}
+
+		nets = append(nets, n)
+	}
+
+	return nets, nil
+}
+
+func isTrusted(addr string, proxies []*net.IPNet) bool {
+	ip := net.ParseIP(addr)
+	if ip == nil {
+		return false
+	}
+
+	for _, n := range proxies {
+		if n.Contains(ip) {
+			return true
+		}
+	}
+
+	return false
+}
+
+func hostOf(addr string) string {
+	host, _, err := net.SplitHostPort(addr)
+	if err != nil {
+		return addr
+	}
+
+	return host
+}
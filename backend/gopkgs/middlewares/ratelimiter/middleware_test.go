@@ -0,0 +1,109 @@
+package ratelimiter
+
+import (
+	"context"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/cloudzenith/DouTok/backend/gopkgs/ratelimit"
+	"github.com/go-kratos/kratos/v2/middleware/auth/jwt"
+	"github.com/go-kratos/kratos/v2/transport"
+	jwtv5 "github.com/golang-jwt/jwt/v5"
+	"github.com/stretchr/testify/assert"
+)
+
+type userKey struct{}
+
+type testTransport struct {
+	transport.Transporter
+	operation string
+}
+
+func (t *testTransport) Operation() string {
+	return t.operation
+}
+
+func TestServer(t *testing.T) {
+	m, stop := Server(&ratelimit.Config{
+		Backend: ratelimit.BackendMemory,
+		Rules: []*ratelimit.Rule{
+			{Operation: "/svapi.UserService/Login", Rate: 1, Period: 60},
+		},
+	}, WithUserExtractor(func(ctx context.Context) (string, bool) {
+		userId, ok := ctx.Value(userKey{}).(string)
+		return userId, ok
+	}))
+	defer stop()
+
+	handler := m(func(ctx context.Context, req interface{}) (interface{}, error) {
+		return "ok", nil
+	})
+
+	call := func(operation, user string) error {
+		ctx := transport.NewServerContext(context.Background(), &testTransport{operation: operation})
+		ctx = context.WithValue(ctx, userKey{}, user)
+		_, err := handler(ctx, nil)
+		return err
+	}
+
+	assert.Nil(t, call("/svapi.UserService/Login", "1"))
+	assert.ErrorIs(t, call("/svapi.UserService/Login", "1"), ErrLimitExceed)
+	assert.Nil(t, call("/svapi.UserService/Login", "2"))
+	assert.Nil(t, call("/svapi.UserService/Register", "1"))
+	assert.Nil(t, call("/svapi.UserService/Register", "1"))
+}
+
+type testClaims struct {
+	jwtv5.RegisteredClaims
+	UserId int64 `json:"user_id"`
+}
+
+func TestDefaultUserExtractor(t *testing.T) {
+	_, ok := defaultUserExtractor(context.Background())
+	assert.False(t, ok)
+
+	for _, c := range []struct {
+		claims   jwtv5.Claims
+		expected string
+	}{
+		{claims: &testClaims{UserId: 42}, expected: "42"},
+		{claims: &testClaims{RegisteredClaims: jwtv5.RegisteredClaims{Subject: "7"}}, expected: "7"},
+		{claims: jwtv5.MapClaims{"user_id": "42"}, expected: "42"},
+		{claims: &testClaims{}},
+	} {
+		userId, ok := defaultUserExtractor(jwt.NewContext(context.Background(), c.claims))
+		assert.Equal(t, c.expected != "", ok, c.claims)
+		assert.Equal(t, c.expected, userId, c.claims)
+	}
+}
+
+func TestRequestIP(t *testing.T) {
+	proxies, err := parseCIDRs([]string{"10.0.0.0/8"})
+	assert.Nil(t, err)
+
+	for _, c := range []struct {
+		remote    string
+		forwarded string
+		realIP    string
+		expected  string
+	}{
+		{remote: "1.2.3.4:5678", forwarded: "9.9.9.9", realIP: "8.8.8.8", expected: "1.2.3.4"},
+		{remote: "10.0.0.1:5678", expected: "10.0.0.1"},
+		{remote: "10.0.0.1:5678", forwarded: "9.9.9.9, 1.2.3.4, 10.0.0.2", expected: "1.2.3.4"},
+		{remote: "10.0.0.1:5678", realIP: "1.2.3.4", expected: "1.2.3.4"},
+	} {
+		request := httptest.NewRequest("GET", "/", nil)
+		request.RemoteAddr = c.remote
+		if c.forwarded != "" {
+			request.Header.Set("X-Forwarded-For", c.forwarded)
+		}
+		if c.realIP != "" {
+			request.Header.Set("X-Real-IP", c.realIP)
+		}
+
+		assert.Equal(t, c.expected, requestIP(request, proxies), c)
+	}
+
+	_, err = parseCIDRs([]string{"10.0.0.0"})
+	assert.NotNil(t, err)
+}
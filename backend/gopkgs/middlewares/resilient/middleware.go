@@ -0,0 +1,113 @@
+package resilient
+
+import (
+	"context"
+	"sync"
+
+	"github.com/cloudzenith/DouTok/backend/gopkgs/resilience"
+	"github.com/go-kratos/kratos/v2/middleware"
+	"github.com/go-kratos/kratos/v2/transport"
+)
+
+// executor applies the policies of an operation, the bulkhead is shared by all calls to it and every target
+// of the operation has its own breaker
+type executor struct {
+	operation string
+	retry     *resilience.RetryConfig
+	breakers  *resilience.BreakerGroup
+	bulkhead  *resilience.Bulkhead
+}
+
+func newExecutor(operation string, policy *resilience.Policy) *executor {
+	e := &executor{operation: operation, retry: policy.Retry}
+	if policy.Breaker != nil {
+		e.breakers = resilience.NewBreakerGroup(policy.Breaker)
+	}
+
+	if policy.Bulkhead != nil {
+		e.bulkhead = resilience.NewBulkhead(policy.Bulkhead)
+	}
+
+	return e
+}
+
+// execute retries fn, every attempt goes through the bulkhead and the breaker of target
+func (e *executor) execute(ctx context.Context, target string, fn func(ctx context.Context) error) error {
+	attempt := fn
+	if e.breakers != nil {
+		breaker := e.breakers.Get(target + e.operation)
+		next := attempt
+		attempt = func(ctx context.Context) error {
+			return breaker.Execute(ctx, next)
+		}
+	}
+
+	if e.bulkhead != nil {
+		next := attempt
+		attempt = func(ctx context.Context) error {
+			return e.bulkhead.Execute(ctx, next)
+		}
+	}
+
+	if e.retry == nil {
+		return attempt(ctx)
+	}
+
+	return resilience.Retry(ctx, e.retry, attempt)
+}
+
+// Client applies the retry, circuit breaker and bulkhead policies of cfg to the calls of every downstream operation.
+// Operations matching the * policy get their own breakers and bulkhead. The breakers are kept per target and
+// operation, the target is the endpoint of the client such as discovery:///base-service or the address of a replica.
+func Client(cfg *resilience.Config) middleware.Middleware {
+	cfg.SetDefault()
+
+	var (
+		executors sync.Map
+		matchAll  *resilience.Policy
+	)
+	for _, policy := range cfg.Policies {
+		if policy.Operation == resilience.MatchAllOperations {
+			matchAll = policy
+			continue
+		}
+
+		executors.Store(policy.Operation, newExecutor(policy.Operation, policy))
+	}
+
+	getExecutor := func(operation string) (*executor, bool) {
+		if e, ok := executors.Load(operation); ok {
+			return e.(*executor), true
+		}
+
+		if matchAll == nil {
+			return nil, false
+		}
+
+		e, _ := executors.LoadOrStore(operation, newExecutor(operation, matchAll))
+		return e.(*executor), true
+	}
+
+	return func(handler middleware.Handler) middleware.Handler {
+		return func(ctx context.Context, req interface{}) (interface{}, error) {
+			info, ok := transport.FromClientContext(ctx)
+			if !ok {
+				return handler(ctx, req)
+			}
+
+			e, ok := getExecutor(info.Operation())
+			if !ok {
+				return handler(ctx, req)
+			}
+
+			var reply interface{}
+			err := e.execute(ctx, info.Endpoint(), func(ctx context.Context) error {
+				var err error
+				reply, err = handler(ctx, req)
+				return err
+			})
+
+			return reply, err
+		}
+	}
+}
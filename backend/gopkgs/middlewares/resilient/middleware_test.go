@@ -0,0 +1,78 @@
+package resilient
+
+import (
+	"context"
+	"testing"
+
+	"github.com/cloudzenith/DouTok/backend/gopkgs/resilience"
+	"github.com/go-kratos/kratos/v2/errors"
+	"github.com/go-kratos/kratos/v2/transport"
+	"github.com/stretchr/testify/assert"
+)
+
+type testTransport struct {
+	transport.Transporter
+	endpoint  string
+	operation string
+}
+
+func (t *testTransport) Endpoint() string {
+	return t.endpoint
+}
+
+func (t *testTransport) Operation() string {
+	return t.operation
+}
+
+func TestClient(t *testing.T) {
+	m := Client(&resilience.Config{
+		Policies: []*resilience.Policy{
+			{
+				Operation: "/svcore.VideoService/GetVideoById",
+				Retry:     &resilience.RetryConfig{MaxAttempts: 3, InitialBackoff: 1},
+			},
+			{
+				Operation: resilience.MatchAllOperations,
+				Breaker:   &resilience.BreakerConfig{FailureThreshold: 1, OpenTimeout: 60},
+			},
+		},
+	})
+
+	calls := make(map[string]int)
+	handler := m(func(ctx context.Context, req interface{}) (interface{}, error) {
+		info, _ := transport.FromClientContext(ctx)
+		calls[info.Operation()]++
+		if calls[info.Operation()] < 3 {
+			return nil, errors.ServiceUnavailable("UNAVAILABLE", "downstream unavailable")
+		}
+		return "ok", nil
+	})
+
+	callTarget := func(endpoint, operation string) (interface{}, error) {
+		ctx := transport.NewClientContext(context.Background(), &testTransport{endpoint: endpoint, operation: operation})
+		return handler(ctx, nil)
+	}
+	call := func(operation string) (interface{}, error) {
+		return callTarget("10.0.0.1:9000", operation)
+	}
+
+	reply, err := call("/svcore.VideoService/GetVideoById")
+	assert.Nil(t, err)
+	assert.Equal(t, "ok", reply)
+	assert.Equal(t, 3, calls["/svcore.VideoService/GetVideoById"])
+
+	_, err = call("/svcore.UserService/GetUserInfo")
+	assert.NotNil(t, err)
+	_, err = call("/svcore.UserService/GetUserInfo")
+	assert.ErrorIs(t, err, resilience.ErrCircuitOpen)
+	assert.Equal(t, 1, calls["/svcore.UserService/GetUserInfo"])
+
+	// every operation matching * has its own breaker
+	_, err = call("/svcore.CommentService/ListComment")
+	assert.False(t, errors.Is(err, resilience.ErrCircuitOpen))
+
+	// and every target of an operation has its own breaker
+	_, err = callTarget("10.0.0.2:9000", "/svcore.UserService/GetUserInfo")
+	assert.False(t, errors.Is(err, resilience.ErrCircuitOpen))
+	assert.Equal(t, 2, calls["/svcore.UserService/GetUserInfo"])
+}
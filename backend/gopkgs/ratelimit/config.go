@@ -0,0 +1,95 @@
+package ratelimit
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+const (
+	AlgorithmTokenBucket   = "token_bucket"
+	AlgorithmSlidingWindow = "sliding_window"
+
+	BackendRedis  = "redis"
+	BackendMemory = "memory"
+
+	// MatchAllOperations is the operation of the rule applied to operations without their own rule
+	MatchAllOperations = "*"
+)
+
+type Rule struct {
+	// Operation is the full operation name such as /svapi.UserService/Login, or * for all operations
+	Operation string `json:"operation" yaml:"operation"`
+	Algorithm string `json:"algorithm" yaml:"algorithm"`
+	Rate      int    `json:"rate" yaml:"rate"`
+	// Period is in seconds
+	Period int `json:"period" yaml:"period"`
+	Burst  int `json:"burst" yaml:"burst"`
+}
+
+func (r *Rule) SetDefault() {
+	if r.Algorithm == "" {
+		r.Algorithm = AlgorithmTokenBucket
+	}
+
+	if r.Period == 0 {
+		r.Period = 1
+	}
+}
+
+func (r *Rule) GetLimit() Limit {
+	return Limit{
+		Rate:   r.Rate,
+		Period: time.Duration(r.Period) * time.Second,
+		Burst:  r.Burst,
+	}
+}
+
+type Config struct {
+	Backend   string  `json:"backend" yaml:"backend"`
+	KeyPrefix string  `json:"key_prefix" yaml:"key_prefix"`
+	Rules     []*Rule `json:"rules" yaml:"rules"`
+}
+
+func (c *Config) SetDefault() {
+	if c.Backend == "" {
+		c.Backend = BackendRedis
+	}
+
+	if c.KeyPrefix == "" {
+		c.KeyPrefix = "ratelimit"
+	}
+
+	for _, rule := range c.Rules {
+		rule.SetDefault()
+	}
+}
+
+// NewLimiter creates the limiter of a rule, client is only used by the redis backend
+func NewLimiter(backend string, rule *Rule, client *redis.Client) (Limiter, error) {
+	if rule.Rate <= 0 {
+		return nil, fmt.Errorf("rate of rule %s should be positive", rule.Operation)
+	}
+
+	switch backend {
+	case BackendMemory:
+		if rule.Algorithm != AlgorithmTokenBucket {
+			return nil, fmt.Errorf("memory backend does not support algorithm %s", rule.Algorithm)
+		}
+		return NewMemoryTokenBucket(rule.GetLimit()), nil
+	case BackendRedis:
+		if client == nil {
+			return nil, fmt.Errorf("redis backend needs a redis client")
+		}
+
+		switch rule.Algorithm {
+		case AlgorithmTokenBucket:
+			return NewRedisTokenBucket(client, rule.GetLimit()), nil
+		case AlgorithmSlidingWindow:
+			return NewRedisSlidingWindow(client, rule.GetLimit()), nil
+		}
+	}
+
+	return nil, fmt.Errorf("unknown rate limit backend %s or algorithm %s", backend, rule.Algorithm)
+}
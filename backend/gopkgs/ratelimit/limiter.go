@@ -0,0 +1,40 @@
+package ratelimit
+
+import (
+	"context"
+	"time"
+)
+
+// Limit allows Rate events per Period, token bucket limiters also allow bursts of Burst events
+type Limit struct {
+	Rate   int
+	Period time.Duration
+	Burst  int
+}
+
+// tokensPerMs returns the refill speed of a token bucket
+func (l Limit) tokensPerMs() float64 {
+	return float64(l.Rate) / float64(l.Period.Milliseconds())
+}
+
+func (l Limit) capacity() int {
+	if l.Burst > 0 {
+		return l.Burst
+	}
+
+	return l.Rate
+}
+
+type Result struct {
+	Allowed bool
+	// Remaining is the number of events still allowed right now
+	Remaining int
+	// RetryAfter is the time to wait before the next event is allowed, it is zero when Allowed is true
+	RetryAfter time.Duration
+}
+
+type Limiter interface {
+	Allow(ctx context.Context, key string) (*Result, error)
+	// Stop releases the resources held by the limiter, it should not be used after
+	Stop()
+}
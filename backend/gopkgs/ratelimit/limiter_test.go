@@ -0,0 +1,84 @@
+package ratelimit
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/redis/go-redis/v9"
+	"github.com/stretchr/testify/assert"
+)
+
+func newTestClient(t *testing.T) *redis.Client {
+	s := miniredis.RunT(t)
+	client := redis.NewClient(&redis.Options{Addr: s.Addr()})
+	t.Cleanup(func() {
+		_ = client.Close()
+	})
+
+	return client
+}
+
+func assertLimited(t *testing.T, limiter Limiter, allowed int) {
+	for i := 0; i < allowed; i++ {
+		result, err := limiter.Allow(context.Background(), "k")
+		assert.Nil(t, err)
+		assert.True(t, result.Allowed)
+		assert.Equal(t, allowed-i-1, result.Remaining)
+	}
+
+	result, err := limiter.Allow(context.Background(), "k")
+	assert.Nil(t, err)
+	assert.False(t, result.Allowed)
+	assert.Greater(t, result.RetryAfter, time.Duration(0))
+
+	result, err = limiter.Allow(context.Background(), "other")
+	assert.Nil(t, err)
+	assert.True(t, result.Allowed)
+}
+
+func TestRedisTokenBucket(t *testing.T) {
+	limiter := NewRedisTokenBucket(newTestClient(t), Limit{Rate: 1, Period: time.Minute, Burst: 3})
+	assertLimited(t, limiter, 3)
+}
+
+func TestRedisSlidingWindow(t *testing.T) {
+	limiter := NewRedisSlidingWindow(newTestClient(t), Limit{Rate: 2, Period: time.Minute})
+	assertLimited(t, limiter, 2)
+}
+
+func TestMemoryTokenBucket(t *testing.T) {
+	limiter := NewMemoryTokenBucket(Limit{Rate: 2, Period: time.Minute})
+	defer limiter.Stop()
+	assertLimited(t, limiter, 2)
+}
+
+func TestMemoryTokenBucketRefill(t *testing.T) {
+	limiter := NewMemoryTokenBucket(Limit{Rate: 20, Period: time.Second, Burst: 1})
+	defer limiter.Stop()
+
+	result, _ := limiter.Allow(context.Background(), "k")
+	assert.True(t, result.Allowed)
+	result, _ = limiter.Allow(context.Background(), "k")
+	assert.False(t, result.Allowed)
+
+	time.Sleep(result.RetryAfter)
+	result, _ = limiter.Allow(context.Background(), "k")
+	assert.True(t, result.Allowed)
+}
+
+func TestNewLimiter(t *testing.T) {
+	rule := &Rule{Operation: "/svapi.UserService/Login", Algorithm: AlgorithmSlidingWindow, Rate: 1}
+	rule.SetDefault()
+
+	_, err := NewLimiter(BackendMemory, rule, nil)
+	assert.NotNil(t, err)
+
+	_, err = NewLimiter(BackendRedis, rule, nil)
+	assert.NotNil(t, err)
+
+	limiter, err := NewLimiter(BackendRedis, rule, newTestClient(t))
+	assert.Nil(t, err)
+	assert.IsType(t, &RedisSlidingWindow{}, limiter)
+}
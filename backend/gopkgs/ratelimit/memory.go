@@ -0,0 +1,65 @@
+package ratelimit
+
+import (
+	"context"
+	"math"
+	"sync"
+	"time"
+
+	"github.com/jellydator/ttlcache/v3"
+)
+
+type bucket struct {
+	mu     sync.Mutex
+	tokens float64
+	ts     time.Time
+}
+
+// MemoryTokenBucket is a token bucket limiter local to the process, idle buckets are evicted once they are full
+type MemoryTokenBucket struct {
+	limit   Limit
+	buckets *ttlcache.Cache[string, *bucket]
+}
+
+func NewMemoryTokenBucket(limit Limit) *MemoryTokenBucket {
+	buckets := ttlcache.New[string, *bucket](
+		ttlcache.WithTTL[string, *bucket](time.Duration(float64(limit.capacity())/limit.tokensPerMs()) * time.Millisecond),
+	)
+	go buckets.Start()
+
+	return &MemoryTokenBucket{
+		limit:   limit,
+		buckets: buckets,
+	}
+}
+
+func (l *MemoryTokenBucket) Allow(ctx context.Context, key string) (*Result, error) {
+	now := time.Now()
+	item, _ := l.buckets.GetOrSet(key, &bucket{tokens: float64(l.limit.capacity()), ts: now})
+	b := item.Value()
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	rate := l.limit.tokensPerMs()
+	elapsed := float64(now.Sub(b.ts).Milliseconds())
+	b.tokens = math.Min(float64(l.limit.capacity()), b.tokens+math.Max(0, elapsed)*rate)
+	b.ts = now
+
+	if b.tokens < 1 {
+		return &Result{
+			RetryAfter: time.Duration(math.Ceil((1-b.tokens)/rate)) * time.Millisecond,
+		}, nil
+	}
+
+	b.tokens--
+	return &Result{
+		Allowed:   true,
+		Remaining: int(b.tokens),
+	}, nil
+}
+
+// Stop stops evicting idle buckets
+func (l *MemoryTokenBucket) Stop() {
+	l.buckets.Stop()
+}
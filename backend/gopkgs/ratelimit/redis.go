@@ -0,0 +1,119 @@
+package ratelimit
+
+import (
+	"context"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/redis/go-redis/v9"
+)
+
+// tokenBucketScript refills the bucket according to the time elapsed since the last call, then takes one token
+var tokenBucketScript = redis.NewScript(`
+local rate = tonumber(ARGV[1])
+local capacity = tonumber(ARGV[2])
+local now = tonumber(ARGV[3])
+
+local bucket = redis.call('HMGET', KEYS[1], 'tokens', 'ts')
+local tokens = tonumber(bucket[1])
+local ts = tonumber(bucket[2])
+if tokens == nil then
+	tokens = capacity
+	ts = now
+end
+
+tokens = math.min(capacity, tokens + math.max(0, now - ts) * rate)
+
+local allowed = 0
+local retry_after = 0
+if tokens >= 1 then
+	tokens = tokens - 1
+	allowed = 1
+else
+	retry_after = math.ceil((1 - tokens) / rate)
+end
+
+redis.call('HSET', KEYS[1], 'tokens', tostring(tokens), 'ts', now)
+redis.call('PEXPIRE', KEYS[1], math.ceil(capacity / rate))
+return {allowed, math.floor(tokens), retry_after}
+`)
+
+// slidingWindowScript keeps the timestamps of the events in the window in a sorted set
+var slidingWindowScript = redis.NewScript(`
+local window = tonumber(ARGV[1])
+local limit = tonumber(ARGV[2])
+local now = tonumber(ARGV[3])
+
+redis.call('ZREMRANGEBYSCORE', KEYS[1], '-inf', now - window)
+local count = redis.call('ZCARD', KEYS[1])
+if count < limit then
+	redis.call('ZADD', KEYS[1], now, ARGV[4])
+	redis.call('PEXPIRE', KEYS[1], window)
+	return {1, limit - count - 1, 0}
+end
+
+local oldest = redis.call('ZRANGE', KEYS[1], 0, 0, 'WITHSCORES')
+return {0, 0, tonumber(oldest[2]) + window - now}
+`)
+
+type RedisTokenBucket struct {
+	client *redis.Client
+	limit  Limit
+}
+
+func NewRedisTokenBucket(client *redis.Client, limit Limit) *RedisTokenBucket {
+	return &RedisTokenBucket{
+		client: client,
+		limit:  limit,
+	}
+}
+
+func (l *RedisTokenBucket) Allow(ctx context.Context, key string) (*Result, error) {
+	values, err := tokenBucketScript.Run(
+		ctx, l.client, []string{key},
+		l.limit.tokensPerMs(), l.limit.capacity(), time.Now().UnixMilli(),
+	).Int64Slice()
+	if err != nil {
+		return nil, err
+	}
+
+	return newResult(values), nil
+}
+
+// Stop does nothing, the buckets live in redis
+func (l *RedisTokenBucket) Stop() {}
+
+type RedisSlidingWindow struct {
+	client *redis.Client
+	limit  Limit
+}
+
+func NewRedisSlidingWindow(client *redis.Client, limit Limit) *RedisSlidingWindow {
+	return &RedisSlidingWindow{
+		client: client,
+		limit:  limit,
+	}
+}
+
+func (l *RedisSlidingWindow) Allow(ctx context.Context, key string) (*Result, error) {
+	values, err := slidingWindowScript.Run(
+		ctx, l.client, []string{key},
+		l.limit.Period.Milliseconds(), l.limit.Rate, time.Now().UnixMilli(), uuid.NewString(),
+	).Int64Slice()
+	if err != nil {
+		return nil, err
+	}
+
+	return newResult(values), nil
+}
+
+// Stop does nothing, the windows live in redis
+func (l *RedisSlidingWindow) Stop() {}
+
+func newResult(values []int64) *Result {
+	return &Result{
+		Allowed:    values[0] == 1,
+		Remaining:  int(values[1]),
+		RetryAfter: time.Duration(values[2]) * time.Millisecond,
+	}
+}
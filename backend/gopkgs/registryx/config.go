@@ -0,0 +1,39 @@
+package registryx
+
+const (
+	ModeConsul = "consul"
+	ModeEtcd   = "etcd"
+	ModeStatic = "static"
+)
+
+// Config chooses the registry services register to and discover each other with, such as
+//
+//	registry:
+//	  mode: static
+//	  services:
+//	    base-service:
+//	      - grpc://127.0.0.1:9000
+type Config struct {
+	// Mode is consul, etcd or static, consul by default
+	Mode string `json:"mode" yaml:"mode"`
+	// Client is the key of the consul or etcd client in the components config, default by default
+	Client string `json:"client" yaml:"client"`
+	// Namespace is the etcd key prefix of the services, /microservices by default
+	Namespace string `json:"namespace" yaml:"namespace"`
+	// Services are the endpoints of every service in static mode, an endpoint without scheme is a grpc endpoint
+	Services map[string][]string `json:"services" yaml:"services"`
+}
+
+func (c *Config) SetDefault() {
+	if c.Mode == "" {
+		c.Mode = ModeConsul
+	}
+
+	if c.Client == "" {
+		c.Client = "default"
+	}
+
+	if c.Namespace == "" {
+		c.Namespace = "/microservices"
+	}
+}
@@ -0,0 +1,81 @@
+package registryx
+
+import (
+	"context"
+	"fmt"
+	"sync"
+
+	"github.com/cloudzenith/DouTok/backend/gopkgs/components/consulx"
+	"github.com/cloudzenith/DouTok/backend/gopkgs/components/etcdx"
+	"github.com/go-kratos/kratos/contrib/registry/consul/v2"
+	"github.com/go-kratos/kratos/contrib/registry/etcd/v2"
+	"github.com/go-kratos/kratos/v2/middleware/tracing"
+	"github.com/go-kratos/kratos/v2/registry"
+	kratosgrpc "github.com/go-kratos/kratos/v2/transport/grpc"
+	"google.golang.org/grpc"
+)
+
+// Registry registers the service and discovers the others
+type Registry interface {
+	registry.Registrar
+	registry.Discovery
+}
+
+var (
+	globalMu       sync.RWMutex
+	globalRegistry Registry
+)
+
+// New creates the registry of cfg, the consul and etcd modes use the clients of the consulx and etcdx components
+func New(cfg *Config) (Registry, error) {
+	cfg.SetDefault()
+
+	switch cfg.Mode {
+	case ModeConsul:
+		return consul.New(consulx.GetClient(context.Background(), cfg.Client)), nil
+	case ModeEtcd:
+		return etcd.New(etcdx.GetClient(context.Background(), cfg.Client), etcd.Namespace(cfg.Namespace)), nil
+	case ModeStatic:
+		return NewStatic(cfg.Services), nil
+	default:
+		return nil, fmt.Errorf("unknown registry mode %s", cfg.Mode)
+	}
+}
+
+// Init creates the registry of cfg and makes it the one returned by GetRegistry
+func Init(cfg *Config) error {
+	r, err := New(cfg)
+	if err != nil {
+		return err
+	}
+
+	globalMu.Lock()
+	defer globalMu.Unlock()
+
+	globalRegistry = r
+	return nil
+}
+
+func GetRegistry() Registry {
+	globalMu.RLock()
+	defer globalMu.RUnlock()
+
+	if globalRegistry == nil {
+		panic("registry not initialized")
+	}
+
+	return globalRegistry
+}
+
+// GetGrpcConn dials a service through the registry, entryPoint is such as discovery:///base-service
+func GetGrpcConn(ctx context.Context, entryPoint string, options ...kratosgrpc.ClientOption) (*grpc.ClientConn, error) {
+	options = append([]kratosgrpc.ClientOption{
+		kratosgrpc.WithEndpoint(entryPoint),
+		kratosgrpc.WithDiscovery(GetRegistry()),
+		kratosgrpc.WithMiddleware(
+			tracing.Client(),
+		),
+	}, options...)
+
+	return kratosgrpc.DialInsecure(ctx, options...)
+}
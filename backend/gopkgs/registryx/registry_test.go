@@ -0,0 +1,74 @@
+package registryx
+
+import (
+	"context"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/health"
+	"google.golang.org/grpc/health/grpc_health_v1"
+)
+
+func TestNew(t *testing.T) {
+	_, err := New(&Config{Mode: "zookeeper"})
+	assert.NotNil(t, err)
+
+	r, err := New(&Config{Mode: ModeStatic})
+	assert.Nil(t, err)
+	assert.IsType(t, &Static{}, r)
+}
+
+func TestStatic(t *testing.T) {
+	s := NewStatic(map[string][]string{
+		"base-service": {"127.0.0.1:9000", "grpc://127.0.0.1:9001"},
+	})
+
+	instances, err := s.GetService(context.Background(), "base-service")
+	assert.Nil(t, err)
+	assert.Len(t, instances, 2)
+	assert.Equal(t, []string{"grpc://127.0.0.1:9000"}, instances[0].Endpoints)
+
+	_, err = s.GetService(context.Background(), "unknown")
+	assert.NotNil(t, err)
+
+	w, err := s.Watch(context.Background(), "base-service")
+	assert.Nil(t, err)
+	instances, err = w.Next()
+	assert.Nil(t, err)
+	assert.Len(t, instances, 2)
+
+	assert.Nil(t, w.Stop())
+	_, err = w.Next()
+	assert.ErrorIs(t, err, context.Canceled)
+}
+
+func TestGetGrpcConn(t *testing.T) {
+	lis, err := net.Listen("tcp", "127.0.0.1:0")
+	assert.Nil(t, err)
+
+	server := grpc.NewServer()
+	grpc_health_v1.RegisterHealthServer(server, health.NewServer())
+	go func() {
+		_ = server.Serve(lis)
+	}()
+	defer server.Stop()
+
+	assert.Nil(t, Init(&Config{
+		Mode:     ModeStatic,
+		Services: map[string][]string{"base-service": {lis.Addr().String()}},
+	}))
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	conn, err := GetGrpcConn(ctx, "discovery:///base-service")
+	assert.Nil(t, err)
+	defer conn.Close()
+
+	resp, err := grpc_health_v1.NewHealthClient(conn).Check(ctx, &grpc_health_v1.HealthCheckRequest{})
+	assert.Nil(t, err)
+	assert.Equal(t, grpc_health_v1.HealthCheckResponse_SERVING, resp.Status)
+}
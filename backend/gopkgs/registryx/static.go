@@ -0,0 +1,87 @@
+package registryx
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/go-kratos/kratos/v2/log"
+	"github.com/go-kratos/kratos/v2/registry"
+)
+
+// Static serves a fixed address map for local or offline runs, registering is a no-op
+type Static struct {
+	services map[string][]*registry.ServiceInstance
+}
+
+// NewStatic creates a static registry from the endpoints of every service, such as grpc://127.0.0.1:9000,
+// an endpoint without scheme is a grpc endpoint
+func NewStatic(services map[string][]string) *Static {
+	s := &Static{services: make(map[string][]*registry.ServiceInstance, len(services))}
+	for name, endpoints := range services {
+		for i, endpoint := range endpoints {
+			if !strings.Contains(endpoint, "://") {
+				endpoint = "grpc://" + endpoint
+			}
+
+			s.services[name] = append(s.services[name], &registry.ServiceInstance{
+				ID:        fmt.Sprintf("%s-%d", name, i),
+				Name:      name,
+				Endpoints: []string{endpoint},
+			})
+		}
+	}
+
+	return s
+}
+
+func (s *Static) Register(_ context.Context, service *registry.ServiceInstance) error {
+	log.Infof("static registry, service %s is not registered", service.Name)
+	return nil
+}
+
+func (s *Static) Deregister(context.Context, *registry.ServiceInstance) error {
+	return nil
+}
+
+func (s *Static) GetService(_ context.Context, name string) ([]*registry.ServiceInstance, error) {
+	instances, ok := s.services[name]
+	if !ok {
+		return nil, fmt.Errorf("service %s not found in the static registry", name)
+	}
+
+	return instances, nil
+}
+
+func (s *Static) Watch(ctx context.Context, name string) (registry.Watcher, error) {
+	instances, err := s.GetService(ctx, name)
+	if err != nil {
+		return nil, err
+	}
+
+	ctx, cancel := context.WithCancel(ctx)
+	return &staticWatcher{ctx: ctx, cancel: cancel, instances: instances}, nil
+}
+
+// staticWatcher returns the instances once, they never change
+type staticWatcher struct {
+	ctx       context.Context
+	cancel    context.CancelFunc
+	instances []*registry.ServiceInstance
+	sent      bool
+}
+
+func (w *staticWatcher) Next() ([]*registry.ServiceInstance, error) {
+	if !w.sent {
+		w.sent = true
+		return w.instances, nil
+	}
+
+	<-w.ctx.Done()
+	return nil, w.ctx.Err()
+}
+
+func (w *staticWatcher) Stop() error {
+	w.cancel()
+	return nil
+}
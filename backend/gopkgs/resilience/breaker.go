@@ -0,0 +1,195 @@
+package resilience
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/go-kratos/kratos/v2/errors"
+	"github.com/go-kratos/kratos/v2/log"
+)
+
+var ErrCircuitOpen = errors.New(http.StatusServiceUnavailable, "CIRCUIT_OPEN", "circuit breaker is open")
+
+// DefaultIsFailure counts server errors as failures, client errors such as invalid arguments
+// say nothing about the health of the downstream service
+func DefaultIsFailure(err error) bool {
+	return err != nil && errors.FromError(err).Code >= http.StatusInternalServerError
+}
+
+type State int
+
+const (
+	StateClosed State = iota
+	StateOpen
+	StateHalfOpen
+)
+
+func (s State) String() string {
+	switch s {
+	case StateClosed:
+		return "closed"
+	case StateOpen:
+		return "open"
+	default:
+		return "half-open"
+	}
+}
+
+// Breaker is a circuit breaker of a downstream target. It opens after FailureThreshold consecutive failures,
+// rejects calls for OpenTimeout, then lets HalfOpenProbes calls through and closes after SuccessThreshold
+// of them succeeded, a failed probe opens it again.
+type Breaker struct {
+	name string
+	cfg  *BreakerConfig
+	now  func() time.Time
+
+	mu         sync.Mutex
+	state      State
+	generation uint64
+	failures   int
+	successes  int
+	probes     int
+	openedAt   time.Time
+}
+
+func NewBreaker(name string, cfg *BreakerConfig) *Breaker {
+	cfg.SetDefault()
+	return &Breaker{
+		name: name,
+		cfg:  cfg,
+		now:  time.Now,
+	}
+}
+
+func (b *Breaker) State() State {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.checkOpenTimeout()
+	return b.state
+}
+
+// Allow reports whether a call may proceed, the caller must report the result of the call through done
+func (b *Breaker) Allow() (done func(err error), err error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.checkOpenTimeout()
+	switch b.state {
+	case StateOpen:
+		return nil, ErrCircuitOpen
+	case StateHalfOpen:
+		if b.probes >= b.cfg.HalfOpenProbes {
+			return nil, ErrCircuitOpen
+		}
+		b.probes++
+	}
+
+	generation := b.generation
+	return func(err error) {
+		b.report(generation, err)
+	}, nil
+}
+
+// Execute calls fn when the breaker allows it, a panic of fn is reported as a failure and panics again
+func (b *Breaker) Execute(ctx context.Context, fn func(ctx context.Context) error) error {
+	done, err := b.Allow()
+	if err != nil {
+		return err
+	}
+
+	reported := false
+	defer func() {
+		if r := recover(); r != nil {
+			if !reported {
+				done(errors.InternalServer("PANIC", fmt.Sprintf("panic: %v", r)))
+			}
+			panic(r)
+		}
+	}()
+
+	err = fn(ctx)
+	reported = true
+	done(err)
+	return err
+}
+
+func (b *Breaker) report(generation uint64, err error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	// the breaker changed state since the call was allowed
+	if generation != b.generation {
+		return
+	}
+
+	failed := b.cfg.IsFailure(err)
+	switch b.state {
+	case StateClosed:
+		if !failed {
+			b.failures = 0
+			return
+		}
+
+		b.failures++
+		if b.failures >= b.cfg.FailureThreshold {
+			b.setState(StateOpen)
+		}
+	case StateHalfOpen:
+		b.probes--
+		if failed {
+			b.setState(StateOpen)
+			return
+		}
+
+		b.successes++
+		if b.successes >= b.cfg.SuccessThreshold {
+			b.setState(StateClosed)
+		}
+	}
+}
+
+// checkOpenTimeout moves an open breaker to half open once its open timeout elapsed, the caller must hold mu
+func (b *Breaker) checkOpenTimeout() {
+	if b.state == StateOpen && b.now().Sub(b.openedAt) >= b.cfg.openTimeout() {
+		b.setState(StateHalfOpen)
+	}
+}
+
+// setState resets the counters of the breaker, the caller must hold mu
+func (b *Breaker) setState(state State) {
+	log.Warnf("circuit breaker %s changed from %s to %s", b.name, b.state, state)
+
+	b.state = state
+	b.generation++
+	b.failures = 0
+	b.successes = 0
+	b.probes = 0
+	if state == StateOpen {
+		b.openedAt = b.now()
+	}
+}
+
+// BreakerGroup holds a breaker per target sharing the same config
+type BreakerGroup struct {
+	cfg      *BreakerConfig
+	breakers sync.Map
+}
+
+func NewBreakerGroup(cfg *BreakerConfig) *BreakerGroup {
+	cfg.SetDefault()
+	return &BreakerGroup{cfg: cfg}
+}
+
+// Get returns the breaker of target, creating it on first use
+func (g *BreakerGroup) Get(target string) *Breaker {
+	if b, ok := g.breakers.Load(target); ok {
+		return b.(*Breaker)
+	}
+
+	b, _ := g.breakers.LoadOrStore(target, NewBreaker(target, g.cfg))
+	return b.(*Breaker)
+}
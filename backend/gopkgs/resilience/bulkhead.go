@@ -0,0 +1,62 @@
+package resilience
+
+import (
+	"context"
+	"net/http"
+	"time"
+
+	"github.com/go-kratos/kratos/v2/errors"
+)
+
+var ErrBulkheadFull = errors.New(http.StatusServiceUnavailable, "BULKHEAD_FULL", "too many concurrent calls")
+
+// Bulkhead limits the concurrent calls to a downstream target, so a slow target cannot hold all the goroutines
+// of the caller
+type Bulkhead struct {
+	sem     chan struct{}
+	maxWait time.Duration
+}
+
+func NewBulkhead(cfg *BulkheadConfig) *Bulkhead {
+	cfg.SetDefault()
+	return &Bulkhead{
+		sem:     make(chan struct{}, cfg.MaxConcurrent),
+		maxWait: time.Duration(cfg.MaxWait) * time.Millisecond,
+	}
+}
+
+// Execute calls fn when a slot is free within the max wait, otherwise it returns ErrBulkheadFull
+func (b *Bulkhead) Execute(ctx context.Context, fn func(ctx context.Context) error) error {
+	if err := b.acquire(ctx); err != nil {
+		return err
+	}
+	defer func() {
+		<-b.sem
+	}()
+
+	return fn(ctx)
+}
+
+func (b *Bulkhead) acquire(ctx context.Context) error {
+	select {
+	case b.sem <- struct{}{}:
+		return nil
+	default:
+	}
+
+	if b.maxWait <= 0 {
+		return ErrBulkheadFull
+	}
+
+	timer := time.NewTimer(b.maxWait)
+	defer timer.Stop()
+
+	select {
+	case b.sem <- struct{}{}:
+		return nil
+	case <-timer.C:
+		return ErrBulkheadFull
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
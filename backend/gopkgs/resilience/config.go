@@ -0,0 +1,129 @@
+package resilience
+
+import "time"
+
+// MatchAllOperations is the operation of the policy applied to operations without their own policy
+const MatchAllOperations = "*"
+
+type RetryConfig struct {
+	// MaxAttempts includes the first call, 1 disables retrying
+	MaxAttempts int `json:"max_attempts" yaml:"max_attempts"`
+	// InitialBackoff and MaxBackoff are in milliseconds
+	InitialBackoff int     `json:"initial_backoff" yaml:"initial_backoff"`
+	MaxBackoff     int     `json:"max_backoff" yaml:"max_backoff"`
+	Multiplier     float64 `json:"multiplier" yaml:"multiplier"`
+	// Jitter randomizes every backoff by up to this fraction of it, in [0, 1]
+	Jitter float64 `json:"jitter" yaml:"jitter"`
+	// Retryable reports whether a failed call should be retried, DefaultRetryable is used when it is nil
+	Retryable func(err error) bool `json:"-" yaml:"-"`
+}
+
+func (c *RetryConfig) SetDefault() {
+	if c.MaxAttempts == 0 {
+		c.MaxAttempts = 3
+	}
+
+	if c.InitialBackoff == 0 {
+		c.InitialBackoff = 50
+	}
+
+	if c.MaxBackoff == 0 {
+		c.MaxBackoff = 1000
+	}
+
+	if c.Multiplier == 0 {
+		c.Multiplier = 2
+	}
+
+	if c.Jitter == 0 {
+		c.Jitter = 0.2
+	}
+
+	if c.Retryable == nil {
+		c.Retryable = DefaultRetryable
+	}
+}
+
+type BreakerConfig struct {
+	// FailureThreshold is the number of consecutive failures opening the breaker
+	FailureThreshold int `json:"failure_threshold" yaml:"failure_threshold"`
+	// OpenTimeout is in seconds, after it the breaker lets probes through
+	OpenTimeout int `json:"open_timeout" yaml:"open_timeout"`
+	// HalfOpenProbes is the number of concurrent probes allowed when half open
+	HalfOpenProbes int `json:"half_open_probes" yaml:"half_open_probes"`
+	// SuccessThreshold is the number of consecutive succeeded probes closing the breaker
+	SuccessThreshold int `json:"success_threshold" yaml:"success_threshold"`
+	// IsFailure reports whether an error counts as a failure, DefaultIsFailure is used when it is nil
+	IsFailure func(err error) bool `json:"-" yaml:"-"`
+}
+
+func (c *BreakerConfig) SetDefault() {
+	if c.FailureThreshold == 0 {
+		c.FailureThreshold = 5
+	}
+
+	if c.OpenTimeout == 0 {
+		c.OpenTimeout = 10
+	}
+
+	if c.HalfOpenProbes == 0 {
+		c.HalfOpenProbes = 1
+	}
+
+	if c.SuccessThreshold == 0 {
+		c.SuccessThreshold = 1
+	}
+
+	if c.IsFailure == nil {
+		c.IsFailure = DefaultIsFailure
+	}
+}
+
+func (c *BreakerConfig) openTimeout() time.Duration {
+	return time.Duration(c.OpenTimeout) * time.Second
+}
+
+type BulkheadConfig struct {
+	MaxConcurrent int `json:"max_concurrent" yaml:"max_concurrent"`
+	// MaxWait is in milliseconds, calls beyond MaxConcurrent fail right away when it is 0
+	MaxWait int `json:"max_wait" yaml:"max_wait"`
+}
+
+func (c *BulkheadConfig) SetDefault() {
+	if c.MaxConcurrent == 0 {
+		c.MaxConcurrent = 100
+	}
+}
+
+// Policy is the set of policies applied to a downstream operation, nil policies are not applied
+type Policy struct {
+	// Operation is the full operation name such as /svcore.VideoService/GetVideoById, or * for all operations
+	Operation string          `json:"operation" yaml:"operation"`
+	Retry     *RetryConfig    `json:"retry" yaml:"retry"`
+	Breaker   *BreakerConfig  `json:"breaker" yaml:"breaker"`
+	Bulkhead  *BulkheadConfig `json:"bulkhead" yaml:"bulkhead"`
+}
+
+func (p *Policy) SetDefault() {
+	if p.Retry != nil {
+		p.Retry.SetDefault()
+	}
+
+	if p.Breaker != nil {
+		p.Breaker.SetDefault()
+	}
+
+	if p.Bulkhead != nil {
+		p.Bulkhead.SetDefault()
+	}
+}
+
+type Config struct {
+	Policies []*Policy `json:"policies" yaml:"policies"`
+}
+
+func (c *Config) SetDefault() {
+	for _, policy := range c.Policies {
+		policy.SetDefault()
+	}
+}
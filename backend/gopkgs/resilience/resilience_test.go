@@ -0,0 +1,184 @@
+package resilience
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/go-kratos/kratos/v2/errors"
+	"github.com/stretchr/testify/assert"
+)
+
+var (
+	errUnavailable = errors.ServiceUnavailable("UNAVAILABLE", "downstream unavailable")
+	errBadRequest  = errors.BadRequest("BAD_REQUEST", "invalid argument")
+)
+
+func TestRetry(t *testing.T) {
+	cfg := &RetryConfig{MaxAttempts: 3, InitialBackoff: 1, MaxBackoff: 2}
+
+	var calls int
+	err := Retry(context.Background(), cfg, func(ctx context.Context) error {
+		calls++
+		if calls < 3 {
+			return errUnavailable
+		}
+		return nil
+	})
+	assert.Nil(t, err)
+	assert.Equal(t, 3, calls)
+
+	calls = 0
+	err = Retry(context.Background(), cfg, func(ctx context.Context) error {
+		calls++
+		return errUnavailable
+	})
+	assert.ErrorIs(t, err, errUnavailable)
+	assert.Equal(t, 3, calls)
+
+	calls = 0
+	err = Retry(context.Background(), cfg, func(ctx context.Context) error {
+		calls++
+		return errBadRequest
+	})
+	assert.ErrorIs(t, err, errBadRequest)
+	assert.Equal(t, 1, calls)
+}
+
+func TestRetry_ContextDone(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	var calls int
+	err := Retry(ctx, &RetryConfig{MaxAttempts: 5, InitialBackoff: 1000}, func(ctx context.Context) error {
+		calls++
+		return errUnavailable
+	})
+	assert.ErrorIs(t, err, errUnavailable)
+	assert.Equal(t, 1, calls)
+}
+
+func TestRetryConfig_Backoff(t *testing.T) {
+	cfg := &RetryConfig{InitialBackoff: 100, MaxBackoff: 300, Multiplier: 2, Jitter: 0.1}
+	cfg.SetDefault()
+
+	assert.InDelta(t, 100*time.Millisecond, cfg.Backoff(1), float64(10*time.Millisecond))
+	assert.InDelta(t, 200*time.Millisecond, cfg.Backoff(2), float64(20*time.Millisecond))
+	assert.InDelta(t, 300*time.Millisecond, cfg.Backoff(5), float64(30*time.Millisecond))
+}
+
+func TestBreaker(t *testing.T) {
+	now := time.Now()
+	b := NewBreaker("svcore", &BreakerConfig{FailureThreshold: 2, OpenTimeout: 1, SuccessThreshold: 1})
+	b.now = func() time.Time {
+		return now
+	}
+
+	fail := func(ctx context.Context) error {
+		return errUnavailable
+	}
+	succeed := func(ctx context.Context) error {
+		return nil
+	}
+
+	// client errors do not count as failures
+	assert.ErrorIs(t, b.Execute(context.Background(), func(ctx context.Context) error {
+		return errBadRequest
+	}), errBadRequest)
+	assert.ErrorIs(t, b.Execute(context.Background(), fail), errUnavailable)
+	assert.Equal(t, StateClosed, b.State())
+	assert.ErrorIs(t, b.Execute(context.Background(), fail), errUnavailable)
+	assert.Equal(t, StateOpen, b.State())
+	assert.ErrorIs(t, b.Execute(context.Background(), succeed), ErrCircuitOpen)
+
+	// a failed probe opens the breaker again
+	now = now.Add(time.Second)
+	assert.Equal(t, StateHalfOpen, b.State())
+	assert.ErrorIs(t, b.Execute(context.Background(), fail), errUnavailable)
+	assert.Equal(t, StateOpen, b.State())
+
+	now = now.Add(time.Second)
+	assert.Nil(t, b.Execute(context.Background(), succeed))
+	assert.Equal(t, StateClosed, b.State())
+}
+
+func TestBreaker_HalfOpenProbes(t *testing.T) {
+	now := time.Now()
+	b := NewBreaker("svcore", &BreakerConfig{FailureThreshold: 1, OpenTimeout: 1, HalfOpenProbes: 1})
+	b.now = func() time.Time {
+		return now
+	}
+
+	done, err := b.Allow()
+	assert.Nil(t, err)
+	done(errUnavailable)
+
+	now = now.Add(time.Second)
+	probe, err := b.Allow()
+	assert.Nil(t, err)
+
+	_, err = b.Allow()
+	assert.ErrorIs(t, err, ErrCircuitOpen)
+
+	probe(nil)
+	assert.Equal(t, StateClosed, b.State())
+}
+
+func TestBreaker_Panic(t *testing.T) {
+	now := time.Now()
+	b := NewBreaker("svcore", &BreakerConfig{FailureThreshold: 1, OpenTimeout: 1, HalfOpenProbes: 1})
+	b.now = func() time.Time {
+		return now
+	}
+
+	panics := func(ctx context.Context) error {
+		panic("boom")
+	}
+
+	// a panicking probe is a failure, it does not keep the probe slot
+	assert.ErrorIs(t, b.Execute(context.Background(), func(ctx context.Context) error {
+		return errUnavailable
+	}), errUnavailable)
+	now = now.Add(time.Second)
+	assert.PanicsWithValue(t, "boom", func() {
+		_ = b.Execute(context.Background(), panics)
+	})
+	assert.Equal(t, StateOpen, b.State())
+
+	now = now.Add(time.Second)
+	assert.Nil(t, b.Execute(context.Background(), func(ctx context.Context) error {
+		return nil
+	}))
+	assert.Equal(t, StateClosed, b.State())
+}
+
+func TestBulkhead(t *testing.T) {
+	b := NewBulkhead(&BulkheadConfig{MaxConcurrent: 1, MaxWait: 10})
+
+	started := make(chan struct{})
+	release := make(chan struct{})
+	var wg sync.WaitGroup
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		_ = b.Execute(context.Background(), func(ctx context.Context) error {
+			close(started)
+			<-release
+			return nil
+		})
+	}()
+	<-started
+
+	err := b.Execute(context.Background(), func(ctx context.Context) error {
+		return nil
+	})
+	assert.ErrorIs(t, err, ErrBulkheadFull)
+	assert.False(t, DefaultRetryable(err))
+
+	close(release)
+	wg.Wait()
+	assert.Nil(t, b.Execute(context.Background(), func(ctx context.Context) error {
+		return nil
+	}))
+}
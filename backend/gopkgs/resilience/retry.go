@@ -0,0 +1,56 @@
+package resilience
+
+import (
+	"context"
+	"math"
+	"math/rand"
+	"net/http"
+	"time"
+
+	"github.com/go-kratos/kratos/v2/errors"
+)
+
+// DefaultRetryable retries the errors of unavailable or timed out downstream services,
+// it never retries when the circuit breaker is open or the bulkhead is full
+func DefaultRetryable(err error) bool {
+	if err == nil || errors.Is(err, ErrCircuitOpen) || errors.Is(err, ErrBulkheadFull) {
+		return false
+	}
+
+	switch errors.FromError(err).Code {
+	case http.StatusBadGateway, http.StatusServiceUnavailable, http.StatusGatewayTimeout:
+		return true
+	default:
+		return false
+	}
+}
+
+// Backoff returns the time to wait before the attempt following attempt, attempts start from 1
+func (c *RetryConfig) Backoff(attempt int) time.Duration {
+	backoff := float64(c.InitialBackoff) * math.Pow(c.Multiplier, float64(attempt-1))
+	backoff = math.Min(backoff, float64(c.MaxBackoff))
+	backoff *= 1 + c.Jitter*(rand.Float64()*2-1)
+	return time.Duration(backoff * float64(time.Millisecond))
+}
+
+// Retry calls fn until it succeeds, returns an error which is not retryable, the attempts are exhausted
+// or ctx is done. The error of the last attempt is returned.
+func Retry(ctx context.Context, cfg *RetryConfig, fn func(ctx context.Context) error) error {
+	cfg.SetDefault()
+
+	var err error
+	for attempt := 1; ; attempt++ {
+		err = fn(ctx)
+		if err == nil || attempt >= cfg.MaxAttempts || !cfg.Retryable(err) {
+			return err
+		}
+
+		timer := time.NewTimer(cfg.Backoff(attempt))
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return err
+		case <-timer.C:
+		}
+	}
+}
@@ -0,0 +1,46 @@
+package scheduler
+
+import (
+	"context"
+	"time"
+
+	"github.com/robfig/cron/v3"
+)
+
+type Job struct {
+	Name     string
+	Schedule cron.Schedule
+	Run      func(ctx context.Context) error
+	// Timeout cancels the context of a run when positive
+	Timeout time.Duration
+	// Local jobs run on every replica instead of once across replicas
+	Local bool
+}
+
+type JobOption func(job *Job)
+
+// WithTimeout cancels the context of a run after timeout
+func WithTimeout(timeout time.Duration) JobOption {
+	return func(job *Job) {
+		job.Timeout = timeout
+	}
+}
+
+// WithLocal runs the job on every replica, such as jobs cleaning up local state
+func WithLocal() JobOption {
+	return func(job *Job) {
+		job.Local = true
+	}
+}
+
+// JobStatus is the result of the last run of a job
+type JobStatus struct {
+	Name      string        `json:"name"`
+	LastRun   time.Time     `json:"last_run"`
+	Duration  time.Duration `json:"duration"`
+	LastError string        `json:"last_error"`
+	Runs      int64         `json:"runs"`
+	Failures  int64         `json:"failures"`
+	// Node is the host name of the replica which ran the job last
+	Node string `json:"node"`
+}
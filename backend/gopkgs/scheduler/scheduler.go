@@ -0,0 +1,379 @@
+package scheduler
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/cloudzenith/DouTok/backend/gopkgs/cachex"
+	"github.com/go-kratos/kratos/v2/log"
+	"github.com/redis/go-redis/v9"
+	"github.com/robfig/cron/v3"
+)
+
+const (
+	defaultKeyPrefix = "scheduler"
+	defaultLockTTL   = time.Minute
+
+	fieldLastScheduled = "last_scheduled"
+	fieldLastRun       = "last_run"
+	fieldDuration      = "duration"
+	fieldLastError     = "last_error"
+	fieldRuns          = "runs"
+	fieldFailures      = "failures"
+	fieldNode          = "node"
+)
+
+var ErrJobNotFound = errors.New("job not found")
+
+// Scheduler runs cron and interval jobs, it implements transport.Server so it can be served by the launcher.
+// When a redis client is set, a job runs on one replica per tick and its status is shared by all replicas.
+type Scheduler struct {
+	client    *redis.Client
+	keyPrefix string
+	lockTTL   time.Duration
+	locker    *cachex.LockHandle
+	node      string
+
+	now      func() time.Time
+	mu       sync.Mutex
+	jobs     map[string]*Job
+	statuses map[string]*JobStatus
+
+	// ctx is the context of the loops while the scheduler is started, it is guarded by mu
+	ctx     context.Context
+	cancel  context.CancelFunc
+	running sync.WaitGroup
+}
+
+type Option func(s *Scheduler)
+
+// WithRedisClient makes jobs run once across replicas and shares their status through redis
+func WithRedisClient(client *redis.Client) Option {
+	return func(s *Scheduler) {
+		s.client = client
+	}
+}
+
+// WithKeyPrefix sets the prefix of the redis keys of locks and statuses, "scheduler" by default
+func WithKeyPrefix(prefix string) Option {
+	return func(s *Scheduler) {
+		s.keyPrefix = prefix
+	}
+}
+
+// WithLockTTL sets the TTL of job locks, locks are renewed while their job runs
+func WithLockTTL(ttl time.Duration) Option {
+	return func(s *Scheduler) {
+		s.lockTTL = ttl
+	}
+}
+
+func New(options ...Option) *Scheduler {
+	s := &Scheduler{
+		keyPrefix: defaultKeyPrefix,
+		lockTTL:   defaultLockTTL,
+		jobs:      make(map[string]*Job),
+		statuses:  make(map[string]*JobStatus),
+		now:       time.Now,
+	}
+
+	for _, option := range options {
+		option(s)
+	}
+
+	s.node, _ = os.Hostname()
+	if s.client != nil {
+		s.locker = cachex.New(s.client, s.keyPrefix+":lock:%s", s.lockTTL, s.lockTTL)
+	}
+
+	return s
+}
+
+// AddCron registers a job run on a standard cron expression such as "*/5 * * * *", descriptors such as
+// "@hourly" and "@every 30s" are supported too, "@every" jobs are aligned like the jobs of AddInterval
+func (s *Scheduler) AddCron(name, spec string, run func(ctx context.Context) error, options ...JobOption) error {
+	schedule, err := cron.ParseStandard(spec)
+	if err != nil {
+		return fmt.Errorf("invalid cron expression of job %s: %w", name, err)
+	}
+
+	return s.add(name, schedule, run, options...)
+}
+
+// AddInterval registers a job run every interval, the ticks are the multiples of interval since the unix epoch
+// so all replicas agree on them whenever they started
+func (s *Scheduler) AddInterval(name string, interval time.Duration, run func(ctx context.Context) error, options ...JobOption) error {
+	if interval < time.Second {
+		return fmt.Errorf("interval of job %s should be at least one second", name)
+	}
+
+	return s.add(name, cron.Every(interval), run, options...)
+}
+
+func (s *Scheduler) add(name string, schedule cron.Schedule, run func(ctx context.Context) error, options ...JobOption) error {
+	job := &Job{
+		Name:     name,
+		Schedule: aligned(schedule),
+		Run:      run,
+	}
+
+	for _, option := range options {
+		option(job)
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, ok := s.jobs[name]; ok {
+		return fmt.Errorf("job %s already exists", name)
+	}
+
+	s.jobs[name] = job
+	s.statuses[name] = &JobStatus{Name: name}
+
+	// a job added to a started scheduler is scheduled right away
+	if s.ctx != nil {
+		s.running.Add(1)
+		go s.loop(s.ctx, job)
+	}
+
+	return nil
+}
+
+// aligned replaces the constant delay schedules of cron, which tick relative to the local clock,
+// with schedules ticking on multiples of their delay
+func aligned(schedule cron.Schedule) cron.Schedule {
+	if every, ok := schedule.(cron.ConstantDelaySchedule); ok {
+		return intervalSchedule(every.Delay)
+	}
+
+	return schedule
+}
+
+type intervalSchedule time.Duration
+
+func (s intervalSchedule) Next(t time.Time) time.Time {
+	interval := time.Duration(s)
+	return t.Truncate(interval).Add(interval)
+}
+
+// Start starts a loop for every job, jobs added later are started when they are added
+func (s *Scheduler) Start(ctx context.Context) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.ctx != nil {
+		return errors.New("scheduler already started")
+	}
+
+	s.ctx, s.cancel = context.WithCancel(context.WithoutCancel(ctx))
+	for _, job := range s.jobs {
+		s.running.Add(1)
+		go s.loop(s.ctx, job)
+	}
+
+	log.Infof("scheduler started with %d jobs", len(s.jobs))
+	return nil
+}
+
+// Stop stops scheduling jobs and waits for the running ones until ctx is done
+func (s *Scheduler) Stop(ctx context.Context) error {
+	s.mu.Lock()
+	cancel := s.cancel
+	s.ctx, s.cancel = nil, nil
+	s.mu.Unlock()
+
+	if cancel == nil {
+		return nil
+	}
+	cancel()
+
+	done := make(chan struct{})
+	go func() {
+		s.running.Wait()
+		close(done)
+	}()
+
+	select {
+	case <-done:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+func (s *Scheduler) loop(ctx context.Context, job *Job) {
+	defer s.running.Done()
+
+	for {
+		now := s.now()
+		next := job.Schedule.Next(now)
+		timer := time.NewTimer(next.Sub(now))
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return
+		case <-timer.C:
+		}
+
+		// another replica holds the lock, it runs the tick
+		err := s.runScheduled(ctx, job, next)
+		if err != nil && !errors.Is(err, cachex.ErrLockNotObtained) {
+			log.Errorf("job %s failed: %v", job.Name, err)
+		}
+	}
+}
+
+// Trigger runs the job named name right away and returns its error, it is mostly used by tests.
+// It returns cachex.ErrLockNotObtained when the job is running on another replica.
+func (s *Scheduler) Trigger(ctx context.Context, name string) error {
+	s.mu.Lock()
+	job, ok := s.jobs[name]
+	s.mu.Unlock()
+	if !ok {
+		return ErrJobNotFound
+	}
+
+	return s.runScheduled(ctx, job, time.Time{})
+}
+
+// runScheduled runs job for the tick scheduled, a zero scheduled time runs it unconditionally.
+// Across replicas the job lock and the last scheduled tick make every tick run once,
+// cachex.ErrLockNotObtained is returned when another replica holds the lock.
+func (s *Scheduler) runScheduled(ctx context.Context, job *Job, scheduled time.Time) error {
+	if s.client == nil || job.Local {
+		return s.run(ctx, job, scheduled)
+	}
+
+	lock, err := s.locker.TryLock(ctx, job.Name)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		_ = lock.Release(context.WithoutCancel(ctx))
+	}()
+
+	if !scheduled.IsZero() {
+		last, err := s.client.HGet(ctx, s.statusKey(job.Name), fieldLastScheduled).Int64()
+		if err != nil && !errors.Is(err, redis.Nil) {
+			return err
+		}
+
+		if last >= scheduled.UnixNano() {
+			return nil
+		}
+	}
+
+	return s.run(ctx, job, scheduled)
+}
+
+func (s *Scheduler) run(ctx context.Context, job *Job, scheduled time.Time) (err error) {
+	runCtx := ctx
+	if job.Timeout > 0 {
+		var cancel context.CancelFunc
+		runCtx, cancel = context.WithTimeout(ctx, job.Timeout)
+		defer cancel()
+	}
+
+	start := time.Now()
+	defer func() {
+		if r := recover(); r != nil {
+			err = fmt.Errorf("job %s panic: %v", job.Name, r)
+		}
+
+		s.record(context.WithoutCancel(ctx), job.Name, scheduled, start, err)
+	}()
+
+	return job.Run(runCtx)
+}
+
+func (s *Scheduler) record(ctx context.Context, name string, scheduled, start time.Time, err error) {
+	duration := time.Since(start)
+	var lastError string
+	if err != nil {
+		lastError = err.Error()
+	}
+
+	s.mu.Lock()
+	status := s.statuses[name]
+	status.LastRun = start
+	status.Duration = duration
+	status.LastError = lastError
+	status.Node = s.node
+	status.Runs++
+	if err != nil {
+		status.Failures++
+	}
+	s.mu.Unlock()
+
+	if s.client == nil {
+		return
+	}
+
+	key := s.statusKey(name)
+	pipe := s.client.TxPipeline()
+	values := map[string]any{
+		fieldLastRun:   start.Format(time.RFC3339Nano),
+		fieldDuration:  duration.Milliseconds(),
+		fieldLastError: lastError,
+		fieldNode:      s.node,
+	}
+	if !scheduled.IsZero() {
+		values[fieldLastScheduled] = scheduled.UnixNano()
+	}
+	pipe.HSet(ctx, key, values)
+	pipe.HIncrBy(ctx, key, fieldRuns, 1)
+	if err != nil {
+		pipe.HIncrBy(ctx, key, fieldFailures, 1)
+	}
+
+	if _, err := pipe.Exec(ctx); err != nil {
+		log.Context(ctx).Warnf("failed to record status of job %s: %v", name, err)
+	}
+}
+
+// Status returns the status of the job named name, it is read from redis when a redis client is set
+// so runs on other replicas are included
+func (s *Scheduler) Status(ctx context.Context, name string) (*JobStatus, error) {
+	s.mu.Lock()
+	local, ok := s.statuses[name]
+	if ok {
+		copied := *local
+		local = &copied
+	}
+	s.mu.Unlock()
+
+	if !ok {
+		return nil, ErrJobNotFound
+	}
+
+	if s.client == nil {
+		return local, nil
+	}
+
+	values, err := s.client.HGetAll(ctx, s.statusKey(name)).Result()
+	if err != nil {
+		return nil, err
+	}
+
+	status := &JobStatus{
+		Name:      name,
+		LastError: values[fieldLastError],
+		Node:      values[fieldNode],
+	}
+	status.LastRun, _ = time.Parse(time.RFC3339Nano, values[fieldLastRun])
+	duration, _ := strconv.ParseInt(values[fieldDuration], 10, 64)
+	status.Duration = time.Duration(duration) * time.Millisecond
+	status.Runs, _ = strconv.ParseInt(values[fieldRuns], 10, 64)
+	status.Failures, _ = strconv.ParseInt(values[fieldFailures], 10, 64)
+	return status, nil
+}
+
+func (s *Scheduler) statusKey(name string) string {
+	return s.keyPrefix + ":status:" + name
+}
@@ -0,0 +1,182 @@
+package scheduler
+
+import (
+	"context"
+	"errors"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/cloudzenith/DouTok/backend/gopkgs/cachex"
+	"github.com/redis/go-redis/v9"
+	"github.com/stretchr/testify/assert"
+)
+
+func newTestClient(t *testing.T) *redis.Client {
+	s := miniredis.RunT(t)
+	client := redis.NewClient(&redis.Options{Addr: s.Addr()})
+	t.Cleanup(func() {
+		_ = client.Close()
+	})
+
+	return client
+}
+
+func TestScheduler_AddJob(t *testing.T) {
+	s := New()
+	run := func(ctx context.Context) error {
+		return nil
+	}
+
+	assert.Nil(t, s.AddCron("close-orders", "*/5 * * * *", run))
+	assert.NotNil(t, s.AddCron("close-orders", "*/5 * * * *", run))
+	assert.NotNil(t, s.AddCron("invalid", "every minute", run))
+	assert.Nil(t, s.AddCron("gc-uploads", "@every 1h", run))
+	assert.NotNil(t, s.AddInterval("flush", time.Millisecond, run))
+}
+
+func TestScheduler_Trigger(t *testing.T) {
+	s := New()
+	failure := errors.New("failure")
+	assert.Nil(t, s.AddInterval("reconcile", time.Hour, func(ctx context.Context) error {
+		return failure
+	}))
+
+	assert.ErrorIs(t, s.Trigger(context.Background(), "reconcile"), failure)
+	assert.ErrorIs(t, s.Trigger(context.Background(), "unknown"), ErrJobNotFound)
+
+	status, err := s.Status(context.Background(), "reconcile")
+	assert.Nil(t, err)
+	assert.Equal(t, int64(1), status.Runs)
+	assert.Equal(t, int64(1), status.Failures)
+	assert.Equal(t, "failure", status.LastError)
+	assert.False(t, status.LastRun.IsZero())
+}
+
+func TestScheduler_RunOncePerTickAcrossReplicas(t *testing.T) {
+	client := newTestClient(t)
+
+	var runs atomic.Int64
+	job := func(ctx context.Context) error {
+		runs.Add(1)
+		return nil
+	}
+
+	replicas := []*Scheduler{New(WithRedisClient(client)), New(WithRedisClient(client))}
+	for _, s := range replicas {
+		assert.Nil(t, s.AddInterval("flush", time.Minute, job))
+	}
+
+	tick := time.Now().Truncate(time.Minute)
+	for _, s := range replicas {
+		assert.Nil(t, s.runScheduled(context.Background(), s.jobs["flush"], tick))
+	}
+	assert.Equal(t, int64(1), runs.Load())
+
+	for _, s := range replicas {
+		assert.Nil(t, s.runScheduled(context.Background(), s.jobs["flush"], tick.Add(time.Minute)))
+	}
+	assert.Equal(t, int64(2), runs.Load())
+
+	// the status of a run is visible from every replica
+	status, err := replicas[1].Status(context.Background(), "flush")
+	assert.Nil(t, err)
+	assert.Equal(t, int64(2), status.Runs)
+	assert.Empty(t, status.LastError)
+}
+
+func TestScheduler_IntervalAlignedAcrossReplicas(t *testing.T) {
+	client := newTestClient(t)
+
+	var runs atomic.Int64
+	job := func(ctx context.Context) error {
+		runs.Add(1)
+		return nil
+	}
+
+	// the clocks of the replicas are 20 seconds apart
+	start := time.Now().Truncate(time.Minute).Add(10 * time.Second)
+	replicas := []*Scheduler{New(WithRedisClient(client)), New(WithRedisClient(client))}
+	for i, s := range replicas {
+		now := start.Add(time.Duration(i) * 20 * time.Second)
+		s.now = func() time.Time {
+			return now
+		}
+		assert.Nil(t, s.AddInterval("flush", time.Minute, job))
+	}
+
+	var ticks []time.Time
+	for _, s := range replicas {
+		tick := s.jobs["flush"].Schedule.Next(s.now())
+		ticks = append(ticks, tick)
+		assert.Nil(t, s.runScheduled(context.Background(), s.jobs["flush"], tick))
+	}
+	assert.Equal(t, ticks[0], ticks[1])
+	assert.Equal(t, start.Truncate(time.Minute).Add(time.Minute), ticks[0])
+	assert.Equal(t, int64(1), runs.Load())
+}
+
+func TestScheduler_TriggerLocked(t *testing.T) {
+	client := newTestClient(t)
+	s := New(WithRedisClient(client))
+	assert.Nil(t, s.AddInterval("flush", time.Minute, func(ctx context.Context) error {
+		return nil
+	}))
+
+	lock, err := s.locker.TryLock(context.Background(), "flush")
+	assert.Nil(t, err)
+	assert.ErrorIs(t, s.Trigger(context.Background(), "flush"), cachex.ErrLockNotObtained)
+
+	assert.Nil(t, lock.Release(context.Background()))
+	assert.Nil(t, s.Trigger(context.Background(), "flush"))
+}
+
+func TestScheduler_StartStop(t *testing.T) {
+	s := New()
+
+	ran := make(chan struct{}, 1)
+	assert.Nil(t, s.AddInterval("flush", time.Second, func(ctx context.Context) error {
+		select {
+		case ran <- struct{}{}:
+		default:
+		}
+		return nil
+	}))
+
+	assert.Nil(t, s.Start(context.Background()))
+	select {
+	case <-ran:
+	case <-time.After(3 * time.Second):
+		t.Fatal("job did not run")
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	assert.Nil(t, s.Stop(ctx))
+}
+
+func TestScheduler_AddAfterStart(t *testing.T) {
+	s := New()
+	assert.Nil(t, s.Start(context.Background()))
+	assert.NotNil(t, s.Start(context.Background()))
+
+	ran := make(chan struct{}, 1)
+	assert.Nil(t, s.AddInterval("flush", time.Second, func(ctx context.Context) error {
+		select {
+		case ran <- struct{}{}:
+		default:
+		}
+		return nil
+	}))
+
+	select {
+	case <-ran:
+	case <-time.After(3 * time.Second):
+		t.Fatal("job added after start did not run")
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	assert.Nil(t, s.Stop(ctx))
+}
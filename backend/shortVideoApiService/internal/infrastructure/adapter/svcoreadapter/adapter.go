@@ -2,7 +2,7 @@ package svcoreadapter
 
 import (
 	"context"
-	"github.com/cloudzenith/DouTok/backend/gopkgs/components/consulx"
+	"github.com/cloudzenith/DouTok/backend/gopkgs/registryx"
 	v1 "github.com/cloudzenith/DouTok/backend/shortVideoCoreService/api/v1"
 )
 
@@ -16,7 +16,7 @@ type Adapter struct {
 }
 
 func New() *Adapter {
-	conn, err := consulx.GetGrpcConn(context.Background(), "discovery:///short-video-core-service")
+	conn, err := registryx.GetGrpcConn(context.Background(), "discovery:///short-video-core-service")
 	if err != nil {
 		panic(err)
 	}
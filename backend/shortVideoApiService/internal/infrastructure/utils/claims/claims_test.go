@@ -0,0 +1,87 @@
+package claims_test
+
+import (
+	"context"
+	nethttp "net/http"
+	"testing"
+
+	"github.com/cloudzenith/DouTok/backend/gopkgs/middlewares/ratelimiter"
+	"github.com/cloudzenith/DouTok/backend/gopkgs/ratelimit"
+	"github.com/cloudzenith/DouTok/backend/shortVideoApiService/internal/infrastructure/utils/claims"
+	"github.com/go-kratos/kratos/v2/middleware"
+	"github.com/go-kratos/kratos/v2/middleware/auth/jwt"
+	"github.com/go-kratos/kratos/v2/transport"
+	jwt5 "github.com/golang-jwt/jwt/v5"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type headerCarrier nethttp.Header
+
+func (h headerCarrier) Get(key string) string { return nethttp.Header(h).Get(key) }
+
+func (h headerCarrier) Set(key, value string) { nethttp.Header(h).Set(key, value) }
+
+func (h headerCarrier) Add(key, value string) { nethttp.Header(h).Add(key, value) }
+
+func (h headerCarrier) Keys() []string {
+	keys := make([]string, 0, len(h))
+	for k := range h {
+		keys = append(keys, k)
+	}
+	return keys
+}
+
+func (h headerCarrier) Values(key string) []string { return nethttp.Header(h).Values(key) }
+
+type testTransport struct {
+	header headerCarrier
+}
+
+func (t *testTransport) Kind() transport.Kind            { return transport.KindHTTP }
+func (t *testTransport) Endpoint() string                { return "" }
+func (t *testTransport) Operation() string               { return "/svapi.ShortVideoCoreVideoService/PublishVideo" }
+func (t *testTransport) RequestHeader() transport.Header { return t.header }
+func (t *testTransport) ReplyHeader() transport.Header   { return headerCarrier{} }
+
+func TestClaims_RateLimitedPerUser(t *testing.T) {
+	limiter, stop := ratelimiter.Server(&ratelimit.Config{
+		Backend: ratelimit.BackendMemory,
+		Rules:   []*ratelimit.Rule{{Operation: ratelimit.MatchAllOperations, Rate: 1, Period: 60}},
+	})
+	defer stop()
+
+	handler := middleware.Chain(
+		jwt.Server(
+			func(token *jwt5.Token) (interface{}, error) {
+				return []byte("token"), nil
+			},
+			jwt.WithClaims(func() jwt5.Claims {
+				return &claims.Claims{}
+			}),
+		),
+		limiter,
+	)(func(ctx context.Context, req interface{}) (interface{}, error) {
+		return claims.GetUserId(ctx)
+	})
+
+	call := func(userId int64) (interface{}, error) {
+		token, err := claims.GenerateToken(claims.New(userId))
+		require.NoError(t, err)
+
+		header := headerCarrier{}
+		header.Set("Authorization", "Bearer "+token)
+		ctx := transport.NewServerContext(context.Background(), &testTransport{header: header})
+		return handler(ctx, nil)
+	}
+
+	// the requests come from the same address, they are limited per user
+	reply, err := call(1)
+	require.NoError(t, err)
+	assert.Equal(t, int64(1), reply)
+	_, err = call(1)
+	assert.ErrorIs(t, err, ratelimiter.ErrLimitExceed)
+	reply, err = call(2)
+	require.NoError(t, err)
+	assert.Equal(t, int64(2), reply)
+}
@@ -3,7 +3,7 @@ package baseadapter
 import (
 	"context"
 	"github.com/cloudzenith/DouTok/backend/baseService/api"
-	"github.com/cloudzenith/DouTok/backend/gopkgs/components/consulx"
+	"github.com/cloudzenith/DouTok/backend/gopkgs/registryx"
 )
 
 type Adapter struct {
@@ -11,7 +11,7 @@ type Adapter struct {
 }
 
 func New() *Adapter {
-	conn, err := consulx.GetGrpcConn(context.Background(), "discovery:///base-service")
+	conn, err := registryx.GetGrpcConn(context.Background(), "discovery:///base-service")
 	if err != nil {
 		panic(err)
 	}
@@ -3,7 +3,7 @@ package baseservice
 import (
 	"context"
 	"github.com/cloudzenith/DouTok/backend/baseService/api"
-	"github.com/cloudzenith/DouTok/backend/gopkgs/components/consulx"
+	"github.com/cloudzenith/DouTok/backend/gopkgs/registryx"
 )
 
 type Handler struct {
@@ -11,7 +11,7 @@ type Handler struct {
 }
 
 func NewHandler() (*Handler, error) {
-	conn, err := consulx.GetGrpcConn(context.Background(), "discovery:///base-service")
+	conn, err := registryx.GetGrpcConn(context.Background(), "discovery:///base-service")
 	if err != nil {
 		return nil, err
 	}
@@ -0,0 +1,71 @@
+package server_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/cloudzenith/DouTok/backend/gopkgs/components/mysqlx"
+	"github.com/cloudzenith/DouTok/backend/gopkgs/launcher/testkit"
+	"github.com/cloudzenith/DouTok/backend/gopkgs/snowflakeutil"
+	v1 "github.com/cloudzenith/DouTok/backend/shortVideoCoreService/api/v1"
+	"github.com/cloudzenith/DouTok/backend/shortVideoCoreService/internal/conf"
+	"github.com/cloudzenith/DouTok/backend/shortVideoCoreService/internal/infrastructure/persistence/model"
+	"github.com/cloudzenith/DouTok/backend/shortVideoCoreService/internal/infrastructure/persistence/query"
+	"github.com/cloudzenith/DouTok/backend/shortVideoCoreService/internal/infrastructure/utils"
+	"github.com/cloudzenith/DouTok/backend/shortVideoCoreService/internal/server"
+	"github.com/go-kratos/kratos/v2/transport/grpc"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func newTestKit(t *testing.T) *testkit.Kit {
+	k := testkit.New(t,
+		testkit.WithConfigFile("../../configs/config.yaml"),
+		testkit.WithConfigValue(&conf.Config{}),
+		testkit.WithGrpcServer(func(configValue interface{}) *grpc.Server {
+			cfg := configValue.(*conf.Config)
+			utils.InitDefaultSnowflakeNode(cfg.App.Node)
+			snowflakeutil.InitDefaultSnowflakeNode(cfg.App.Node)
+			query.SetDefault(mysqlx.GetDBClient(context.Background()))
+			return server.NewGRPCServer(cfg)
+		}),
+	)
+	require.NoError(t, k.DB().AutoMigrate(&model.User{}, &model.Video{}))
+
+	return k
+}
+
+func TestGRPCServer_PublishAndFeed(t *testing.T) {
+	k := newTestKit(t)
+	ctx := context.Background()
+	users := v1.NewUserServiceClient(k.GrpcConn())
+	videos := v1.NewVideoServiceClient(k.GrpcConn())
+
+	user, err := users.CreateUser(ctx, &v1.CreateUserRequest{Mobile: "13800000000", AccountId: 1})
+	require.NoError(t, err)
+
+	info, err := users.GetUserInfo(ctx, &v1.GetUserInfoRequest{UserId: user.UserId})
+	require.NoError(t, err)
+	assert.Equal(t, "13800000000", info.User.Mobile)
+
+	published, err := videos.PublishVideo(ctx, &v1.PublishVideoRequest{
+		UserId:   user.UserId,
+		Title:    "hello",
+		PlayUrl:  "videos/1.mp4",
+		CoverUrl: "covers/1.png",
+	})
+	require.NoError(t, err)
+
+	// gorse is not running, the feed falls back to the latest videos
+	feed, err := videos.FeedShortVideo(ctx, &v1.FeedShortVideoRequest{
+		UserId:     user.UserId,
+		LatestTime: time.Now().Add(time.Second).Unix(),
+		FeedNum:    10,
+	})
+	require.NoError(t, err)
+	require.Len(t, feed.Videos, 1)
+	assert.Equal(t, published.VideoId, feed.Videos[0].Id)
+	assert.Equal(t, "hello", feed.Videos[0].Title)
+	assert.Equal(t, user.UserId, feed.Videos[0].Author.Id)
+}